@@ -12,9 +12,11 @@ func main() {
 	var (
 		configPath string
 		token      string
+		reencrypt  bool
 	)
 	flag.StringVar(&configPath, "c", "./config/config.toml", "path to config file")
 	flag.StringVar(&token, "t", "", "token for bot")
+	flag.BoolVar(&reencrypt, "reencrypt", false, "reencrypt stored requisites with current key and exit")
 	flag.Parse()
 
 	serverConfig := config.CreateConfigForServer()
@@ -22,10 +24,17 @@ func main() {
 	if err != nil {
 		logrus.Fatal(err)
 	}
+	if err = serverConfig.EncryptionParams.LoadFromEnv(); err != nil {
+		logrus.Fatal(err)
+	}
 	contextLogger := logrus.WithFields(logrus.Fields{})
 	logrus.SetReportCaller(false)
 	logrus.SetFormatter(&logrus.TextFormatter{PadLevelText: false, DisableLevelTruncation: false})
 	appServer := server.CreateServer(serverConfig, contextLogger, token)
 
+	if reencrypt {
+		appServer.Reencrypt()
+		return
+	}
 	appServer.Start()
 }
@@ -1,7 +1,31 @@
 package config
 
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// EncryptionKeysEnv has comma separated keys "<key id>:<base64 key>", keys are never kept in config file
+	EncryptionKeysEnv       = "ENCRYPTION_KEYS"
+	EncryptionCurrentKeyEnv = "ENCRYPTION_CURRENT_KEY"
+	encryptionKeySeparator  = ":"
+	encryptionKeysDivider   = ","
+)
+
+const (
+	DefaultReopenGracePeriod = 24 * time.Hour
+	DefaultQueueSize         = 64
+	DefaultStatsInterval     = 10 * time.Minute
+)
+
 type ServerConfig struct {
-	DatabaseParams PostgresConnectionParams `toml:"database"`
+	DatabaseParams   PostgresConnectionParams `toml:"database"`
+	EncryptionParams EncryptionParams         `toml:"encryption"`
+	SessionParams    SessionParams            `toml:"sessions"`
+	DispatcherParams DispatcherParams         `toml:"dispatcher"`
 }
 
 type PostgresConnectionParams struct {
@@ -12,6 +36,53 @@ type PostgresConnectionParams struct {
 	Password string
 }
 
+// EncryptionParams contains base64 encoded 32-byte AES keys by their ids.
+// New values are encrypted with CurrentKey, others are kept to decrypt old values.
+type EncryptionParams struct {
+	CurrentKey string `toml:"current_key"`
+	Keys       map[string]string
+}
+
+// LoadFromEnv takes keys from environment, values from environment replace ones from config file.
+func (p *EncryptionParams) LoadFromEnv() error {
+	if currentKey := os.Getenv(EncryptionCurrentKeyEnv); currentKey != "" {
+		p.CurrentKey = currentKey
+	}
+
+	keys := os.Getenv(EncryptionKeysEnv)
+	if keys == "" {
+		return nil
+	}
+
+	p.Keys = make(map[string]string)
+	for _, pair := range strings.Split(keys, encryptionKeysDivider) {
+		keyID, key, found := strings.Cut(strings.TrimSpace(pair), encryptionKeySeparator)
+		if !found || keyID == "" || key == "" {
+			return fmt.Errorf("%s: key must be written as <key id>%s<base64 key>", EncryptionKeysEnv,
+				encryptionKeySeparator)
+		}
+		p.Keys[keyID] = key
+	}
+	return nil
+}
+
+// SessionParams are limits of session lifecycle, durations are written like "24h".
+type SessionParams struct {
+	// ReopenGracePeriod is time after finish, during which session can be reopened
+	ReopenGracePeriod time.Duration `toml:"reopen_grace_period"`
+}
+
+// DispatcherParams limit processing of incoming updates, updates of every chat are processed one by one.
+type DispatcherParams struct {
+	// QueueSize is count of updates of one chat waiting for processing, newer updates are dropped
+	QueueSize int `toml:"queue_size"`
+	// StatsInterval is how often processing stats are logged, zero turns logging off
+	StatsInterval time.Duration `toml:"stats_interval"`
+}
+
 func CreateConfigForServer() *ServerConfig {
-	return &ServerConfig{}
+	return &ServerConfig{
+		SessionParams:    SessionParams{ReopenGracePeriod: DefaultReopenGracePeriod},
+		DispatcherParams: DispatcherParams{QueueSize: DefaultQueueSize, StatsInterval: DefaultStatsInterval},
+	}
 }
@@ -0,0 +1,50 @@
+package config
+
+import "testing"
+
+func TestEncryptionParamsLoadFromEnv(t *testing.T) {
+	t.Setenv(EncryptionCurrentKeyEnv, "v2")
+	t.Setenv(EncryptionKeysEnv, "v2:bmV3,v1:b2xk")
+
+	params := EncryptionParams{CurrentKey: "v1", Keys: map[string]string{"v0": "file"}}
+	if err := params.LoadFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if params.CurrentKey != "v2" {
+		t.Errorf("CurrentKey = %q, want v2", params.CurrentKey)
+	}
+	want := map[string]string{"v2": "bmV3", "v1": "b2xk"}
+	if len(params.Keys) != len(want) {
+		t.Fatalf("Keys = %v, want %v", params.Keys, want)
+	}
+	for keyID, key := range want {
+		if params.Keys[keyID] != key {
+			t.Errorf("Keys[%s] = %q, want %q", keyID, params.Keys[keyID], key)
+		}
+	}
+}
+
+func TestEncryptionParamsLoadFromEnvInvalid(t *testing.T) {
+	for _, keys := range []string{"v1", "v1:", ":key", "v1:key,"} {
+		t.Run(keys, func(t *testing.T) {
+			t.Setenv(EncryptionKeysEnv, keys)
+			params := EncryptionParams{}
+			if err := params.LoadFromEnv(); err == nil {
+				t.Errorf("LoadFromEnv() err = nil for %q", keys)
+			}
+		})
+	}
+}
+
+func TestEncryptionParamsLoadFromEmptyEnv(t *testing.T) {
+	t.Setenv(EncryptionCurrentKeyEnv, "")
+	t.Setenv(EncryptionKeysEnv, "")
+
+	params := EncryptionParams{CurrentKey: "v1", Keys: map[string]string{"v1": "file"}}
+	if err := params.LoadFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if params.CurrentKey != "v1" || params.Keys["v1"] != "file" {
+		t.Errorf("params from config file are changed: %+v", params)
+	}
+}
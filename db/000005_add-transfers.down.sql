@@ -0,0 +1 @@
+drop table transfers;
\ No newline at end of file
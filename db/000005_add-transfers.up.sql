@@ -0,0 +1,15 @@
+create table transfers (
+    id bigserial not null,
+    sender_id bigint not null,
+    recipient_id bigint not null,
+    money bigint not null,
+    created_at date default current_timestamp not null,
+    primary key (id),
+    foreign key (sender_id) references members (id) on delete cascade,
+    foreign key (recipient_id) references members (id) on delete cascade
+);
+
+alter table
+    transfers
+add
+    constraint "transfers_money_check" check (money > 0);
@@ -0,0 +1,9 @@
+drop table pot_contributions;
+
+alter table
+    costs drop column from_pot;
+
+alter table
+    sessions drop column mode;
+
+drop type session_mode_t;
@@ -0,0 +1,25 @@
+create type session_mode_t as enum ('regular', 'pot');
+
+alter table
+    sessions
+add
+    column mode session_mode_t default 'regular' not null;
+
+alter table
+    costs
+add
+    column from_pot boolean default false not null;
+
+create table pot_contributions (
+    id bigserial not null,
+    member_id bigint not null,
+    money bigint not null,
+    created_at date default current_timestamp not null,
+    primary key (id),
+    foreign key (member_id) references members (id) on delete cascade
+);
+
+alter table
+    pot_contributions
+add
+    constraint "pot_contributions_money_check" check (money > 0);
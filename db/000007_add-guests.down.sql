@@ -0,0 +1,12 @@
+delete from users where tg_id is null;
+
+alter table
+    users drop constraint "users_guest_check";
+
+alter table
+    users drop column guest_chat_id;
+
+alter table
+    users
+alter column
+    tg_id set not null;
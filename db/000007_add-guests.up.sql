@@ -0,0 +1,14 @@
+alter table
+    users
+alter column
+    tg_id drop not null;
+
+alter table
+    users
+add
+    column guest_chat_id bigint;
+
+alter table
+    users
+add
+    constraint "users_guest_check" check ((tg_id is null) = (guest_chat_id is not null));
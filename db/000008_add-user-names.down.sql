@@ -0,0 +1,5 @@
+alter table
+    users drop column last_name;
+
+alter table
+    users drop column first_name;
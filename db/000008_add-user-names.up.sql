@@ -0,0 +1,9 @@
+alter table
+    users
+add
+    column first_name text default '' not null;
+
+alter table
+    users
+add
+    column last_name text default '' not null;
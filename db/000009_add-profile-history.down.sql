@@ -0,0 +1,4 @@
+drop table user_profile_history;
+
+alter table
+    users drop column language_code;
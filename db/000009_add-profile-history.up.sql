@@ -0,0 +1,16 @@
+alter table
+    users
+add
+    column language_code text default '' not null;
+
+create table user_profile_history (
+    id bigserial not null,
+    user_id bigint not null,
+    username text not null,
+    first_name text not null,
+    last_name text not null,
+    language_code text not null,
+    changed_at timestamp default current_timestamp not null,
+    primary key (id),
+    foreign key (user_id) references users (id) on delete cascade
+);
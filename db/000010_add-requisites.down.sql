@@ -0,0 +1,3 @@
+drop table requisites;
+
+drop type requisite_kind_t;
@@ -0,0 +1,15 @@
+create type requisite_kind_t as enum ('phone', 'card');
+
+create table requisites (
+    id bigserial not null,
+    user_id bigint not null,
+    kind requisite_kind_t not null,
+    value text not null,
+    bank text not null,
+    is_default boolean default false not null,
+    created_at date default current_timestamp not null,
+    primary key (id),
+    foreign key (user_id) references users (id) on delete cascade
+);
+
+create unique index requisites_default_idx on requisites (user_id) where is_default;
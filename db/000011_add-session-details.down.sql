@@ -0,0 +1,7 @@
+drop index members_user_id_idx;
+
+alter table
+    sessions drop column finished_at;
+
+alter table
+    sessions drop column chat_title;
@@ -0,0 +1,11 @@
+alter table
+    sessions
+add
+    column chat_title text default '' not null;
+
+alter table
+    sessions
+add
+    column finished_at date;
+
+create index members_user_id_idx on members (user_id);
@@ -0,0 +1,5 @@
+drop table netting_debts;
+
+drop table nettings;
+
+drop type netting_status_t;
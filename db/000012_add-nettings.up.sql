@@ -0,0 +1,23 @@
+create type netting_status_t as enum ('proposed', 'done', 'declined');
+
+create table nettings (
+    id bigserial not null,
+    first_user_id bigint not null,
+    second_user_id bigint not null,
+    money bigint not null,
+    first_confirmed boolean default false not null,
+    second_confirmed boolean default false not null,
+    status netting_status_t default 'proposed' not null,
+    created_at date default current_timestamp not null,
+    primary key (id),
+    foreign key (first_user_id) references users (id) on delete cascade,
+    foreign key (second_user_id) references users (id) on delete cascade
+);
+
+create table netting_debts (
+    netting_id bigint not null,
+    debt_id bigint not null,
+    primary key (netting_id, debt_id),
+    foreign key (netting_id) references nettings (id) on delete cascade,
+    foreign key (debt_id) references debts (id) on delete cascade
+);
@@ -0,0 +1,2 @@
+alter table
+    users drop column last_session_id;
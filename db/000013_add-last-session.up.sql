@@ -0,0 +1,9 @@
+alter table
+    users
+add
+    column last_session_id uuid;
+
+alter table
+    users
+add
+    constraint "users_last_session_fk" foreign key (last_session_id) references sessions (uuid) on delete set null;
@@ -0,0 +1,4 @@
+drop index sessions_invite_token_idx;
+
+alter table
+    sessions drop column invite_token;
@@ -0,0 +1,6 @@
+alter table
+    sessions
+add
+    column invite_token text;
+
+create unique index sessions_invite_token_idx on sessions (invite_token);
@@ -0,0 +1,4 @@
+alter table
+    users
+add
+    column notify boolean default false not null;
@@ -0,0 +1,6 @@
+alter table
+    users drop column remind;
+
+drop table reminders;
+
+drop table chat_settings;
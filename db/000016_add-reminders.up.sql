@@ -0,0 +1,31 @@
+create table chat_settings (
+    chat_id bigint not null,
+    remind_enabled boolean default true not null,
+    remind_interval_days integer default 3 not null,
+    remind_max integer default 3 not null,
+    remind_in_group boolean default false not null,
+    primary key (chat_id)
+);
+
+alter table
+    chat_settings
+add
+    constraint "chat_settings_remind_check" check (remind_interval_days > 0 and remind_max > 0);
+
+create table reminders (
+    id bigserial not null,
+    debt_id bigint not null,
+    chat_id bigint not null,
+    next_at timestamptz not null,
+    sent integer default 0 not null,
+    primary key (id),
+    unique (debt_id),
+    foreign key (debt_id) references debts (id) on delete cascade
+);
+
+create index reminders_next_at_idx on reminders (next_at);
+
+alter table
+    users
+add
+    column remind boolean default true not null;
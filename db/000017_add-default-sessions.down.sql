@@ -0,0 +1,3 @@
+drop index sessions_chat_id_state_idx;
+
+drop table default_sessions;
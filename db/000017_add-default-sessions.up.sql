@@ -0,0 +1,10 @@
+create table default_sessions (
+    chat_id bigint not null,
+    -- tg_id is 0 for default session of whole chat
+    tg_id bigint not null default 0,
+    session_id uuid not null,
+    primary key (chat_id, tg_id),
+    foreign key (session_id) references sessions (uuid) on delete cascade
+);
+
+create index sessions_chat_id_state_idx on sessions (chat_id, state);
@@ -0,0 +1,6 @@
+drop index sessions_chat_id_finished_at_idx;
+
+alter table
+    sessions
+alter
+    column finished_at type date;
@@ -0,0 +1,7 @@
+-- Time of finish is needed to check grace period of reopening
+alter table
+    sessions
+alter
+    column finished_at type timestamptz;
+
+create index sessions_chat_id_finished_at_idx on sessions (chat_id, finished_at);
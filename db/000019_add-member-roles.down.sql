@@ -0,0 +1,7 @@
+alter table
+    chat_settings drop column admins_treasurers;
+
+alter table
+    members drop column role;
+
+drop type member_role_t;
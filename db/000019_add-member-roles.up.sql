@@ -0,0 +1,21 @@
+create type member_role_t as enum ('creator', 'treasurer', 'member', 'observer');
+
+alter table
+    members
+add
+    column role member_role_t default 'member' not null;
+
+update
+    members as M
+set
+    role = 'creator'
+from
+    sessions as S
+where
+    M.session_id = S.uuid
+    and M.user_id = S.creator_id;
+
+alter table
+    chat_settings
+add
+    column admins_treasurers boolean default true not null;
@@ -0,0 +1,7 @@
+alter table
+    chat_settings drop column autofinish_grace_days,
+    drop column autofinish_days;
+
+alter table
+    sessions drop column inactivity_warned_at,
+    drop column last_activity_at;
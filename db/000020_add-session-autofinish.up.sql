@@ -0,0 +1,29 @@
+alter table
+    sessions
+add
+    column last_activity_at timestamptz default current_timestamp not null,
+add
+    column inactivity_warned_at timestamptz;
+
+update
+    sessions as S
+set
+    last_activity_at = coalesce(
+        (
+            select
+                max(C.created_at)
+            from
+                costs as C
+                join members as M on C.member_id = M.id
+            where
+                M.session_id = S.uuid
+        ),
+        S.started_at
+    );
+
+alter table
+    chat_settings
+add
+    column autofinish_days integer default 0 not null check (autofinish_days >= 0),
+add
+    column autofinish_grace_days integer default 2 not null check (autofinish_grace_days > 0);
@@ -0,0 +1,17 @@
+update
+    sessions
+set
+    state = 'closed'
+where
+    state = 'settling';
+
+alter type session_state_t rename to session_state_old_t;
+
+create type session_state_t as enum ('active', 'closed');
+
+alter table
+    sessions
+alter column
+    state type session_state_t using state::text::session_state_t;
+
+drop type session_state_old_t;
@@ -0,0 +1 @@
+alter type session_state_t add value 'settling' before 'closed';
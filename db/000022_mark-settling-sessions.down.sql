@@ -0,0 +1,6 @@
+update
+    sessions
+set
+    state = 'closed'
+where
+    state = 'settling';
@@ -0,0 +1,16 @@
+update
+    sessions as S
+set
+    state = 'settling'
+where
+    S.state = 'closed'
+    and exists (
+        select
+            1
+        from
+            debts as D
+            join members as M on D.creditor_id = M.id
+        where
+            M.session_id = S.uuid
+            and D.status = 'pending'
+    );
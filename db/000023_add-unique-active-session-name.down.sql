@@ -0,0 +1 @@
+drop index sessions_active_name_idx;
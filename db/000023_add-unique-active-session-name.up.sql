@@ -0,0 +1,30 @@
+-- Duplicates left by concurrent /start are renamed, so none of them loses its expenses.
+-- Suffix is taken from uuid, so new name doesn't collide with names like session_2 chosen by users
+with duplicates as (
+    select
+        uuid,
+        row_number() over (
+            partition by chat_id,
+            lower(session_name)
+            order by
+                started_at,
+                uuid
+        ) as number
+    from
+        sessions
+    where
+        state = 'active'
+)
+update
+    sessions as S
+set
+    session_name = S.session_name || '_' || left(S.uuid::text, 8)
+from
+    duplicates as D
+where
+    S.uuid = D.uuid
+    and D.number > 1;
+
+create unique index sessions_active_name_idx on sessions (chat_id, lower(session_name))
+where
+    state = 'active';
@@ -0,0 +1,2 @@
+alter table
+    members drop constraint members_session_id_user_id_key;
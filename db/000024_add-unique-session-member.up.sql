@@ -0,0 +1,106 @@
+-- Members added twice by concurrent updates are merged into the oldest one
+create temporary table member_duplicates as
+select
+    M.id,
+    K.keep_id,
+    K.role
+from
+    members as M
+    join (
+        select
+            session_id,
+            user_id,
+            min(id) as keep_id,
+            min(role) as role
+        from
+            members
+        group by
+            session_id,
+            user_id
+        having
+            count(*) > 1
+    ) as K on M.session_id = K.session_id
+    and M.user_id = K.user_id
+where
+    M.id <> K.keep_id;
+
+update
+    costs as C
+set
+    member_id = D.keep_id
+from
+    member_duplicates as D
+where
+    C.member_id = D.id;
+
+update
+    pot_contributions as P
+set
+    member_id = D.keep_id
+from
+    member_duplicates as D
+where
+    P.member_id = D.id;
+
+update
+    transfers as T
+set
+    sender_id = D.keep_id
+from
+    member_duplicates as D
+where
+    T.sender_id = D.id;
+
+update
+    transfers as T
+set
+    recipient_id = D.keep_id
+from
+    member_duplicates as D
+where
+    T.recipient_id = D.id;
+
+update
+    debts as DB
+set
+    creditor_id = D.keep_id
+from
+    member_duplicates as D
+where
+    DB.creditor_id = D.id;
+
+update
+    debts as DB
+set
+    debtor_id = D.keep_id
+from
+    member_duplicates as D
+where
+    DB.debtor_id = D.id;
+
+delete from
+    debts
+where
+    creditor_id = debtor_id;
+
+-- The highest role of duplicates is kept, roles are ordered from creator to observer
+update
+    members as M
+set
+    role = D.role
+from
+    member_duplicates as D
+where
+    M.id = D.keep_id;
+
+delete from
+    members as M using member_duplicates as D
+where
+    M.id = D.id;
+
+drop table member_duplicates;
+
+alter table
+    members
+add
+    constraint members_session_id_user_id_key unique (session_id, user_id);
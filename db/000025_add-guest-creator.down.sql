@@ -0,0 +1,3 @@
+alter table
+    users drop constraint "users_guest_creator_id_fkey",
+    drop column guest_creator_id;
@@ -0,0 +1,8 @@
+alter table
+    users
+add
+    column guest_creator_id bigint,
+add
+    constraint "users_guest_creator_id_fkey" foreign key (guest_creator_id) references users (id) on delete
+set
+    null;
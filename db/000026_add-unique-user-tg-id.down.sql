@@ -0,0 +1,4 @@
+drop index users_tg_id_idx;
+
+alter table
+    requisites drop column encrypted_for;
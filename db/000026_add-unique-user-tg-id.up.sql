@@ -0,0 +1,218 @@
+-- Users created twice by concurrent updates are merged into the oldest one
+create temporary table user_duplicates as
+select
+    U.id,
+    K.keep_id
+from
+    users as U
+    join (
+        select
+            tg_id,
+            min(id) as keep_id
+        from
+            users
+        where
+            tg_id is not null
+        group by
+            tg_id
+        having
+            count(*) > 1
+    ) as K on U.tg_id = K.tg_id
+where
+    U.id <> K.keep_id;
+
+update
+    sessions as S
+set
+    creator_id = D.keep_id
+from
+    user_duplicates as D
+where
+    S.creator_id = D.id;
+
+update
+    user_profile_history as H
+set
+    user_id = D.keep_id
+from
+    user_duplicates as D
+where
+    H.user_id = D.id;
+
+-- Requisite value is encrypted for its owner, so previous owner is kept for decryption until -reencrypt.
+-- Default requisite of the oldest user stays the only default one
+alter table
+    requisites
+add
+    column encrypted_for bigint;
+
+update
+    requisites as R
+set
+    encrypted_for = R.user_id,
+    user_id = D.keep_id,
+    is_default = false
+from
+    user_duplicates as D
+where
+    R.user_id = D.id;
+
+update
+    nettings as N
+set
+    first_user_id = D.keep_id
+from
+    user_duplicates as D
+where
+    N.first_user_id = D.id;
+
+update
+    nettings as N
+set
+    second_user_id = D.keep_id
+from
+    user_duplicates as D
+where
+    N.second_user_id = D.id;
+
+delete from
+    nettings
+where
+    first_user_id = second_user_id;
+
+update
+    users as U
+set
+    guest_creator_id = D.keep_id
+from
+    user_duplicates as D
+where
+    U.guest_creator_id = D.id;
+
+-- Members of duplicates are moved to the oldest user, then members of one session are merged like in 000024
+alter table
+    members drop constraint members_session_id_user_id_key;
+
+update
+    members as M
+set
+    user_id = D.keep_id
+from
+    user_duplicates as D
+where
+    M.user_id = D.id;
+
+create temporary table member_duplicates as
+select
+    M.id,
+    K.keep_id,
+    K.role
+from
+    members as M
+    join (
+        select
+            session_id,
+            user_id,
+            min(id) as keep_id,
+            min(role) as role
+        from
+            members
+        group by
+            session_id,
+            user_id
+        having
+            count(*) > 1
+    ) as K on M.session_id = K.session_id
+    and M.user_id = K.user_id
+where
+    M.id <> K.keep_id;
+
+update
+    costs as C
+set
+    member_id = D.keep_id
+from
+    member_duplicates as D
+where
+    C.member_id = D.id;
+
+update
+    pot_contributions as P
+set
+    member_id = D.keep_id
+from
+    member_duplicates as D
+where
+    P.member_id = D.id;
+
+update
+    transfers as T
+set
+    sender_id = D.keep_id
+from
+    member_duplicates as D
+where
+    T.sender_id = D.id;
+
+update
+    transfers as T
+set
+    recipient_id = D.keep_id
+from
+    member_duplicates as D
+where
+    T.recipient_id = D.id;
+
+update
+    debts as DB
+set
+    creditor_id = D.keep_id
+from
+    member_duplicates as D
+where
+    DB.creditor_id = D.id;
+
+update
+    debts as DB
+set
+    debtor_id = D.keep_id
+from
+    member_duplicates as D
+where
+    DB.debtor_id = D.id;
+
+delete from
+    debts
+where
+    creditor_id = debtor_id;
+
+update
+    members as M
+set
+    role = D.role
+from
+    member_duplicates as D
+where
+    M.id = D.keep_id;
+
+delete from
+    members as M using member_duplicates as D
+where
+    M.id = D.id;
+
+drop table member_duplicates;
+
+alter table
+    members
+add
+    constraint members_session_id_user_id_key unique (session_id, user_id);
+
+delete from
+    users as U using user_duplicates as D
+where
+    U.id = D.id;
+
+drop table user_duplicates;
+
+-- Guests have no telegram id, null values don't conflict
+create unique index users_tg_id_idx on users (tg_id);
@@ -4,12 +4,10 @@ go 1.20
 
 require (
 	github.com/BurntSushi/toml v1.2.1
+	github.com/google/uuid v1.3.0
+	github.com/lib/pq v1.10.9
 	github.com/sirupsen/logrus v1.9.0
 	gopkg.in/telebot.v3 v3.1.3
 )
 
-require (
-	github.com/google/uuid v1.3.0 // indirect
-	github.com/lib/pq v1.10.9 // indirect
-	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
-)
+require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
@@ -6,7 +6,29 @@ type GroupHandler interface {
 	Great(c tele.Context) error
 	StartSession(c tele.Context) error
 	AddExpense(c tele.Context) error
+	AddTransfer(c tele.Context) error
+	AddContribution(c tele.Context) error
+	GetPot(c tele.Context) error
 	GetCosts(c tele.Context) error
 	GetDebts(c tele.Context) error
 	FinishSession(c tele.Context) error
+	Reopen(c tele.Context) error
+	History(c tele.Context) error
+	Show(c tele.Context) error
+	JoinSession(c tele.Context) error
+	Invite(c tele.Context) error
+	Remind(c tele.Context) error
+	AutoFinish(c tele.Context) error
+	Switch(c tele.Context) error
+	AddMember(c tele.Context) error
+	LeaveSession(c tele.Context) error
+	KickMember(c tele.Context) error
+	Role(c tele.Context) error
+	GetMembers(c tele.Context) error
+	JoinSessionByButton(c tele.Context) error
+	PayByQR(c tele.Context) error
+	AddGuest(c tele.Context) error
+	AddGuestExpense(c tele.Context) error
+	MergeGuest(c tele.Context) error
+	EditExpense(c tele.Context) error
 }
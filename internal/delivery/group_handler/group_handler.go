@@ -1,23 +1,69 @@
 package group_handler
 
 import (
+	"bytes"
 	"fmt"
+	"html"
 	"strconv"
+	"strings"
 
 	"collector-telegram-bot/internal"
 	"collector-telegram-bot/internal/dto"
 	"collector-telegram-bot/internal/models"
+	"collector-telegram-bot/internal/qrcode"
 	"collector-telegram-bot/internal/usecase"
 	"collector-telegram-bot/internal/usecase/group_usecase"
 
+	"github.com/google/uuid"
 	tele "gopkg.in/telebot.v3"
 )
 
 const (
 	bigSeparateString   = "===========\n"
 	smallSeparateString = "----------\n"
+
+	potArg    = "pot"
+	noGuest   = ""
+	noSession = ""
+
+	// historyLimit is number of sessions shown in /history, older ones are still available by /show
+	historyLimit = 30
+
+	qrScale       = 8
+	qrDataDivider = ":"
+
+	onArg          = "on"
+	offArg         = "off"
+	remindGroupArg = "group"
+	remindDMArg    = "dm"
+
+	adminsArg = "admins"
+
+	sessionFlag               = "--session"
+	personalArg               = "me"
+	currentSessionMark        = " ← текущая"
+	observerMsg               = "Наблюдатель не может записывать траты и переводы!"
+	settlingMark              = ", идут расчеты"
+	chatSettingsPermissionMsg = "Настройки чата меняют администраторы чата, создатели и казначеи активных сессий!"
+	finishPermissionMsg       = "Завершить сессию может только создатель или казначей сессии!"
+	settlingMsg               = "Сессия закроется, когда все долги будут отмечены возвращенными через /paid\n"
+	sessionNotChosenMsg       = "В чате несколько сессий: выбери нужную командой /switch <Название> " +
+		"или добавь к команде --session <Название>"
 )
 
+// roleTitles are shown next to members, ordinary members have no title.
+var roleTitles = map[string]string{
+	models.RoleCreator:   " (создатель)",
+	models.RoleTreasurer: " (казначей)",
+	models.RoleObserver:  " (наблюдатель)",
+}
+
+// JoinBtn is shown under session start message, its data is session uuid.
+var JoinBtn = tele.Btn{Unique: "join_session", Text: "Участвую"}
+
+// PayQRBtn is shown under debts for every debt, its data is "<session uuid>:<creditor id>:<debtor id>".
+var PayQRBtn = tele.Btn{Unique: "pay_qr"}
+
 type GroupTgHandler struct {
 	log     internal.Logger
 	usecase group_usecase.GroupUsecase
@@ -40,8 +86,7 @@ func (h *GroupTgHandler) StartSession(c tele.Context) error {
 	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
 
 	chatID := c.Chat().ID
-	userID := c.Message().Sender.ID
-	username := c.Message().Sender.Username
+	sender := c.Message().Sender
 
 	if len(c.Args()) == 0 {
 		return c.Send("Пожалуйста, добавьте название сессии после команды!")
@@ -49,53 +94,162 @@ func (h *GroupTgHandler) StartSession(c tele.Context) error {
 
 	sessionName := c.Args()[0]
 	info := dto.CreateSessionDTO{
-		UserID:      userID,
+		UserID:      sender.ID,
 		ChatID:      chatID,
-		Username:    username,
+		ChatTitle:   c.Chat().Title,
+		Username:    sender.Username,
+		FirstName:   sender.FirstName,
+		LastName:    sender.LastName,
 		SessionName: sessionName,
+		Pot:         len(c.Args()) > 1 && c.Args()[1] == potArg,
 	}
 
-	err = h.usecase.CreateSession(info)
+	session, err := h.usecase.CreateSession(info)
 	switch {
 	case err == usecase.SessionExistsErr:
-		return c.Send("Сессия уже существует – новую создать нельзя. :(")
+		return c.Send("Сессия с таким названием уже идет – выбери другое название. :(")
 	case err != nil:
 		h.log.Warnf("Create session err: %v", err)
 		return c.Send("Извини, технические проблемы")
 	default:
-		responseText = fmt.Sprintf("Сессия '%s' успешно создана!", sessionName)
+		creator := &models.User{
+			TgID:      sender.ID,
+			Username:  sender.Username,
+			FirstName: sender.FirstName,
+			LastName:  sender.LastName,
+		}
+		responseText = h.createOutputStart(session, []*models.SessionMember{{User: creator, Role: models.RoleCreator}})
 	}
-	return c.Send(responseText)
+	return c.Send(responseText, h.joinMarkup(session), tele.ModeHTML)
+}
+
+func (h *GroupTgHandler) joinMarkup(session *models.Session) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	btn := JoinBtn
+	btn.Data = session.UUID.String()
+	markup.Inline(markup.Row(btn))
+	return markup
+}
+
+func (h *GroupTgHandler) createOutputStart(session *models.Session, members []*models.SessionMember) string {
+	var responseText string
+	if session.Mode == models.SessionModePot {
+		responseText = fmt.Sprintf("Сессия '%s' с общим котлом успешно создана!\n"+
+			"Взносы в котел: /contribute &lt;Сумма&gt;\n", html.EscapeString(session.SessionName))
+	} else {
+		responseText = fmt.Sprintf("Сессия '%s' успешно создана!\n", html.EscapeString(session.SessionName))
+	}
+	responseText += "Участники:\n" + h.createOutputMembers(members)
+	return responseText
+}
+
+// JoinSessionByButton adds user who pressed JoinBtn to session and
+// refreshes participants list in start message.
+func (h *GroupTgHandler) JoinSessionByButton(c tele.Context) error {
+	h.log.Infof("Recieved callback from %s, data = %s", c.Sender().Username, c.Data())
+
+	sessionUUID, err := uuid.Parse(c.Data())
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "Некорректная кнопка"})
+	}
+
+	err = h.usecase.JoinSession(dto.JoinSessionDTO{
+		ChatID:      c.Chat().ID,
+		UserID:      c.Sender().ID,
+		Username:    c.Sender().Username,
+		FirstName:   c.Sender().FirstName,
+		LastName:    c.Sender().LastName,
+		SessionUUID: sessionUUID,
+	})
+	switch err {
+	case nil:
+	case usecase.SessionNotExistsErr:
+		return c.Respond(&tele.CallbackResponse{Text: "Сессия уже завершена"})
+	case usecase.AlreadyMemberErr:
+		return c.Respond(&tele.CallbackResponse{Text: "Ты уже участвуешь в сессии!"})
+	default:
+		h.log.Warnf("Join session by button err: %v", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Извини, технические проблемы :("})
+	}
+
+	session, members, err := h.usecase.GetMembers(dto.GetMembersDTO{
+		ChatID:      c.Chat().ID,
+		SessionUUID: sessionUUID,
+	})
+	if err != nil {
+		h.log.Warnf("Get members err: %v", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Теперь ты участвуешь в сессии!"})
+	}
+
+	if err = c.Edit(h.createOutputStart(session, members), h.joinMarkup(session), tele.ModeHTML); err != nil {
+		h.log.Warnf("Edit start message err: %v", err)
+	}
+	return c.Respond(&tele.CallbackResponse{Text: "Теперь ты участвуешь в сессии!"})
 }
 
 func (h *GroupTgHandler) AddExpense(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	sessionName, args := splitSessionArg(c.Args())
+	fromPot := len(args) == 3 && args[2] == potArg
+	if len(args) != 2 && !fromPot {
+		return c.Send("Пожалуйста, укажи так: /add <Название продукта> <Цена> [pot]!")
+	}
+
+	return h.addExpense(c, args[0], args[1], fromPot, noGuest, sessionName)
+}
+
+// AddGuestExpense records expense paid by guest: /add_for <Гость> <Название> <Цена>.
+func (h *GroupTgHandler) AddGuestExpense(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	sessionName, args := splitSessionArg(c.Args())
+	fromPot := len(args) == 4 && args[3] == potArg
+	if len(args) != 3 && !fromPot {
+		return c.Send("Пожалуйста, укажи так: /add_for <Гость> <Название продукта> <Цена> [pot]!")
+	}
+
+	return h.addExpense(c, args[1], args[2], fromPot, args[0], sessionName)
+}
+
+func (h *GroupTgHandler) addExpense(c tele.Context, productName string, costArg string, fromPot bool,
+	guestName string, sessionName string) error {
 	var (
 		err          error
 		responseText string
 	)
-	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
-
-	if len(c.Args()) != 2 {
-		return c.Send("Пожалуйста, укажи так: /add <Название продукта> <Цена>!")
-	}
 
-	productName := c.Args()[0]
-	cost, costErr := strconv.Atoi(c.Args()[1])
+	cost, costErr := strconv.Atoi(costArg)
 	if costErr != nil {
 		return c.Send("Цена должна быть целым числом!")
 	}
 	info := dto.AddExpenseDTO{
-		ChatID:   c.Chat().ID,
-		Product:  productName,
-		Cost:     cost,
-		UserID:   c.Message().Sender.ID,
-		Username: c.Message().Sender.Username,
+		ChatID:      c.Chat().ID,
+		Product:     productName,
+		Cost:        cost,
+		UserID:      c.Message().Sender.ID,
+		Username:    c.Message().Sender.Username,
+		FirstName:   c.Message().Sender.FirstName,
+		LastName:    c.Message().Sender.LastName,
+		FromPot:     fromPot,
+		GuestName:   guestName,
+		SessionName: sessionName,
 	}
 
 	err = h.usecase.AddExpenseToSession(info)
 	switch err {
 	case usecase.SessionNotExistsErr:
-		responseText = fmt.Sprintf("Сессия уже существует – новую создать нельзя!")
+		responseText = "Для выполнения этой команды нужно начать сессию!"
+	case usecase.SessionNotChosenErr:
+		responseText = sessionNotChosenMsg
+	case usecase.NotPotSessionErr:
+		responseText = "В этой сессии нет общего котла!"
+	case usecase.ObserverErr:
+		responseText = observerMsg
+	case usecase.PotInsufficientErr:
+		responseText = "В котле недостаточно денег для этой траты :("
+	case usecase.GuestNotExistsErr:
+		responseText = fmt.Sprintf("Гостя %s нет, добавь его командой /guest %s", info.GuestName, info.GuestName)
 	case nil:
 		responseText = fmt.Sprintf("Добавлена новая трата!")
 	default:
@@ -105,12 +259,203 @@ func (h *GroupTgHandler) AddExpense(c tele.Context) error {
 	return c.Send(responseText)
 }
 
+// EditExpense changes expense by its number from /count: /edit <Номер> <Название> <Цена>.
+func (h *GroupTgHandler) EditExpense(c tele.Context) error {
+	var responseText string
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	sessionName, args := splitSessionArg(c.Args())
+	if len(args) != 3 {
+		return c.Send("Пожалуйста, укажи так: /edit <Номер траты из /count> <Название продукта> <Цена>!")
+	}
+	costID, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return c.Send("Номер траты должен быть числом из /count!")
+	}
+	cost, err := strconv.Atoi(args[2])
+	if err != nil || cost <= 0 {
+		return c.Send("Цена должна быть целым положительным числом!")
+	}
+
+	err = h.usecase.EditExpense(dto.EditExpenseDTO{
+		ChatID:      c.Chat().ID,
+		UserID:      c.Message().Sender.ID,
+		Username:    c.Message().Sender.Username,
+		FirstName:   c.Message().Sender.FirstName,
+		LastName:    c.Message().Sender.LastName,
+		CostID:      costID,
+		Product:     args[1],
+		Cost:        cost,
+		SessionName: sessionName,
+		IsAdmin:     h.isChatAdmin(c),
+	})
+	switch err {
+	case nil:
+		responseText = fmt.Sprintf("Трата №%d изменена: «%s» на %d рублей!", costID, args[1], cost)
+	case usecase.SessionNotExistsErr:
+		responseText = "Для выполнения этой команды нужно начать сессию!"
+	case usecase.SessionNotChosenErr:
+		responseText = sessionNotChosenMsg
+	case usecase.CostNotExistsErr:
+		responseText = "В сессии нет траты с таким номером, посмотри /count"
+	case usecase.NoPermissionErr:
+		responseText = "Изменить чужую трату может только создатель или казначей сессии!"
+	case usecase.PotInsufficientErr:
+		responseText = "В котле недостаточно денег для этой траты :("
+	default:
+		h.log.Warnf("Edit expense err: %v", err)
+		responseText = "Извини, технические проблемы :("
+	}
+	return c.Send(responseText)
+}
+
+func (h *GroupTgHandler) AddTransfer(c tele.Context) error {
+	var (
+		err          error
+		responseText string
+	)
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	recipient, args, ok := parseMemberArgs(c)
+	if !ok || len(args) != 1 {
+		return c.Send("Пожалуйста, укажи так: /transfer @<Получатель> <Сумма>!")
+	}
+	money, moneyErr := strconv.Atoi(args[0])
+	if moneyErr != nil || money <= 0 {
+		return c.Send("Сумма должна быть целым положительным числом!")
+	}
+	info := dto.AddTransferDTO{
+		ChatID:            c.Chat().ID,
+		UserID:            c.Message().Sender.ID,
+		Username:          c.Message().Sender.Username,
+		FirstName:         c.Message().Sender.FirstName,
+		LastName:          c.Message().Sender.LastName,
+		RecipientUsername: recipient.username,
+		RecipientTgID:     recipient.tgID,
+		Money:             money,
+		SessionName:       sessionArg(c),
+	}
+
+	err = h.usecase.AddTransferToSession(info)
+	switch err {
+	case usecase.SessionNotExistsErr:
+		responseText = "Для выполнения этой команды нужно начать сессию!"
+	case usecase.SessionNotChosenErr:
+		responseText = sessionNotChosenMsg
+	case usecase.UserNotExistsErr:
+		responseText = fmt.Sprintf("Пользователь %s еще не писал боту :(", recipient.name)
+	case usecase.ObserverErr:
+		responseText = observerMsg
+	case usecase.SelfTransferErr:
+		responseText = "Нельзя перевести деньги самому себе!"
+	case nil:
+		responseText = fmt.Sprintf("Перевод %s на %d рублей записан!", recipient.name, money)
+	default:
+		h.log.Warnf("Add transfer err: %v", err)
+		responseText = "Извини, технические проблемы :("
+	}
+	return c.Send(responseText)
+}
+
+func (h *GroupTgHandler) AddContribution(c tele.Context) error {
+	var (
+		err          error
+		responseText string
+	)
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	sessionName, args := splitSessionArg(c.Args())
+	if len(args) != 1 {
+		return c.Send("Пожалуйста, укажи так: /contribute <Сумма>!")
+	}
+
+	money, moneyErr := strconv.Atoi(args[0])
+	if moneyErr != nil || money <= 0 {
+		return c.Send("Сумма должна быть целым положительным числом!")
+	}
+	info := dto.AddContributionDTO{
+		ChatID:      c.Chat().ID,
+		UserID:      c.Message().Sender.ID,
+		Username:    c.Message().Sender.Username,
+		FirstName:   c.Message().Sender.FirstName,
+		LastName:    c.Message().Sender.LastName,
+		Money:       money,
+		SessionName: sessionName,
+	}
+
+	err = h.usecase.AddContributionToPot(info)
+	switch err {
+	case usecase.SessionNotExistsErr:
+		responseText = "Для выполнения этой команды нужно начать сессию!"
+	case usecase.SessionNotChosenErr:
+		responseText = sessionNotChosenMsg
+	case usecase.ObserverErr:
+		responseText = observerMsg
+	case usecase.NotPotSessionErr:
+		responseText = "В этой сессии нет общего котла!"
+	case nil:
+		responseText = fmt.Sprintf("Взнос в котел на %d рублей записан!", money)
+	default:
+		h.log.Warnf("Add contribution err: %v", err)
+		responseText = "Извини, технические проблемы :("
+	}
+	return c.Send(responseText)
+}
+
+func (h *GroupTgHandler) GetPot(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	pot, err := h.usecase.GetPot(dto.GetPotDTO{
+		ChatID:      c.Chat().ID,
+		UserID:      c.Message().Sender.ID,
+		SessionName: sessionArg(c),
+	})
+	switch err {
+	case usecase.SessionNotExistsErr:
+		return c.Send("Для выполнения этой команды нужно начать сессию!")
+	case usecase.SessionNotChosenErr:
+		return c.Send(sessionNotChosenMsg)
+	case usecase.NotPotSessionErr:
+		return c.Send("В этой сессии нет общего котла!")
+	case nil:
+	default:
+		h.log.Warnf("Get pot err: %v", err)
+		return c.Send("Извини, техническая ошибка :(")
+	}
+
+	return c.Send(h.createOutputPot(pot), tele.ModeHTML)
+}
+
+func (h *GroupTgHandler) createOutputPot(pot *models.PotState) string {
+	responseText := fmt.Sprintf("В котле: %d рублей\n", pot.Balance)
+	responseText += fmt.Sprintf("Внесено: %d рублей, потрачено: %d рублей\n", pot.Contributed, pot.Spent)
+	responseText += bigSeparateString
+
+	for _, balance := range pot.Members {
+		responseText += fmt.Sprintf("Пользователь %s \n", balance.User.Mention())
+		responseText += fmt.Sprintf("Внес: %d рублей, потратил сам: %d рублей, доля: %d рублей\n",
+			balance.Contributed, balance.Spent, balance.Share)
+		switch {
+		case balance.Balance > 0:
+			responseText += fmt.Sprintf("Вернуть из котла: %d рублей\n", balance.Balance)
+		case balance.Balance < 0:
+			responseText += fmt.Sprintf("Доплатить в котел: %d рублей\n", -balance.Balance)
+		default:
+			responseText += "В расчете с котлом\n"
+		}
+		responseText += smallSeparateString
+	}
+	return responseText
+}
+
 func (h *GroupTgHandler) GetCosts(c tele.Context) error {
 	var responseText string
 	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
 
 	info := dto.GetCostsDTO{
-		ChatID: c.Chat().ID,
+		ChatID:      c.Chat().ID,
+		UserID:      c.Message().Sender.ID,
+		SessionName: sessionArg(c),
 	}
 
 	allCosts, err := h.usecase.GetAllExpenses(info)
@@ -119,32 +464,54 @@ func (h *GroupTgHandler) GetCosts(c tele.Context) error {
 		return c.Send("Для выполнения этой команды нужно начать сессию!")
 	}
 
+	if err == usecase.SessionNotChosenErr {
+		return c.Send(sessionNotChosenMsg)
+	}
+
 	if err != nil {
 		h.log.Warnf("Get costs err: %v", err)
 		return c.Send("Извини, техническая ошибка :(")
 	}
 
-	if len(allCosts) == 0 {
+	allTransfers, err := h.usecase.GetAllTransfers(dto.GetTransfersDTO(info))
+	if err != nil {
+		h.log.Warnf("Get transfers err: %v", err)
+		return c.Send("Извини, техническая ошибка :(")
+	}
+
+	if len(allCosts) == 0 && len(allTransfers) == 0 {
 		return c.Send("Трат пока еще не было :(")
 	}
 
 	responseText += "Все траты на текущий момент\n" + bigSeparateString
-	responseText += h.createOutput(allCosts)
+	responseText += h.createOutput(allCosts, true)
 
-	return c.Send(responseText)
+	if len(allTransfers) != 0 {
+		responseText += "Переводы\n" + smallSeparateString
+		responseText += h.createOutputTransfers(allTransfers)
+	}
+
+	return c.Send(responseText, tele.ModeHTML)
 }
 
-func (h *GroupTgHandler) createOutput(allCosts map[string]models.AllUserCosts) string {
+// createOutput lists expenses of members, numbered expenses of active session can be edited.
+func (h *GroupTgHandler) createOutput(allCosts map[uint64]models.AllUserCosts, numbered bool) string {
 	var responseText string
-	for username, allUserCosts := range allCosts {
-		responseText += fmt.Sprintf("Пользователь @%s \n", username)
+	for _, allUserCosts := range allCosts {
+		responseText += fmt.Sprintf("Пользователь %s \n", allUserCosts.User.Mention())
 		responseText += fmt.Sprintf("Общая сумма: %d рублей\n"+smallSeparateString, allUserCosts.Sum)
 
 		// Sorting for pretty output
 		allUserCosts.SortByCost()
 
 		for _, cost := range allUserCosts.Costs {
-			responseText += fmt.Sprintf("%s - %d рублей \n", cost.Description, cost.Money)
+			if cost.FromPot {
+				responseText += fmt.Sprintf("%s - %d рублей (из котла)%s \n", html.EscapeString(cost.Description),
+					cost.Money, costNumber(cost, numbered))
+				continue
+			}
+			responseText += fmt.Sprintf("%s - %d рублей%s \n", html.EscapeString(cost.Description), cost.Money,
+				costNumber(cost, numbered))
 		}
 
 		responseText += bigSeparateString
@@ -152,26 +519,76 @@ func (h *GroupTgHandler) createOutput(allCosts map[string]models.AllUserCosts) s
 	return responseText
 }
 
+// costNumber is shown next to expense for /edit.
+func costNumber(cost models.UserCost, numbered bool) string {
+	if !numbered {
+		return ""
+	}
+	return fmt.Sprintf(" [№%d]", cost.ID)
+}
+
+func (h *GroupTgHandler) createOutputTransfers(allTransfers []*models.UserTransfer) string {
+	var responseText string
+	for _, transfer := range allTransfers {
+		responseText += fmt.Sprintf("%s → %s - %d рублей \n", transfer.Sender.Mention(), transfer.Recipient.Mention(),
+			transfer.Money)
+	}
+	return responseText + bigSeparateString
+}
+
 func (h *GroupTgHandler) FinishSession(c tele.Context) error {
 	var responseText string
 	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
 
 	info := dto.GetCostsDTO{
-		ChatID: c.Chat().ID,
+		ChatID:      c.Chat().ID,
+		UserID:      c.Message().Sender.ID,
+		SessionName: sessionArg(c),
+	}
+	finishInfo := dto.FinishSessionDTO{
+		ChatID:      info.ChatID,
+		UserID:      info.UserID,
+		SessionName: info.SessionName,
+		IsAdmin:     h.isChatAdmin(c),
 	}
 
-	allCosts, err := h.usecase.GetAllExpenses(info)
-
-	if err == usecase.SessionNotExistsErr {
+	// Permission is checked before settlement, which reveals requisites of creditors
+	err := h.usecase.CanFinish(finishInfo)
+	switch err {
+	case nil:
+	case usecase.SessionNotExistsErr:
 		return c.Send("Нельзя закончить сессию, если ее еще нет!")
+	case usecase.SessionNotChosenErr:
+		return c.Send(sessionNotChosenMsg)
+	case usecase.NoPermissionErr:
+		return c.Send(finishPermissionMsg)
+	default:
+		h.log.Warnf("Finish session err: %v", err)
+		return c.Send("Извини, технические проблемы")
 	}
 
+	allCosts, err := h.usecase.GetAllExpenses(info)
 	if err != nil {
 		h.log.Warnf("Finish session err: %v", err)
 		return c.Send("Извини, технические проблемы")
 	}
 
-	err = h.usecase.FinishSession(dto.FinishSessionDTO{ChatID: c.Chat().ID})
+	// Settlement is calculated while session is still active
+	settlementText, err := h.createOutputSettlement(dto.GetDebtsDTO(info))
+	if err != nil {
+		h.log.Warnf("Finish session err: %v", err)
+		return c.Send("Извини, технические проблемы")
+	}
+
+	err = h.usecase.FinishSession(finishInfo)
+
+	if err == usecase.NoPermissionErr {
+		return c.Send(finishPermissionMsg)
+	}
+
+	if err == usecase.SessionFinishedErr {
+		return c.Send("Сессия уже завершена!")
+	}
 
 	if err != nil {
 		h.log.Warnf("Finish session err: %v", err)
@@ -179,21 +596,183 @@ func (h *GroupTgHandler) FinishSession(c tele.Context) error {
 	}
 
 	responseText += "Сессия завершена! Итоговые траты: \n" + bigSeparateString
-	responseText += h.createOutput(allCosts)
+	responseText += h.createOutput(allCosts, false)
+	responseText += settlementText
 
-	return c.Send(responseText)
+	return c.Send(responseText, tele.ModeHTML)
+}
+
+// Reopen makes the last finished session of chat active again, it is allowed to session
+// creator and chat administrators for a while after finish.
+func (h *GroupTgHandler) Reopen(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	sender := c.Message().Sender
+	session, err := h.usecase.ReopenSession(dto.ReopenSessionDTO{
+		ChatID:    c.Chat().ID,
+		UserID:    sender.ID,
+		Username:  sender.Username,
+		FirstName: sender.FirstName,
+		LastName:  sender.LastName,
+		IsAdmin:   h.isChatAdmin(c),
+	})
+	switch err {
+	case nil:
+	case usecase.ClosedSessionNotExistsErr:
+		return c.Send("В этом чате еще нет завершенных сессий!")
+	case usecase.ReopenExpiredErr:
+		return c.Send("Последняя сессия завершена слишком давно – вернуть ее уже нельзя :(")
+	case usecase.NoPermissionErr:
+		return c.Send("Это может сделать только создатель или казначей сессии!")
+	case usecase.SessionExistsErr:
+		return c.Send("Уже идет сессия с таким же названием – сначала завершите ее!")
+	case usecase.SessionSettledErr:
+		return c.Send("Часть долгов этой сессии уже возвращена – вернуть сессию нельзя :(")
+	default:
+		h.log.Warnf("Reopen session err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+
+	return c.Send(fmt.Sprintf("Сессия '%s' снова активна! Добавьте забытые траты и завершите ее командой /finish",
+		html.EscapeString(session.SessionName)), tele.ModeHTML)
+}
+
+// History lists finished sessions of chat from newest to oldest.
+func (h *GroupTgHandler) History(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	history, err := h.usecase.GetHistory(dto.GetHistoryDTO{ChatID: c.Chat().ID})
+	if err != nil {
+		h.log.Warnf("Get history err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+	if len(history) == 0 {
+		return c.Send("В этом чате еще нет завершенных сессий!")
+	}
+
+	responseText := "Завершенные сессии чата\n" + bigSeparateString
+	for i, archived := range history {
+		if i == historyLimit {
+			responseText += fmt.Sprintf("... и еще %d\n", len(history)-historyLimit)
+			break
+		}
+		responseText += fmt.Sprintf("%d. %s (%s – %s): %d рублей, участников: %d", i+1,
+			html.EscapeString(archived.Session.SessionName), archived.Session.StartedAt, archived.Session.FinishedAt,
+			archived.Total, archived.Members)
+		if archived.Session.State == models.SessionSettling {
+			responseText += settlingMark
+		}
+		responseText += "\n"
+	}
+	responseText += bigSeparateString + "Итоги сессии: /show &lt;Номер или название&gt;"
+	return c.Send(responseText, tele.ModeHTML)
+}
+
+// Show reprints final costs and debts of finished session: /show <Номер или название>.
+func (h *GroupTgHandler) Show(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	if len(c.Args()) != 1 {
+		return c.Send("Пожалуйста, укажи так: /show <Номер или название>!")
+	}
+
+	info := dto.ShowSessionDTO{ChatID: c.Chat().ID}
+	if number, err := strconv.Atoi(c.Args()[0]); err == nil {
+		info.Number = number
+	} else {
+		info.SessionName = c.Args()[0]
+	}
+
+	archive, err := h.usecase.ShowSession(info)
+	switch err {
+	case nil:
+	case usecase.ClosedSessionNotExistsErr:
+		return c.Send("Такой завершенной сессии нет, список: /history")
+	default:
+		h.log.Warnf("Show session err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+
+	return c.Send(h.createOutputArchive(archive), tele.ModeHTML)
+}
+
+func (h *GroupTgHandler) createOutputArchive(archive *models.SessionArchive) string {
+	responseText := fmt.Sprintf("Сессия '%s' (%s – %s)\n", html.EscapeString(archive.Session.SessionName),
+		archive.Session.StartedAt, archive.Session.FinishedAt)
+
+	if len(archive.Costs) == 0 {
+		responseText += "Трат не было\n"
+	} else {
+		responseText += "Итоговые траты\n" + bigSeparateString + h.createOutput(archive.Costs, false)
+	}
+
+	switch {
+	case archive.Pot != nil:
+		responseText += "Расчет с котлом\n" + bigSeparateString + h.createOutputPot(archive.Pot)
+	case len(archive.Debts) == 0:
+		responseText += "Долгов нет\n"
+	default:
+		responseText += "Итоговые долги\n" + bigSeparateString
+		for _, debt := range archive.Debts {
+			responseText += fmt.Sprintf("%s → %s - %d рублей", debt.Debtor.Mention(), debt.Creditor.Mention(),
+				debt.Money)
+			if debt.Status == models.DebtPayed {
+				responseText += " (возвращен)"
+			}
+			responseText += "\n"
+		}
+		if archive.Session.State == models.SessionSettling {
+			responseText += settlingMsg
+		}
+	}
+	return responseText
+}
+
+// isChatAdmin checks that sender is creator or administrator of chat.
+func (h *GroupTgHandler) isChatAdmin(c tele.Context) bool {
+	member, err := c.Bot().ChatMemberOf(c.Chat(), c.Sender())
+	if err != nil {
+		h.log.Warnf("Get chat member err: %v", err)
+		return false
+	}
+	return member.Role == tele.Creator || member.Role == tele.Administrator
+}
+
+// createOutputSettlement returns final debts of session, or pot settlement in pot mode.
+func (h *GroupTgHandler) createOutputSettlement(info dto.GetDebtsDTO) (string, error) {
+	_, allDebts, err := h.usecase.GetAllDebts(info)
+	switch err {
+	case nil:
+	case usecase.PotSessionErr:
+		pot, err := h.usecase.GetPot(dto.GetPotDTO(info))
+		if err != nil {
+			return "", err
+		}
+		return "Расчет с котлом\n" + bigSeparateString + h.createOutputPot(pot), nil
+	default:
+		return "", err
+	}
+
+	if len(allDebts) == 0 {
+		return "Долгов нет\n", nil
+	}
+	return "Итоговые долги\n" + bigSeparateString + h.createOutputDebts(allDebts), nil
 }
 
-func (h *GroupTgHandler) createOutputDebts(allDebts map[string]models.AllUserDebts) string {
+func (h *GroupTgHandler) createOutputDebts(allDebts map[uint64]models.AllUserDebts) string {
 	var responseText string
-	for username, allUserDebts := range allDebts {
-		responseText += fmt.Sprintf("Пользователю @%s \n", username)
+	for _, allUserDebts := range allDebts {
+		responseText += fmt.Sprintf("Пользователю %s \n", allUserDebts.Creditor.Mention())
+		if allUserDebts.Requisite != nil {
+			responseText += fmt.Sprintf("Реквизиты: %s\n",
+				html.EscapeString(allUserDebts.Requisite.Format(allUserDebts.Requisite.Masked())))
+		}
 
 		// Sorting for pretty output
 		allUserDebts.SortByDebt()
 
 		for _, cost := range allUserDebts.Debts {
-			responseText += fmt.Sprintf("@%s - %d рублей \n", cost.DebtorName, cost.Money)
+			responseText += fmt.Sprintf("%s - %d рублей \n", cost.Debtor.Mention(), cost.Money)
 		}
 
 		responseText += bigSeparateString
@@ -206,15 +785,26 @@ func (h *GroupTgHandler) GetDebts(c tele.Context) error {
 	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
 
 	info := dto.GetDebtsDTO{
-		ChatID: c.Chat().ID,
+		ChatID:      c.Chat().ID,
+		UserID:      c.Message().Sender.ID,
+		SessionName: sessionArg(c),
 	}
 
-	allDebts, err := h.usecase.GetAllDebts(info)
+	session, allDebts, err := h.usecase.GetAllDebts(info)
 
 	if err == usecase.SessionNotExistsErr {
 		return c.Send("Для выполнения этой команды нужно начать сессию!")
 	}
 
+	if err == usecase.SessionNotChosenErr {
+		return c.Send(sessionNotChosenMsg)
+	}
+
+	// Members of pot session settle with the pot
+	if err == usecase.PotSessionErr {
+		return h.GetPot(c)
+	}
+
 	if err != nil {
 		h.log.Warnf("Get debts err: %v", err)
 		return c.Send("Извини, техническая ошибка :(")
@@ -227,5 +817,621 @@ func (h *GroupTgHandler) GetDebts(c tele.Context) error {
 	responseText += "Все долги на текущий момент\n" + bigSeparateString
 	responseText += h.createOutputDebts(allDebts)
 
-	return c.Send(responseText)
+	return c.Send(responseText, h.payQRMarkup(session, allDebts), tele.ModeHTML)
+}
+
+// payQRMarkup returns QR code button for every debt, which can be paid to creditor's requisite.
+func (h *GroupTgHandler) payQRMarkup(session *models.Session,
+	allDebts map[uint64]models.AllUserDebts) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	for creditorID, allUserDebts := range allDebts {
+		if allUserDebts.Requisite == nil {
+			continue
+		}
+		for _, debt := range allUserDebts.Debts {
+			// Guests can't press buttons
+			if debt.Debtor.IsGuest() {
+				continue
+			}
+			btn := PayQRBtn
+			btn.Text = fmt.Sprintf("QR: %s → %s", debt.Debtor.DisplayName(), allUserDebts.Creditor.DisplayName())
+			btn.Data = strings.Join([]string{session.UUID.String(), strconv.FormatUint(creditorID, 10),
+				strconv.FormatUint(debt.Debtor.ID, 10)}, qrDataDivider)
+			rows = append(rows, markup.Row(btn))
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	markup.Inline(rows...)
+	return markup
+}
+
+// PayByQR sends QR code for payment of debt to debtor who pressed PayQRBtn.
+// Code contains full requisite, so it is sent to private chat.
+func (h *GroupTgHandler) PayByQR(c tele.Context) error {
+	h.log.Infof("Recieved callback from %s, data = %s", c.Sender().Username, c.Data())
+
+	data := strings.Split(c.Data(), qrDataDivider)
+	if len(data) != 3 {
+		return c.Respond(&tele.CallbackResponse{Text: "Некорректная кнопка"})
+	}
+	sessionUUID, sessionErr := uuid.Parse(data[0])
+	creditorID, creditorErr := strconv.ParseUint(data[1], 10, 64)
+	debtorID, debtorErr := strconv.ParseUint(data[2], 10, 64)
+	if sessionErr != nil || creditorErr != nil || debtorErr != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "Некорректная кнопка"})
+	}
+
+	payment, err := h.usecase.GetPayment(dto.GetPaymentDTO{
+		ChatID:      c.Chat().ID,
+		SessionUUID: sessionUUID,
+		UserID:      c.Sender().ID,
+		CreditorID:  creditorID,
+		DebtorID:    debtorID,
+	})
+	switch err {
+	case nil:
+	case usecase.SessionNotExistsErr:
+		return c.Respond(&tele.CallbackResponse{Text: "Сессия уже завершена"})
+	case usecase.NotDebtorErr:
+		return c.Respond(&tele.CallbackResponse{Text: "Это не твой долг"})
+	case usecase.DebtNotExistsErr:
+		return c.Respond(&tele.CallbackResponse{Text: "Долг уже изменился, запроси /debts еще раз"})
+	case usecase.NoRequisiteErr:
+		return c.Respond(&tele.CallbackResponse{Text: "Получатель удалил реквизиты"})
+	default:
+		h.log.Warnf("Pay by qr err: %v", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Извини, технические проблемы :("})
+	}
+
+	png, err := qrcode.PNG(payment.Payload(), qrScale)
+	if err != nil {
+		h.log.Warnf("Pay by qr err: %v", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Извини, технические проблемы :("})
+	}
+
+	photo := &tele.Photo{
+		File: tele.FromReader(bytes.NewReader(png)),
+		Caption: fmt.Sprintf("Перевод %s - %d рублей\n%s", payment.Creditor.DisplayName(), payment.Money,
+			payment.Requisite.Format(payment.Requisite.Value)),
+	}
+	if _, err = c.Bot().Send(c.Sender(), photo); err != nil {
+		h.log.Warnf("Pay by qr err: %v", err)
+		return c.Respond(&tele.CallbackResponse{
+			Text:      "Не получилось отправить QR-код, сначала напиши боту в личные сообщения",
+			ShowAlert: true,
+		})
+	}
+	return c.Respond(&tele.CallbackResponse{Text: "QR-код отправлен в личные сообщения"})
+}
+
+// memberArg is user pointed in command by @username or, if user has no username,
+// by mention, which contains telegram id.
+type memberArg struct {
+	username string
+	tgID     int64
+	name     string
+}
+
+// parseMemberArgs finds first mentioned user in command and returns arguments after mention.
+func parseMemberArgs(c tele.Context) (memberArg, []string, bool) {
+	msg := c.Message()
+	for _, entity := range msg.Entities {
+		var member memberArg
+		switch entity.Type {
+		case tele.EntityMention:
+			member.name = msg.EntityText(entity)
+			member.username = strings.TrimPrefix(member.name, "@")
+		case tele.EntityTMention:
+			member.name = msg.EntityText(entity)
+			member.username = entity.User.Username
+			member.tgID = entity.User.ID
+		default:
+			continue
+		}
+		_, rest := splitSessionArg(strings.Fields(strings.SplitN(msg.Text, member.name, 2)[1]))
+		return member, rest, true
+	}
+	_, rest := splitSessionArg(c.Args())
+	return memberArg{}, rest, false
+}
+
+// splitSessionArg cuts session selector "--session <Название>" out of command arguments.
+// Some clients replace "--" with long dash, so it is accepted too.
+func splitSessionArg(args []string) (string, []string) {
+	for i, arg := range args {
+		if (arg == sessionFlag || arg == "—session") && i+1 < len(args) {
+			rest := append(append([]string{}, args[:i]...), args[i+2:]...)
+			return args[i+1], rest
+		}
+	}
+	return noSession, args
+}
+
+// sessionArg returns session name from selector of command or empty string.
+func sessionArg(c tele.Context) string {
+	sessionName, _ := splitSessionArg(c.Args())
+	return sessionName
+}
+
+func (h *GroupTgHandler) membershipResponse(err error, successText string) string {
+	switch err {
+	case nil:
+		return successText
+	case usecase.SessionNotExistsErr:
+		return "Для выполнения этой команды нужно начать сессию!"
+	case usecase.SessionNotChosenErr:
+		return sessionNotChosenMsg
+	case usecase.UserNotExistsErr:
+		return "Этот пользователь еще не писал боту :("
+	case usecase.AlreadyMemberErr:
+		return "Уже участвует в сессии!"
+	case usecase.NotMemberErr:
+		return "Не участвует в сессии!"
+	case usecase.NotCreatorErr:
+		return "Это может сделать только создатель сессии!"
+	case usecase.NoPermissionErr:
+		return "Это может сделать только создатель или казначей сессии!"
+	case usecase.CreatorRoleErr:
+		return "Роль создателя сессии изменить нельзя!"
+	case usecase.ObserverErr:
+		return observerMsg
+	case usecase.CreatorLeaveErr:
+		return "Создатель не может покинуть сессию!"
+	case usecase.MemberHasRecordsErr:
+		return "У участника есть траты или переводы в сессии – сначала рассчитайтесь!"
+	case usecase.GuestNotExistsErr:
+		return "Такого гостя нет :("
+	default:
+		h.log.Warnf("Membership err: %v", err)
+		return "Извини, технические проблемы :("
+	}
+}
+
+func (h *GroupTgHandler) JoinSession(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	err := h.usecase.JoinSession(dto.JoinSessionDTO{
+		ChatID:      c.Chat().ID,
+		UserID:      c.Message().Sender.ID,
+		Username:    c.Message().Sender.Username,
+		FirstName:   c.Message().Sender.FirstName,
+		LastName:    c.Message().Sender.LastName,
+		SessionName: sessionArg(c),
+	})
+	return c.Send(h.membershipResponse(err, "Теперь ты участвуешь в сессии!"))
+}
+
+// Invite sends link, which adds user to active session from private chat with bot.
+func (h *GroupTgHandler) Invite(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	token, err := h.usecase.GetInviteToken(dto.GetInviteDTO{
+		ChatID:      c.Chat().ID,
+		UserID:      c.Message().Sender.ID,
+		SessionName: sessionArg(c),
+	})
+	switch err {
+	case nil:
+	case usecase.SessionNotExistsErr:
+		return c.Send("Для выполнения этой команды нужно начать сессию!")
+	case usecase.SessionNotChosenErr:
+		return c.Send(sessionNotChosenMsg)
+	default:
+		h.log.Warnf("Get invite err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+
+	return c.Send(fmt.Sprintf("Ссылка для участия в сессии:\nhttps://t.me/%s?start=%s", c.Bot().Me.Username, token))
+}
+
+// Switch shows active sessions of chat or chooses session for commands without --session:
+// /switch <Название> for whole chat, /switch <Название> me only for sender.
+func (h *GroupTgHandler) Switch(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	args := c.Args()
+	if len(args) == 0 {
+		sessions, current, err := h.usecase.GetChatSessions(dto.GetChatSessionsDTO{
+			ChatID: c.Chat().ID,
+			UserID: c.Message().Sender.ID,
+		})
+		if err != nil {
+			h.log.Warnf("Get chat sessions err: %v", err)
+			return c.Send("Извини, технические проблемы :(")
+		}
+		if len(sessions) == 0 {
+			return c.Send("Для выполнения этой команды нужно начать сессию!")
+		}
+		return c.Send(h.createOutputSessions(sessions, current), tele.ModeHTML)
+	}
+
+	personal := len(args) == 2 && args[1] == personalArg
+	if len(args) != 1 && !personal {
+		return c.Send("Пожалуйста, укажи так: /switch <Название> [me]!")
+	}
+
+	session, err := h.usecase.SwitchSession(dto.SwitchSessionDTO{
+		ChatID:      c.Chat().ID,
+		UserID:      c.Message().Sender.ID,
+		SessionName: args[0],
+		Personal:    personal,
+		IsAdmin:     h.isChatAdmin(c),
+	})
+	switch {
+	case err == usecase.SessionNotExistsErr:
+		return c.Send("Активной сессии с таким названием нет :(")
+	case err == usecase.NoPermissionErr:
+		return c.Send(chatSettingsPermissionMsg + " Для себя сессию можно выбрать так: /switch <Название> me")
+	case err != nil:
+		h.log.Warnf("Switch session err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	case personal:
+		return c.Send(fmt.Sprintf("Твои команды теперь относятся к сессии '%s'",
+			html.EscapeString(session.SessionName)), tele.ModeHTML)
+	default:
+		return c.Send(fmt.Sprintf("Команды чата теперь относятся к сессии '%s'",
+			html.EscapeString(session.SessionName)), tele.ModeHTML)
+	}
+}
+
+func (h *GroupTgHandler) createOutputSessions(sessions []*models.Session, current *models.Session) string {
+	responseText := "Активные сессии чата\n" + bigSeparateString
+	for i, session := range sessions {
+		responseText += fmt.Sprintf("%d. %s (с %s)", i+1, html.EscapeString(session.SessionName), session.StartedAt)
+		if current != nil && current.UUID == session.UUID {
+			responseText += currentSessionMark
+		}
+		responseText += "\n"
+	}
+	responseText += bigSeparateString + "Выбрать: /switch &lt;Название&gt; [me]\n" +
+		"Или добавь к команде --session &lt;Название&gt;"
+	return responseText
+}
+
+// Remind shows or changes reminder settings of chat:
+// /remind [on|off|group|dm|<Дни> [Раз]].
+func (h *GroupTgHandler) Remind(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	settings, err := h.usecase.GetChatSettings(dto.GetChatSettingsDTO{ChatID: c.Chat().ID})
+	if err != nil {
+		h.log.Warnf("Get chat settings err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send(createOutputChatSettings(settings))
+	}
+
+	if !applyRemindArgs(settings, args) {
+		return c.Send("Пожалуйста, укажи так: /remind <on|off|group|dm> или /remind <Дни> [Раз]!")
+	}
+
+	err = h.usecase.SaveChatSettings(dto.SaveChatSettingsDTO{
+		UserID:   c.Message().Sender.ID,
+		IsAdmin:  h.isChatAdmin(c),
+		Settings: settings,
+	})
+	switch err {
+	case nil:
+		return c.Send(createOutputChatSettings(settings))
+	case usecase.NoPermissionErr:
+		return c.Send(chatSettingsPermissionMsg)
+	case usecase.InvalidSettingsErr:
+		return c.Send("Интервал и количество напоминаний должны быть положительными!")
+	default:
+		h.log.Warnf("Save chat settings err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+}
+
+func applyRemindArgs(settings *models.ChatSettings, args []string) bool {
+	switch {
+	case len(args) == 1 && args[0] == onArg:
+		settings.RemindEnabled = true
+	case len(args) == 1 && args[0] == offArg:
+		settings.RemindEnabled = false
+	case len(args) == 1 && args[0] == remindGroupArg:
+		settings.RemindInGroup = true
+	case len(args) == 1 && args[0] == remindDMArg:
+		settings.RemindInGroup = false
+	case len(args) <= 2:
+		days, err := strconv.Atoi(args[0])
+		if err != nil {
+			return false
+		}
+		settings.RemindIntervalDays = days
+		if len(args) == 2 {
+			if settings.RemindMax, err = strconv.Atoi(args[1]); err != nil {
+				return false
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func createOutputChatSettings(settings *models.ChatSettings) string {
+	if !settings.RemindEnabled {
+		return "Напоминания о долгах выключены. Включить: /remind on"
+	}
+
+	place := "в личные сообщения должникам"
+	if settings.RemindInGroup {
+		place = "в этот чат"
+	}
+	return fmt.Sprintf("Напоминания о долгах: каждые %d дн., не больше %d раз, %s.",
+		settings.RemindIntervalDays, settings.RemindMax, place)
+}
+
+// AutoFinish shows or changes auto finish of inactive sessions: /autofinish off or
+// /autofinish <days> [grace days].
+func (h *GroupTgHandler) AutoFinish(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	settings, err := h.usecase.GetChatSettings(dto.GetChatSettingsDTO{ChatID: c.Chat().ID})
+	if err != nil {
+		h.log.Warnf("Get chat settings err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send(createOutputAutoFinish(settings))
+	}
+
+	if !applyAutoFinishArgs(settings, args) {
+		return c.Send("Пожалуйста, укажи так: /autofinish off или /autofinish <Дни> [Дни после предупреждения]!")
+	}
+
+	err = h.usecase.SaveChatSettings(dto.SaveChatSettingsDTO{
+		UserID:   c.Message().Sender.ID,
+		IsAdmin:  h.isChatAdmin(c),
+		Settings: settings,
+	})
+	switch err {
+	case nil:
+		return c.Send(createOutputAutoFinish(settings))
+	case usecase.NoPermissionErr:
+		return c.Send(chatSettingsPermissionMsg)
+	case usecase.InvalidSettingsErr:
+		return c.Send("Количество дней должно быть положительным!")
+	default:
+		h.log.Warnf("Save chat settings err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+}
+
+func applyAutoFinishArgs(settings *models.ChatSettings, args []string) bool {
+	switch {
+	case len(args) == 1 && args[0] == offArg:
+		settings.AutoFinishDays = 0
+	case len(args) <= 2:
+		days, err := strconv.Atoi(args[0])
+		if err != nil || days <= 0 {
+			return false
+		}
+		settings.AutoFinishDays = days
+		if len(args) == 2 {
+			if settings.AutoFinishGraceDays, err = strconv.Atoi(args[1]); err != nil {
+				return false
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func createOutputAutoFinish(settings *models.ChatSettings) string {
+	if settings.AutoFinishDays == 0 {
+		return "Автозавершение сессий выключено. Включить: /autofinish <Дни> [Дни после предупреждения]"
+	}
+	return fmt.Sprintf("Если в сессии нет новых трат %d дн., бот предупредит об этом, "+
+		"а еще через %d дн. завершит сессию автоматически.", settings.AutoFinishDays, settings.AutoFinishGraceDays)
+}
+
+func (h *GroupTgHandler) AddMember(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	member, args, ok := parseMemberArgs(c)
+	if !ok || len(args) != 0 {
+		return c.Send("Пожалуйста, укажи так: /add_member @<Пользователь>!")
+	}
+
+	err := h.usecase.AddMember(dto.ManageMemberDTO{
+		ChatID:         c.Chat().ID,
+		UserID:         c.Message().Sender.ID,
+		Username:       c.Message().Sender.Username,
+		FirstName:      c.Message().Sender.FirstName,
+		LastName:       c.Message().Sender.LastName,
+		MemberUsername: member.username,
+		MemberTgID:     member.tgID,
+		SessionName:    sessionArg(c),
+		IsAdmin:        h.isChatAdmin(c),
+	})
+	return c.Send(h.membershipResponse(err, fmt.Sprintf("%s теперь участвует в сессии!", member.name)))
+}
+
+// Role changes role of session member: /role @<Пользователь> <treasurer|member|observer>.
+// /role admins <on|off> gives or takes away rights of treasurer from chat administrators.
+func (h *GroupTgHandler) Role(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	member, args, ok := parseMemberArgs(c)
+	if !ok {
+		return h.setAdminsTreasurers(c, args)
+	}
+	if len(args) != 1 {
+		return c.Send("Пожалуйста, укажи так: /role @<Пользователь> <treasurer|member|observer>!")
+	}
+
+	err := h.usecase.SetRole(dto.SetRoleDTO{
+		ChatID:         c.Chat().ID,
+		UserID:         c.Message().Sender.ID,
+		Username:       c.Message().Sender.Username,
+		FirstName:      c.Message().Sender.FirstName,
+		LastName:       c.Message().Sender.LastName,
+		SessionName:    sessionArg(c),
+		MemberUsername: member.username,
+		MemberTgID:     member.tgID,
+		Role:           args[0],
+	})
+	if err == usecase.InvalidRoleErr {
+		return c.Send("Роль может быть такой: treasurer (казначей), member (участник) или observer (наблюдатель)!")
+	}
+	return c.Send(h.membershipResponse(err, fmt.Sprintf("Роль %s изменена!", member.name)))
+}
+
+func (h *GroupTgHandler) setAdminsTreasurers(c tele.Context, args []string) error {
+	if len(args) != 2 || args[0] != adminsArg || (args[1] != onArg && args[1] != offArg) {
+		return c.Send("Пожалуйста, укажи так: /role @<Пользователь> <treasurer|member|observer> " +
+			"или /role admins <on|off>!")
+	}
+
+	enabled := args[1] == onArg
+	err := h.usecase.SetAdminsTreasurers(dto.SetAdminsTreasurersDTO{
+		ChatID:  c.Chat().ID,
+		UserID:  c.Message().Sender.ID,
+		Enabled: enabled,
+		IsAdmin: h.isChatAdmin(c),
+	})
+	switch {
+	case err == usecase.NoPermissionErr:
+		return c.Send(chatSettingsPermissionMsg)
+	case err != nil:
+		h.log.Warnf("Set admins treasurers err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	case enabled:
+		return c.Send("Администраторы чата теперь могут управлять сессиями как казначеи.")
+	default:
+		return c.Send("Администраторы чата больше не управляют сессиями, если они не казначеи.")
+	}
+}
+
+func (h *GroupTgHandler) LeaveSession(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	err := h.usecase.LeaveSession(dto.LeaveSessionDTO{
+		ChatID:      c.Chat().ID,
+		UserID:      c.Message().Sender.ID,
+		Username:    c.Message().Sender.Username,
+		FirstName:   c.Message().Sender.FirstName,
+		LastName:    c.Message().Sender.LastName,
+		SessionName: sessionArg(c),
+	})
+	return c.Send(h.membershipResponse(err, "Ты больше не участвуешь в сессии!"))
+}
+
+func (h *GroupTgHandler) KickMember(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	// Guests are kicked by name without mention
+	member, args, ok := parseMemberArgs(c)
+	switch {
+	case ok && len(args) == 0:
+	case !ok && len(args) == 1:
+		member = memberArg{username: args[0], name: args[0]}
+	default:
+		return c.Send("Пожалуйста, укажи так: /kick @<Пользователь> или /kick <Гость>!")
+	}
+
+	err := h.usecase.KickMember(dto.ManageMemberDTO{
+		ChatID:         c.Chat().ID,
+		UserID:         c.Message().Sender.ID,
+		Username:       c.Message().Sender.Username,
+		FirstName:      c.Message().Sender.FirstName,
+		LastName:       c.Message().Sender.LastName,
+		MemberUsername: member.username,
+		MemberTgID:     member.tgID,
+		MemberIsGuest:  !ok,
+		SessionName:    sessionArg(c),
+		IsAdmin:        h.isChatAdmin(c),
+	})
+	return c.Send(h.membershipResponse(err, fmt.Sprintf("%s больше не участвует в сессии!", member.name)))
+}
+
+func (h *GroupTgHandler) GetMembers(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	session, members, err := h.usecase.GetMembers(dto.GetMembersDTO{
+		ChatID:      c.Chat().ID,
+		UserID:      c.Message().Sender.ID,
+		SessionName: sessionArg(c),
+	})
+	if err != nil {
+		return c.Send(h.membershipResponse(err, ""))
+	}
+
+	responseText := fmt.Sprintf("Участники сессии '%s'\n", html.EscapeString(session.SessionName))
+	responseText += bigSeparateString + h.createOutputMembers(members)
+	return c.Send(responseText, tele.ModeHTML)
+}
+
+func (h *GroupTgHandler) createOutputMembers(members []*models.SessionMember) string {
+	var responseText string
+	for i, member := range members {
+		responseText += fmt.Sprintf("%d. %s%s\n", i+1, member.User.Mention(), roleTitles[member.Role])
+	}
+	return responseText
+}
+
+func (h *GroupTgHandler) AddGuest(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	sessionName, args := splitSessionArg(c.Args())
+	if len(args) != 1 || strings.HasPrefix(args[0], "@") {
+		return c.Send("Пожалуйста, укажи так: /guest <Имя>!")
+	}
+	guestName := args[0]
+
+	err := h.usecase.AddGuest(dto.AddGuestDTO{
+		ChatID:      c.Chat().ID,
+		UserID:      c.Message().Sender.ID,
+		Username:    c.Message().Sender.Username,
+		FirstName:   c.Message().Sender.FirstName,
+		LastName:    c.Message().Sender.LastName,
+		GuestName:   guestName,
+		SessionName: sessionName,
+	})
+	return c.Send(h.membershipResponse(err, fmt.Sprintf("Гость %s теперь участвует в сессии!\n"+
+		"Его траты: /add_for %s <Название> <Цена>", guestName, guestName)))
+}
+
+func (h *GroupTgHandler) MergeGuest(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Message().Sender.Username, c.Text())
+
+	// Guest is merged into mentioned user or into sender
+	member, _, ok := parseMemberArgs(c)
+	sessionName, args := splitSessionArg(c.Args())
+	if len(args) == 0 || (!ok && len(args) != 1) || strings.HasPrefix(args[0], "@") {
+		return c.Send("Пожалуйста, укажи так: /merge_guest <Имя гостя> [@<Пользователь>]!")
+	}
+	guestName := args[0]
+	successText := fmt.Sprintf("Все траты и долги гостя %s теперь твои!", guestName)
+	if ok {
+		successText = fmt.Sprintf("Все траты и долги гостя %s теперь у %s!", guestName, member.name)
+	}
+
+	err := h.usecase.MergeGuest(dto.MergeGuestDTO{
+		ChatID:         c.Chat().ID,
+		UserID:         c.Message().Sender.ID,
+		Username:       c.Message().Sender.Username,
+		FirstName:      c.Message().Sender.FirstName,
+		LastName:       c.Message().Sender.LastName,
+		GuestName:      guestName,
+		MemberUsername: member.username,
+		MemberTgID:     member.tgID,
+		SessionName:    sessionName,
+		IsAdmin:        h.isChatAdmin(c),
+	})
+	if err == usecase.NoPermissionErr {
+		return c.Send("Объединить гостя может только тот, кто его добавил, или казначей сессии!")
+	}
+	return c.Send(h.membershipResponse(err, successText))
 }
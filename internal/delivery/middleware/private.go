@@ -0,0 +1,14 @@
+package middleware
+
+import tele "gopkg.in/telebot.v3"
+
+// PrivateOnly rejects command sent outside of private chat with bot,
+// e.g. to not expose personal data in group chat.
+func PrivateOnly(next tele.HandlerFunc) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		if c.Chat().Type != tele.ChatPrivate {
+			return c.Send("Эта команда работает только в личном чате с ботом")
+		}
+		return next(c)
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"collector-telegram-bot/internal"
+	"collector-telegram-bot/internal/dto"
+	"collector-telegram-bot/internal/usecase/user_usecase"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// SyncProfile keeps user's profile up to date with every incoming update,
+// so reports always show current names.
+func SyncProfile(log internal.Logger, usecase user_usecase.UserUsecase) tele.MiddlewareFunc {
+	return func(next tele.HandlerFunc) tele.HandlerFunc {
+		return func(c tele.Context) error {
+			sender := c.Sender()
+			if sender == nil || sender.IsBot {
+				return next(c)
+			}
+
+			err := usecase.SyncProfile(dto.SyncProfileDTO{
+				UserID:       sender.ID,
+				Username:     sender.Username,
+				FirstName:    sender.FirstName,
+				LastName:     sender.LastName,
+				LanguageCode: sender.LanguageCode,
+			})
+			if err != nil {
+				log.Warnf("Sync profile err: %v", err)
+			}
+			return next(c)
+		}
+	}
+}
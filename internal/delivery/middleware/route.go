@@ -0,0 +1,13 @@
+package middleware
+
+import tele "gopkg.in/telebot.v3"
+
+// ByChatType routes command, which works both in private and group chats, to its handler.
+func ByChatType(private tele.HandlerFunc, group tele.HandlerFunc) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		if c.Chat().Type == tele.ChatPrivate {
+			return private(c)
+		}
+		return group(c)
+	}
+}
@@ -6,4 +6,22 @@ type PrivateHandler interface {
 	Info(c tele.Context) error
 	Start(c tele.Context) error
 	Sessions(c tele.Context) error
+	SessionsPage(c tele.Context) error
+	SessionDetails(c tele.Context) error
+	AddExpense(c tele.Context) error
+	SwitchExpenseNotify(c tele.Context) error
+	AddExpenseToSession(c tele.Context) error
+	Me(c tele.Context) error
+	Notify(c tele.Context) error
+	Remind(c tele.Context) error
+	Paid(c tele.Context) error
+	MarkDebtPaid(c tele.Context) error
+	Netting(c tele.Context) error
+	ProposeNetting(c tele.Context) error
+	ConfirmNetting(c tele.Context) error
+	DeclineNetting(c tele.Context) error
+	Requisites(c tele.Context) error
+	AddRequisite(c tele.Context) error
+	SetDefaultRequisite(c tele.Context) error
+	DeleteRequisite(c tele.Context) error
 }
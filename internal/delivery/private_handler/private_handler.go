@@ -2,17 +2,93 @@ package private_handler
 
 import (
 	"collector-telegram-bot/internal"
+	"collector-telegram-bot/internal/dto"
+	"collector-telegram-bot/internal/models"
+	"collector-telegram-bot/internal/usecase"
+	"collector-telegram-bot/internal/usecase/group_usecase"
 	"collector-telegram-bot/internal/usecase/private_usecase"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
 	tele "gopkg.in/telebot.v3"
 )
 
+const (
+	bigSeparateString   = "===========\n"
+	smallSeparateString = "----------\n"
+
+	sessionDataDivider = ":"
+	potArg             = "pot"
+	notifyOnArg        = "on"
+	notifyOffArg       = "off"
+
+	// draftTTL is time, during which expense draft waits for choice of session
+	draftTTL = 24 * time.Hour
+
+	requisitesHelp = "Добавить: /requisites_add <phone|card> <Номер> <Банк>\n" +
+		"Сделать основными: /requisites_default <Номер в списке>\n" +
+		"Удалить: /requisites_del <Номер в списке>"
+)
+
+var (
+	// SessionsPageBtn switches page of sessions list, its data is page number.
+	SessionsPageBtn = tele.Btn{Unique: "sessions_page"}
+	// SessionDetailsBtn shows session, its data is "<session uuid>:<page number>".
+	SessionDetailsBtn = tele.Btn{Unique: "session_details"}
+
+	// NettingProposeBtn sends netting to counterparty, its data is counterparty id.
+	NettingProposeBtn = tele.Btn{Unique: "netting_propose", Text: "Предложить взаимозачет"}
+	// NettingConfirmBtn and NettingDeclineBtn answer to netting, their data is netting id.
+	NettingConfirmBtn = tele.Btn{Unique: "netting_confirm", Text: "Подтвердить"}
+	NettingDeclineBtn = tele.Btn{Unique: "netting_decline", Text: "Отклонить"}
+
+	// DebtPaidBtn marks debt as paid, its data is debt id.
+	DebtPaidBtn = tele.Btn{Unique: "debt_paid"}
+
+	// ExpenseSessionBtn adds expense draft to session, its data is session uuid.
+	ExpenseSessionBtn = tele.Btn{Unique: "expense_session"}
+	// ExpenseNotifyBtn switches notification of group about expense draft.
+	ExpenseNotifyBtn = tele.Btn{Unique: "expense_notify"}
+)
+
+// expenseDraft is expense sent to bot in private chat, which waits for choice of session.
+type expenseDraft struct {
+	product   string
+	cost      int
+	fromPot   bool
+	notify    bool
+	createdAt time.Time
+}
+
+// draftKey is user telegram id and id of bot's message with buttons of draft.
+type draftKey struct {
+	userID    int64
+	messageID int
+}
+
 type PrivateTgHandler struct {
-	log     internal.Logger
-	usecase private_usecase.PrivateUsecase
+	log          internal.Logger
+	usecase      private_usecase.PrivateUsecase
+	groupUsecase group_usecase.GroupUsecase
+
+	// Drafts are kept by their messages, so every draft is chosen by its own buttons
+	draftsMu sync.Mutex
+	drafts   map[draftKey]expenseDraft
 }
 
-func New(log internal.Logger, usecase private_usecase.PrivateUsecase) PrivateHandler {
-	return &PrivateTgHandler{log: log, usecase: usecase}
+func New(log internal.Logger, usecase private_usecase.PrivateUsecase,
+	groupUsecase group_usecase.GroupUsecase) PrivateHandler {
+	return &PrivateTgHandler{
+		log:          log,
+		usecase:      usecase,
+		groupUsecase: groupUsecase,
+		drafts:       make(map[draftKey]expenseDraft),
+	}
 }
 
 func (h *PrivateTgHandler) Info(c tele.Context) error {
@@ -21,12 +97,849 @@ func (h *PrivateTgHandler) Info(c tele.Context) error {
 	return c.Send("Hello! You can work with this bot!")
 }
 
+// Start greets user, with payload of invite link it adds user to session.
 func (h *PrivateTgHandler) Start(c tele.Context) error {
 	h.log.Infof("Recieved message from %s, text = %s", c.Chat().Username, c.Text())
-	return c.Send("Hello! Let's work together!")
+
+	token := c.Message().Payload
+	if token == "" {
+		return c.Send("Hello! Let's work together!")
+	}
+
+	session, err := h.groupUsecase.JoinByInvite(dto.JoinByInviteDTO{
+		UserID:    c.Sender().ID,
+		Username:  c.Sender().Username,
+		FirstName: c.Sender().FirstName,
+		LastName:  c.Sender().LastName,
+		Token:     token,
+	})
+	switch err {
+	case nil:
+		return c.Send(fmt.Sprintf("Теперь ты участвуешь в сессии %s!\n"+
+			"Траты можно добавлять прямо здесь: /add <Название> <Цена>", session.SessionName))
+	case usecase.AlreadyMemberErr:
+		return c.Send(fmt.Sprintf("Ты уже участвуешь в сессии %s!", session.SessionName))
+	case usecase.SessionNotExistsErr:
+		return c.Send("Ссылка недействительна: сессия уже завершена.")
+	default:
+		h.log.Warnf("Join by invite err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
 }
 
 func (h *PrivateTgHandler) Sessions(c tele.Context) error {
 	h.log.Infof("Recieved message from %s, text = %s", c.Chat().Username, c.Text())
+
+	page, err := h.usecase.GetSessions(dto.GetSessionsDTO{UserID: c.Sender().ID})
+	switch {
+	case err == usecase.UserNotExistsErr || err == nil && page.Pages == 0:
+		return c.Send("Ты пока не участвовал ни в одной сессии.")
+	case err != nil:
+		h.log.Warnf("Get sessions err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+	return c.Send(h.createOutputSessions(page), h.sessionsMarkup(page), tele.ModeHTML)
+}
+
+// SessionsPage shows page of sessions list by SessionsPageBtn.
+func (h *PrivateTgHandler) SessionsPage(c tele.Context) error {
+	h.log.Infof("Recieved callback from %s, data = %s", c.Sender().Username, c.Data())
+
+	pageNumber, err := strconv.Atoi(c.Data())
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "Некорректная кнопка"})
+	}
+
+	page, err := h.usecase.GetSessions(dto.GetSessionsDTO{UserID: c.Sender().ID, Page: pageNumber})
+	if err != nil {
+		h.log.Warnf("Get sessions err: %v", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Извини, технические проблемы :("})
+	}
+
+	if err = c.Edit(h.createOutputSessions(page), h.sessionsMarkup(page), tele.ModeHTML); err != nil {
+		h.log.Warnf("Edit sessions err: %v", err)
+	}
+	return c.Respond()
+}
+
+// SessionDetails shows expenses and debts of session by SessionDetailsBtn.
+func (h *PrivateTgHandler) SessionDetails(c tele.Context) error {
+	h.log.Infof("Recieved callback from %s, data = %s", c.Sender().Username, c.Data())
+
+	uuidArg, pageArg, _ := strings.Cut(c.Data(), sessionDataDivider)
+	sessionUUID, uuidErr := uuid.Parse(uuidArg)
+	pageNumber, pageErr := strconv.Atoi(pageArg)
+	if uuidErr != nil || pageErr != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "Некорректная кнопка"})
+	}
+
+	details, err := h.usecase.GetSessionDetails(dto.GetSessionDetailsDTO{
+		UserID:      c.Sender().ID,
+		SessionUUID: sessionUUID,
+	})
+	switch err {
+	case nil:
+	case usecase.NotMemberErr:
+		return c.Respond(&tele.CallbackResponse{Text: "Ты больше не участвуешь в этой сессии"})
+	default:
+		h.log.Warnf("Get session details err: %v", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Извини, технические проблемы :("})
+	}
+
+	markup := &tele.ReplyMarkup{}
+	back := SessionsPageBtn
+	back.Text = "« К списку"
+	back.Data = strconv.Itoa(pageNumber)
+	markup.Inline(markup.Row(back))
+
+	if err = c.Edit(h.createOutputSessionDetails(details), markup, tele.ModeHTML); err != nil {
+		h.log.Warnf("Edit session details err: %v", err)
+	}
+	return c.Respond()
+}
+
+// AddExpense saves expense draft and asks user to choose session for it.
+func (h *PrivateTgHandler) AddExpense(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Chat().Username, c.Text())
+
+	args := c.Args()
+	fromPot := len(args) == 3 && args[2] == potArg
+	if len(args) != 2 && !fromPot {
+		return c.Send("Пожалуйста, укажи так: /add <Название продукта> <Цена> [pot]!")
+	}
+
+	cost, err := strconv.Atoi(args[1])
+	if err != nil {
+		return c.Send("Цена должна быть целым числом!")
+	}
+
+	sessions, err := h.usecase.GetActiveSessions(dto.GetSessionsDTO{UserID: c.Sender().ID})
+	switch {
+	case err == usecase.UserNotExistsErr || err == nil && len(sessions) == 0:
+		return c.Send("У тебя нет активных сессий. Присоединись к сессии в групповом чате!")
+	case err != nil:
+		h.log.Warnf("Get active sessions err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+
+	draft := expenseDraft{product: args[0], cost: cost, fromPot: fromPot, notify: true, createdAt: time.Now()}
+	msg, err := c.Bot().Send(c.Chat(), h.createOutputDraft(draft), h.expenseSessionsMarkup(sessions, draft))
+	if err != nil {
+		return err
+	}
+
+	h.draftsMu.Lock()
+	defer h.draftsMu.Unlock()
+	// Forgotten drafts are dropped, their buttons report that expense is outdated
+	for key, old := range h.drafts {
+		if draft.createdAt.Sub(old.createdAt) > draftTTL {
+			delete(h.drafts, key)
+		}
+	}
+	h.drafts[draftKey{userID: c.Sender().ID, messageID: msg.ID}] = draft
+	return nil
+}
+
+// SwitchExpenseNotify turns on or off notification of group about expense draft.
+func (h *PrivateTgHandler) SwitchExpenseNotify(c tele.Context) error {
+	h.log.Infof("Recieved callback from %s, data = %s", c.Sender().Username, c.Data())
+
+	key := draftKey{userID: c.Sender().ID, messageID: c.Message().ID}
+	h.draftsMu.Lock()
+	draft, ok := h.drafts[key]
+	draft.notify = !draft.notify
+	if ok {
+		h.drafts[key] = draft
+	}
+	h.draftsMu.Unlock()
+	if !ok {
+		return c.Respond(&tele.CallbackResponse{Text: "Трата устарела, отправь /add еще раз"})
+	}
+
+	sessions, err := h.usecase.GetActiveSessions(dto.GetSessionsDTO{UserID: c.Sender().ID})
+	if err != nil {
+		h.log.Warnf("Get active sessions err: %v", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Извини, технические проблемы :("})
+	}
+
+	if err = c.Edit(h.createOutputDraft(draft), h.expenseSessionsMarkup(sessions, draft)); err != nil {
+		h.log.Warnf("Edit expense draft err: %v", err)
+	}
+	return c.Respond()
+}
+
+// AddExpenseToSession records expense draft to session chosen by ExpenseSessionBtn.
+func (h *PrivateTgHandler) AddExpenseToSession(c tele.Context) error {
+	h.log.Infof("Recieved callback from %s, data = %s", c.Sender().Username, c.Data())
+
+	sessionUUID, err := uuid.Parse(c.Data())
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "Некорректная кнопка"})
+	}
+
+	key := draftKey{userID: c.Sender().ID, messageID: c.Message().ID}
+	h.draftsMu.Lock()
+	draft, ok := h.drafts[key]
+	delete(h.drafts, key)
+	h.draftsMu.Unlock()
+	if !ok {
+		return c.Respond(&tele.CallbackResponse{Text: "Трата устарела, отправь /add еще раз"})
+	}
+
+	chooseInfo := dto.ChooseSessionDTO{UserID: c.Sender().ID, SessionUUID: sessionUUID}
+	session, err := h.usecase.ChooseSession(chooseInfo)
+	if err == nil {
+		err = h.groupUsecase.AddExpenseToSession(dto.AddExpenseDTO{
+			ChatID:      session.ChatID,
+			SessionUUID: session.UUID,
+			Product:     draft.product,
+			Cost:        draft.cost,
+			UserID:      c.Sender().ID,
+			Username:    c.Sender().Username,
+			FirstName:   c.Sender().FirstName,
+			LastName:    c.Sender().LastName,
+			FromPot:     draft.fromPot,
+		})
+	}
+
+	var responseText string
+	switch err {
+	case nil:
+		responseText = fmt.Sprintf("Трата «%s» на %d рублей добавлена в сессию %s!", draft.product, draft.cost,
+			session.SessionName)
+		if draft.notify {
+			h.notifyExpense(c, session, draft)
+		}
+	case usecase.SessionNotExistsErr:
+		responseText = "Сессия уже завершена, трата не добавлена."
+	case usecase.NotMemberErr:
+		responseText = "Ты больше не участвуешь в этой сессии, трата не добавлена."
+	case usecase.NotPotSessionErr:
+		responseText = "В этой сессии нет общего котла, трата не добавлена."
+	case usecase.PotInsufficientErr:
+		responseText = "В котле недостаточно денег для этой траты :("
+	default:
+		h.log.Warnf("Add expense from private chat err: %v", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Извини, технические проблемы :("})
+	}
+
+	if err = c.Edit(responseText); err != nil {
+		h.log.Warnf("Edit expense draft err: %v", err)
+	}
+	return c.Respond()
+}
+
+// notifyExpense tells group about expense added from private chat without sound notification.
+func (h *PrivateTgHandler) notifyExpense(c tele.Context, session *models.Session, draft expenseDraft) {
+	sender := &models.User{
+		TgID:      c.Sender().ID,
+		Username:  c.Sender().Username,
+		FirstName: c.Sender().FirstName,
+		LastName:  c.Sender().LastName,
+	}
+	notifyText := fmt.Sprintf("%s добавил трату «%s» на %d рублей", sender.Mention(),
+		html.EscapeString(draft.product), draft.cost)
+	if draft.fromPot {
+		notifyText += " (из котла)"
+	}
+
+	if _, err := c.Bot().Send(&tele.Chat{ID: session.ChatID}, notifyText, tele.Silent, tele.ModeHTML); err != nil {
+		h.log.Warnf("Notify expense err: %v", err)
+	}
+}
+
+func (h *PrivateTgHandler) createOutputDraft(draft expenseDraft) string {
+	responseText := fmt.Sprintf("Трата «%s» на %d рублей", draft.product, draft.cost)
+	if draft.fromPot {
+		responseText += " (из котла)"
+	}
+	return responseText + "\nВ какую сессию ее добавить?"
+}
+
+// expenseSessionsMarkup has button for every active session and switch of group notification.
+func (h *PrivateTgHandler) expenseSessionsMarkup(sessions []*models.Session, draft expenseDraft) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	var rows []tele.Row
+	for _, session := range sessions {
+		btn := ExpenseSessionBtn
+		btn.Text = session.SessionName
+		if session.ChatTitle != "" {
+			btn.Text += fmt.Sprintf(" (%s)", session.ChatTitle)
+		}
+		btn.Data = session.UUID.String()
+		rows = append(rows, markup.Row(btn))
+	}
+
+	notify := ExpenseNotifyBtn
+	if draft.notify {
+		notify.Text = "Уведомить чат: да"
+	} else {
+		notify.Text = "Уведомить чат: нет"
+	}
+	rows = append(rows, markup.Row(notify))
+
+	markup.Inline(rows...)
+	return markup
+}
+
+// Notify turns on or off personal notifications about expenses and debts.
+func (h *PrivateTgHandler) Notify(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Chat().Username, c.Text())
+
+	args := c.Args()
+	if len(args) != 1 || (args[0] != notifyOnArg && args[0] != notifyOffArg) {
+		return c.Send("Пожалуйста, укажи так: /notify <on|off>!")
+	}
+
+	notify := args[0] == notifyOnArg
+	err := h.usecase.SetNotify(dto.SetNotifyDTO{UserID: c.Sender().ID, Notify: notify})
+	switch {
+	case err == usecase.UserNotExistsErr:
+		return c.Send("Сначала присоединись к сессии в групповом чате!")
+	case err != nil:
+		h.log.Warnf("Set notify err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	case notify:
+		return c.Send("Уведомления включены: буду писать о новых тратах, итогах сессий и возвратах долгов.")
+	default:
+		return c.Send("Уведомления выключены.")
+	}
+}
+
+// Remind turns on or off reminders about user's pending debts.
+func (h *PrivateTgHandler) Remind(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Chat().Username, c.Text())
+
+	args := c.Args()
+	if len(args) != 1 || (args[0] != notifyOnArg && args[0] != notifyOffArg) {
+		return c.Send("Пожалуйста, укажи так: /remind <on|off>!")
+	}
+
+	remind := args[0] == notifyOnArg
+	err := h.usecase.SetRemind(dto.SetRemindDTO{UserID: c.Sender().ID, Remind: remind})
+	switch {
+	case err == usecase.UserNotExistsErr:
+		return c.Send("Сначала присоединись к сессии в групповом чате!")
+	case err != nil:
+		h.log.Warnf("Set remind err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	case remind:
+		return c.Send("Напоминания о долгах включены.")
+	default:
+		return c.Send("Напоминания о долгах выключены.")
+	}
+}
+
+// Paid shows debts of finished sessions with buttons to mark them paid.
+func (h *PrivateTgHandler) Paid(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Chat().Username, c.Text())
+
+	debts, err := h.usecase.GetDebtsToPay(dto.GetDebtsToPayDTO{UserID: c.Sender().ID})
+	switch {
+	case err == usecase.UserNotExistsErr || err == nil && len(debts) == 0:
+		return c.Send("Неоплаченных долгов нет!")
+	case err != nil:
+		h.log.Warnf("Get debts to pay err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+	return c.Send("Отметь долги, которые ты уже вернул:", h.debtsToPayMarkup(debts))
+}
+
+// MarkDebtPaid marks debt chosen by DebtPaidBtn as paid and refreshes list of debts.
+func (h *PrivateTgHandler) MarkDebtPaid(c tele.Context) error {
+	h.log.Infof("Recieved callback from %s, data = %s", c.Sender().Username, c.Data())
+
+	debtID, err := strconv.ParseUint(c.Data(), 10, 64)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "Некорректная кнопка"})
+	}
+
+	debt, err := h.usecase.MarkDebtPaid(dto.MarkDebtPaidDTO{UserID: c.Sender().ID, DebtID: debtID})
+	switch err {
+	case nil:
+	case usecase.DebtNotExistsErr, usecase.NotDebtorErr:
+		return c.Respond(&tele.CallbackResponse{Text: "Долг уже погашен"})
+	default:
+		h.log.Warnf("Mark debt paid err: %v", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Извини, технические проблемы :("})
+	}
+
+	debts, err := h.usecase.GetDebtsToPay(dto.GetDebtsToPayDTO{UserID: c.Sender().ID})
+	switch {
+	case err != nil:
+		h.log.Warnf("Get debts to pay err: %v", err)
+	case len(debts) == 0:
+		err = c.Edit("Все долги возвращены!")
+	default:
+		err = c.Edit("Отметь долги, которые ты уже вернул:", h.debtsToPayMarkup(debts))
+	}
+	if err != nil {
+		h.log.Warnf("Edit debts to pay err: %v", err)
+	}
+	return c.Respond(&tele.CallbackResponse{
+		Text: fmt.Sprintf("Долг %s на %d рублей отмечен погашенным", debt.Creditor.DisplayName(), debt.Money),
+	})
+}
+
+func (h *PrivateTgHandler) debtsToPayMarkup(debts []*models.Debt) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	var rows []tele.Row
+	for _, debt := range debts {
+		btn := DebtPaidBtn
+		btn.Text = fmt.Sprintf("%d рублей → %s (%s)", debt.Money, debt.Creditor.DisplayName(),
+			debt.Session.SessionName)
+		btn.Data = strconv.FormatUint(debt.ID, 10)
+		rows = append(rows, markup.Row(btn))
+	}
+	markup.Inline(rows...)
+	return markup
+}
+
+// Me shows all unsettled debts of user grouped by counterparty.
+func (h *PrivateTgHandler) Me(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Chat().Username, c.Text())
+
+	dashboard, err := h.usecase.GetDashboard(dto.GetDashboardDTO{UserID: c.Sender().ID})
+	switch {
+	case err == usecase.UserNotExistsErr || err == nil && len(dashboard.Counterparties) == 0:
+		return c.Send("Долгов нет!")
+	case err != nil:
+		h.log.Warnf("Get dashboard err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+	return c.Send(h.createOutputDashboard(dashboard), h.dashboardMarkup(dashboard), tele.ModeHTML)
+}
+
+func (h *PrivateTgHandler) createOutputDashboard(dashboard *models.Dashboard) string {
+	responseText := fmt.Sprintf("Тебе должны: %d рублей\nТы должен: %d рублей\n", dashboard.OwedToUser,
+		dashboard.OwedByUser) + bigSeparateString
+
+	for _, counterparty := range dashboard.Counterparties {
+		switch {
+		case counterparty.Balance > 0:
+			responseText += fmt.Sprintf("%s должен тебе %d рублей\n", counterparty.User.Mention(),
+				counterparty.Balance)
+		case counterparty.Balance < 0:
+			responseText += fmt.Sprintf("Ты должен %s %d рублей\n", counterparty.User.Mention(),
+				-counterparty.Balance)
+		default:
+			responseText += fmt.Sprintf("С %s вы в расчете\n", counterparty.User.Mention())
+		}
+
+		for _, debt := range counterparty.Debts {
+			responseText += fmt.Sprintf("• %s: %+d", html.EscapeString(debt.Session.SessionName), debt.Money)
+			if debt.Session.ChatTitle != "" {
+				responseText += fmt.Sprintf(" (чат «%s»)", html.EscapeString(debt.Session.ChatTitle))
+			}
+			if debt.Session.State == models.SessionActive {
+				responseText += ", сессия идет"
+			}
+			responseText += "\n"
+		}
+		responseText += smallSeparateString
+	}
+	return responseText
+}
+
+// dashboardMarkup has button for every session with debts.
+func (h *PrivateTgHandler) dashboardMarkup(dashboard *models.Dashboard) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	shown := make(map[string]bool)
+
+	var rows []tele.Row
+	for _, counterparty := range dashboard.Counterparties {
+		for _, debt := range counterparty.Debts {
+			sessionUUID := debt.Session.UUID.String()
+			if shown[sessionUUID] {
+				continue
+			}
+			shown[sessionUUID] = true
+
+			btn := SessionDetailsBtn
+			btn.Text = debt.Session.SessionName
+			btn.Data = sessionUUID + sessionDataDivider + "0"
+			rows = append(rows, markup.Row(btn))
+		}
+	}
+	markup.Inline(rows...)
+	return markup
+}
+
+// Netting shows users, with whom user's debts offset each other, and proposes single transfer.
+func (h *PrivateTgHandler) Netting(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Chat().Username, c.Text())
+
+	nettings, err := h.usecase.GetNettings(dto.GetNettingsDTO{UserID: c.Sender().ID})
+	switch {
+	case err == usecase.UserNotExistsErr || err == nil && len(nettings) == 0:
+		return c.Send("Нет встречных долгов, которые можно зачесть.")
+	case err != nil:
+		h.log.Warnf("Get nettings err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+
+	for _, netting := range nettings {
+		markup := &tele.ReplyMarkup{}
+		btn := NettingProposeBtn
+		btn.Data = strconv.FormatUint(netting.SecondUser.ID, 10)
+		markup.Inline(markup.Row(btn))
+
+		if err = c.Send(h.createOutputNetting(netting, netting.FirstUser.ID), markup, tele.ModeHTML); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// ProposeNetting saves netting and asks counterparty to confirm it.
+func (h *PrivateTgHandler) ProposeNetting(c tele.Context) error {
+	h.log.Infof("Recieved callback from %s, data = %s", c.Sender().Username, c.Data())
+
+	counterpartyID, err := strconv.ParseUint(c.Data(), 10, 64)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "Некорректная кнопка"})
+	}
+
+	netting, err := h.usecase.ProposeNetting(dto.ProposeNettingDTO{
+		UserID:         c.Sender().ID,
+		CounterpartyID: counterpartyID,
+	})
+	switch err {
+	case nil:
+	case usecase.NettingNotExistsErr:
+		return c.Respond(&tele.CallbackResponse{Text: "Встречных долгов больше нет, запроси /netting еще раз"})
+	case usecase.NettingProposedErr:
+		return c.Respond(&tele.CallbackResponse{Text: "Взаимозачет уже ждет подтверждения"})
+	default:
+		h.log.Warnf("Propose netting err: %v", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Извини, технические проблемы :("})
+	}
+
+	counterparty := netting.SecondUser
+	markup := &tele.ReplyMarkup{}
+	confirm, decline := NettingConfirmBtn, NettingDeclineBtn
+	confirm.Data = strconv.FormatUint(netting.ID, 10)
+	decline.Data = confirm.Data
+	markup.Inline(markup.Row(confirm, decline))
+
+	requestText := fmt.Sprintf("%s предлагает взаимозачет\n", netting.FirstUser.Mention()) +
+		h.createOutputNetting(netting, counterparty.ID)
+	if _, err = c.Bot().Send(&tele.User{ID: counterparty.TgID}, requestText, markup, tele.ModeHTML); err != nil {
+		h.log.Warnf("Send netting err: %v", err)
+		// Nobody can confirm netting, which counterparty hasn't received
+		declineInfo := dto.ManageNettingDTO{UserID: c.Sender().ID, NettingID: netting.ID}
+		if _, err = h.usecase.DeclineNetting(declineInfo); err != nil {
+			h.log.Warnf("Decline netting err: %v", err)
+		}
+		return c.Respond(&tele.CallbackResponse{
+			Text:      "Не получилось отправить предложение: пользователь еще не писал боту",
+			ShowAlert: true,
+		})
+	}
+
+	proposalText := h.createOutputNetting(netting, netting.FirstUser.ID) +
+		fmt.Sprintf("\nПредложение отправлено %s, ждем подтверждения.", counterparty.Mention())
+	if err = c.Edit(proposalText, tele.ModeHTML); err != nil {
+		h.log.Warnf("Edit netting err: %v", err)
+	}
+	return c.Respond()
+}
+
+// ConfirmNetting confirms netting by NettingConfirmBtn.
+func (h *PrivateTgHandler) ConfirmNetting(c tele.Context) error {
+	return h.answerNetting(c, h.usecase.ConfirmNetting)
+}
+
+// DeclineNetting declines netting by NettingDeclineBtn.
+func (h *PrivateTgHandler) DeclineNetting(c tele.Context) error {
+	return h.answerNetting(c, h.usecase.DeclineNetting)
+}
+
+// answerNetting applies user's answer to netting and notifies counterparty about result.
+func (h *PrivateTgHandler) answerNetting(c tele.Context,
+	answer func(info dto.ManageNettingDTO) (*models.Netting, error)) error {
+	h.log.Infof("Recieved callback from %s, data = %s", c.Sender().Username, c.Data())
+
+	nettingID, err := strconv.ParseUint(c.Data(), 10, 64)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "Некорректная кнопка"})
+	}
+
+	netting, err := answer(dto.ManageNettingDTO{UserID: c.Sender().ID, NettingID: nettingID})
+	switch err {
+	case nil:
+	case usecase.NettingNotExistsErr:
+		return c.Respond(&tele.CallbackResponse{Text: "Взаимозачет уже неактуален"})
+	case usecase.NettingOutdatedErr:
+		if err = c.Edit("Часть долгов уже погашена, взаимозачет отменен. Посмотри /netting еще раз."); err != nil {
+			h.log.Warnf("Edit netting err: %v", err)
+		}
+		return c.Respond()
+	default:
+		h.log.Warnf("Answer netting err: %v", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Извини, технические проблемы :("})
+	}
+
+	var resultText string
+	switch netting.Status {
+	case models.NettingDone:
+		resultText = "Взаимозачет подтвержден, долги отмечены погашенными!"
+		if netting.Money != 0 {
+			resultText += " Итоговая сумма записана одним долгом, его возврат отмечается через /paid."
+		}
+	case models.NettingDeclined:
+		resultText = "Взаимозачет отклонен."
+	default:
+		resultText = "Подтверждено, ждем второго участника."
+	}
+
+	var user, counterparty *models.User
+	if netting.FirstUser.TgID == c.Sender().ID {
+		user, counterparty = netting.FirstUser, netting.SecondUser
+	} else {
+		user, counterparty = netting.SecondUser, netting.FirstUser
+	}
+
+	if err = c.Edit(h.createOutputNetting(netting, user.ID)+"\n"+resultText, tele.ModeHTML); err != nil {
+		h.log.Warnf("Edit netting err: %v", err)
+	}
+	if netting.Status != models.NettingProposed {
+		notifyText := fmt.Sprintf("%s ответил на взаимозачет\n", user.Mention()) +
+			h.createOutputNetting(netting, counterparty.ID) + "\n" + resultText
+		if _, err = c.Bot().Send(&tele.User{ID: counterparty.TgID}, notifyText, tele.ModeHTML); err != nil {
+			h.log.Warnf("Notify netting err: %v", err)
+		}
+	}
+	return c.Respond()
+}
+
+// createOutputNetting describes netting from side of one of its users.
+func (h *PrivateTgHandler) createOutputNetting(netting *models.Netting, userID uint64) string {
+	counterparty := netting.Counterparty(userID)
+	responseText := fmt.Sprintf("Встречные долги с %s\n", counterparty.Mention()) + bigSeparateString
+
+	for _, debt := range netting.Debts {
+		if debt.Creditor.ID == userID {
+			responseText += fmt.Sprintf("• %s: тебе должны %d рублей", html.EscapeString(debt.Session.SessionName),
+				debt.Money)
+		} else {
+			responseText += fmt.Sprintf("• %s: ты должен %d рублей", html.EscapeString(debt.Session.SessionName),
+				debt.Money)
+		}
+		if debt.Session.ChatTitle != "" {
+			responseText += fmt.Sprintf(" (чат «%s»)", html.EscapeString(debt.Session.ChatTitle))
+		}
+		responseText += "\n"
+	}
+
+	responseText += smallSeparateString
+	switch balance := netting.BalanceFor(userID); {
+	case balance > 0:
+		responseText += fmt.Sprintf("Итого: %s переводит тебе %d рублей\n", counterparty.Mention(), balance)
+	case balance < 0:
+		responseText += fmt.Sprintf("Итого: ты переводишь %s %d рублей\n", counterparty.Mention(), -balance)
+	default:
+		responseText += "Итого: долги полностью взаимозачитываются\n"
+	}
+	return responseText
+}
+
+func (h *PrivateTgHandler) createOutputSessions(page *models.SessionsPage) string {
+	responseText := fmt.Sprintf("Твои сессии (страница %d из %d)\n", page.Page+1, page.Pages) + bigSeparateString
+	for i, summary := range page.Summaries {
+		responseText += fmt.Sprintf("%d. ", i+1) + h.createOutputSummary(summary) + smallSeparateString
+	}
+	return responseText
+}
+
+func (h *PrivateTgHandler) createOutputSummary(summary *models.SessionSummary) string {
+	session := summary.Session
+	responseText := fmt.Sprintf("<b>%s</b>", html.EscapeString(session.SessionName))
+	if session.ChatTitle != "" {
+		responseText += fmt.Sprintf(" в чате «%s»", html.EscapeString(session.ChatTitle))
+	}
+	responseText += "\n"
+
+	switch session.State {
+	case models.SessionActive:
+		responseText += fmt.Sprintf("%s - идет сейчас\n", session.StartedAt)
+	case models.SessionSettling:
+		responseText += fmt.Sprintf("%s - %s, идут расчеты\n", session.StartedAt, session.FinishedAt)
+	default:
+		responseText += fmt.Sprintf("%s - %s\n", session.StartedAt, session.FinishedAt)
+	}
+
+	responseText += fmt.Sprintf("Потрачено: %d рублей\n", summary.Spent)
+	switch {
+	case summary.Balance > 0 && session.Mode == models.SessionModePot:
+		responseText += fmt.Sprintf("Котел вернет тебе %d рублей\n", summary.Balance)
+	case summary.Balance < 0 && session.Mode == models.SessionModePot:
+		responseText += fmt.Sprintf("Ты доплачиваешь в котел %d рублей\n", -summary.Balance)
+	case summary.Balance > 0:
+		responseText += fmt.Sprintf("Тебе должны %d рублей\n", summary.Balance)
+	case summary.Balance < 0:
+		responseText += fmt.Sprintf("Ты должен %d рублей\n", -summary.Balance)
+	default:
+		responseText += "Долгов нет\n"
+	}
+	return responseText
+}
+
+func (h *PrivateTgHandler) createOutputSessionDetails(details *models.SessionDetails) string {
+	responseText := h.createOutputSummary(details.Summary) + bigSeparateString
+
+	responseText += "Траты:\n"
+	if len(details.Expenses) == 0 {
+		responseText += "пока нет\n"
+	}
+	for _, expense := range details.Expenses {
+		responseText += fmt.Sprintf("%s - %s: %d рублей", html.EscapeString(expense.User.DisplayName()),
+			html.EscapeString(expense.Description), expense.Cost)
+		if expense.FromPot {
+			responseText += " (из котла)"
+		}
+		responseText += "\n"
+	}
+
+	if len(details.Debts) != 0 {
+		responseText += smallSeparateString + "Долги:\n"
+	}
+	for _, debt := range details.Debts {
+		responseText += fmt.Sprintf("%s → %s - %d рублей\n", html.EscapeString(debt.Debtor.DisplayName()),
+			html.EscapeString(debt.Creditor.DisplayName()), debt.Money)
+	}
+	return responseText
+}
+
+// sessionsMarkup has buttons to open every session of page and to switch pages.
+func (h *PrivateTgHandler) sessionsMarkup(page *models.SessionsPage) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	var details tele.Row
+	for i, summary := range page.Summaries {
+		btn := SessionDetailsBtn
+		btn.Text = strconv.Itoa(i + 1)
+		btn.Data = summary.Session.UUID.String() + sessionDataDivider + strconv.Itoa(page.Page)
+		details = append(details, btn)
+	}
+
+	var navigation tele.Row
+	if page.Page > 0 {
+		btn := SessionsPageBtn
+		btn.Text = "«"
+		btn.Data = strconv.Itoa(page.Page - 1)
+		navigation = append(navigation, btn)
+	}
+	if page.Page+1 < page.Pages {
+		btn := SessionsPageBtn
+		btn.Text = "»"
+		btn.Data = strconv.Itoa(page.Page + 1)
+		navigation = append(navigation, btn)
+	}
+
+	rows := []tele.Row{details}
+	if len(navigation) != 0 {
+		rows = append(rows, navigation)
+	}
+	markup.Inline(rows...)
+	return markup
+}
+
+func (h *PrivateTgHandler) Requisites(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Chat().Username, c.Text())
+
+	requisites, err := h.usecase.GetRequisites(dto.GetRequisitesDTO{UserID: c.Sender().ID})
+	if err != nil {
+		h.log.Warnf("Get requisites err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+
+	if len(requisites) == 0 {
+		return c.Send("Реквизитов пока нет.\n" + requisitesHelp)
+	}
+
+	responseText := "Твои реквизиты\n" + bigSeparateString
+	for i, requisite := range requisites {
+		responseText += fmt.Sprintf("%d. %s", i+1, requisite.Format(requisite.Value))
+		if requisite.IsDefault {
+			responseText += " (основные)"
+		}
+		responseText += "\n"
+	}
+	responseText += bigSeparateString + requisitesHelp
+	return c.Send(responseText)
+}
+
+func (h *PrivateTgHandler) AddRequisite(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Chat().Username, c.Text())
+
+	args := c.Args()
+	if len(args) < 3 || (args[0] != models.RequisitePhone && args[0] != models.RequisiteCard) {
+		return c.Send("Пожалуйста, укажи так: /requisites_add <phone|card> <Номер> <Банк>!")
+	}
+
+	// Card number may be written in groups, bank name is always last
+	info := dto.AddRequisiteDTO{
+		UserID: c.Sender().ID,
+		Kind:   args[0],
+		Value:  strings.Join(args[1:len(args)-1], ""),
+		Bank:   args[len(args)-1],
+	}
+
+	err := h.usecase.AddRequisite(info)
+	switch err {
+	case nil:
+		return c.Send("Реквизиты добавлены!")
+	case usecase.InvalidRequisiteErr:
+		return c.Send("Проверь номер: телефон в формате +79991234567, карта из 16-19 цифр")
+	default:
+		h.log.Warnf("Add requisite err: %v", err)
+		return c.Send("Извини, технические проблемы :(")
+	}
+}
+
+func (h *PrivateTgHandler) SetDefaultRequisite(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Chat().Username, c.Text())
+
+	number, ok := requisiteNumber(c)
+	if !ok {
+		return c.Send("Пожалуйста, укажи так: /requisites_default <Номер в списке>!")
+	}
+
+	err := h.usecase.SetDefaultRequisite(dto.ManageRequisiteDTO{UserID: c.Sender().ID, Number: number})
+	return c.Send(h.requisiteResponse(err, "Основные реквизиты изменены!"))
+}
+
+func (h *PrivateTgHandler) DeleteRequisite(c tele.Context) error {
+	h.log.Infof("Recieved message from %s, text = %s", c.Chat().Username, c.Text())
+
+	number, ok := requisiteNumber(c)
+	if !ok {
+		return c.Send("Пожалуйста, укажи так: /requisites_del <Номер в списке>!")
+	}
+
+	err := h.usecase.DeleteRequisite(dto.ManageRequisiteDTO{UserID: c.Sender().ID, Number: number})
+	return c.Send(h.requisiteResponse(err, "Реквизиты удалены!"))
+}
+
+func requisiteNumber(c tele.Context) (int, bool) {
+	if len(c.Args()) != 1 {
+		return 0, false
+	}
+	number, err := strconv.Atoi(c.Args()[0])
+	return number, err == nil
+}
+
+func (h *PrivateTgHandler) requisiteResponse(err error, successText string) string {
+	switch err {
+	case nil:
+		return successText
+	case usecase.RequisiteNotExistsErr:
+		return "Нет реквизитов с таким номером, посмотри список: /requisites"
+	default:
+		h.log.Warnf("Requisite err: %v", err)
+		return "Извини, технические проблемы :("
+	}
+}
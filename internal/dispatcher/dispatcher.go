@@ -0,0 +1,207 @@
+// Package dispatcher processes updates of one chat one by one, while different chats are processed in parallel.
+package dispatcher
+
+import (
+	"collector-telegram-bot/internal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// busyText is answer to update, which is dropped because of full queue of its chat.
+const busyText = "Бот не успевает обработать команды этого чата, повторите позже"
+
+// Stats are counters of processed updates since start of bot.
+type Stats struct {
+	Received  uint64
+	Processed uint64
+	// Dropped are updates, which didn't fit into full queue of their chat
+	Dropped uint64
+	// Chats is count of chats, which updates are being processed now
+	Chats int
+	// Queued is count of updates waiting in queues of all chats
+	Queued int
+}
+
+// Dispatcher is poller, which wraps another one and runs updates of every chat in separate worker.
+// Bot must be synchronous, so handler is finished before the next update of chat is taken.
+type Dispatcher struct {
+	log           internal.Logger
+	poller        tele.Poller
+	queueSize     int
+	statsInterval time.Duration
+
+	// mu guards queues, worker of chat is removed only when its queue is empty
+	mu     sync.Mutex
+	queues map[int64]chan tele.Update
+	// warned are chats, which got busy message since their queue was filled, it isn't repeated for every update
+	warned  map[int64]bool
+	workers sync.WaitGroup
+
+	received  atomic.Uint64
+	processed atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+func New(log internal.Logger, poller tele.Poller, queueSize int, statsInterval time.Duration) *Dispatcher {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	return &Dispatcher{
+		log:           log,
+		poller:        poller,
+		queueSize:     queueSize,
+		statsInterval: statsInterval,
+		queues:        make(map[int64]chan tele.Update),
+		warned:        make(map[int64]bool),
+	}
+}
+
+// Poll passes updates of wrapped poller to workers of their chats, stats are logged periodically.
+// It returns after stop, when all received updates are processed.
+func (d *Dispatcher) Poll(b *tele.Bot, _ chan tele.Update, stop chan struct{}) {
+	updates := make(chan tele.Update, d.queueSize)
+	stopPoller := make(chan struct{})
+	stopConfirm := make(chan struct{})
+
+	go func() {
+		d.poller.Poll(b, updates, stopPoller)
+		close(stopConfirm)
+	}()
+
+	var statsTick <-chan time.Time
+	if d.statsInterval > 0 {
+		ticker := time.NewTicker(d.statsInterval)
+		defer ticker.Stop()
+		statsTick = ticker.C
+	}
+
+	for {
+		select {
+		case upd := <-updates:
+			d.dispatch(b, upd)
+		case <-statsTick:
+			stats := d.Stats()
+			d.log.Infof("Dispatcher stats: received %d, processed %d, dropped %d, chats %d, queued %d",
+				stats.Received, stats.Processed, stats.Dropped, stats.Chats, stats.Queued)
+		case <-stop:
+			d.shutdown(b, updates, stopPoller, stopConfirm)
+			return
+		}
+	}
+}
+
+// shutdown stops wrapped poller and waits until all updates are processed.
+// Updates are dispatched meanwhile, because poller may block on sending them before it sees stop.
+func (d *Dispatcher) shutdown(b *tele.Bot, updates chan tele.Update, stopPoller, stopConfirm chan struct{}) {
+	close(stopPoller)
+	for stopped := false; !stopped; {
+		select {
+		case upd := <-updates:
+			d.dispatch(b, upd)
+		case <-stopConfirm:
+			stopped = true
+		}
+	}
+	for len(updates) > 0 {
+		d.dispatch(b, <-updates)
+	}
+	d.workers.Wait()
+}
+
+func (d *Dispatcher) Stats() Stats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := Stats{
+		Received:  d.received.Load(),
+		Processed: d.processed.Load(),
+		Dropped:   d.dropped.Load(),
+		Chats:     len(d.queues),
+	}
+	for _, queue := range d.queues {
+		stats.Queued += len(queue)
+	}
+	return stats
+}
+
+// dispatch puts update to queue of its chat and starts worker, if chat has none.
+func (d *Dispatcher) dispatch(b *tele.Bot, upd tele.Update) {
+	d.received.Add(1)
+	chatID := chatOf(b.NewContext(upd))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	queue, ok := d.queues[chatID]
+	if !ok {
+		queue = make(chan tele.Update, d.queueSize)
+		d.queues[chatID] = queue
+		d.workers.Add(1)
+		go d.work(b, chatID, queue)
+	}
+
+	select {
+	case queue <- upd:
+	default:
+		d.dropped.Add(1)
+		d.log.Warnf("Update queue of chat %d is full, update %d is dropped", chatID, upd.ID)
+
+		// Callback is always answered, otherwise button keeps loading
+		if upd.Callback == nil && (upd.Message == nil || d.warned[chatID]) {
+			return
+		}
+		d.warned[chatID] = true
+		d.workers.Add(1)
+		go d.reject(b, upd)
+	}
+}
+
+// reject tells user, that update is dropped. It runs separately, so polling isn't blocked by request.
+func (d *Dispatcher) reject(b *tele.Bot, upd tele.Update) {
+	defer d.workers.Done()
+
+	c := b.NewContext(upd)
+	var err error
+	if upd.Callback != nil {
+		err = c.Respond(&tele.CallbackResponse{Text: busyText})
+	} else {
+		err = c.Send(busyText)
+	}
+	if err != nil {
+		d.log.Warnf("Busy reply to update %d err: %v", upd.ID, err)
+	}
+}
+
+// work processes updates of chat until its queue is empty.
+func (d *Dispatcher) work(b *tele.Bot, chatID int64, queue chan tele.Update) {
+	defer d.workers.Done()
+
+	for {
+		d.mu.Lock()
+		select {
+		case upd := <-queue:
+			delete(d.warned, chatID)
+			d.mu.Unlock()
+			b.ProcessUpdate(upd)
+			d.processed.Add(1)
+		default:
+			delete(d.queues, chatID)
+			d.mu.Unlock()
+			return
+		}
+	}
+}
+
+// chatOf returns chat of update, updates without chat, like inline queries, are grouped by sender.
+func chatOf(c tele.Context) int64 {
+	if chat := c.Chat(); chat != nil {
+		return chat.ID
+	}
+	if sender := c.Sender(); sender != nil {
+		return sender.ID
+	}
+	return 0
+}
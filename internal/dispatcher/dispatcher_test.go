@@ -0,0 +1,287 @@
+package dispatcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+const waitTimeout = 5 * time.Second
+
+type nopLogger struct{}
+
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Debugf(string, ...interface{}) {}
+func (nopLogger) Warnf(string, ...interface{})  {}
+
+// chanPoller passes updates sent by test, after stop it passes updates of afterStop, like poller finishing its request.
+type chanPoller struct {
+	in        chan tele.Update
+	afterStop []tele.Update
+}
+
+func (p *chanPoller) Poll(_ *tele.Bot, dest chan tele.Update, stop chan struct{}) {
+	for {
+		select {
+		case upd := <-p.in:
+			dest <- upd
+		case <-stop:
+			for _, upd := range p.afterStop {
+				dest <- upd
+			}
+			return
+		}
+	}
+}
+
+// apiServer counts requests to bot API by method.
+type apiServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	methods map[string]int
+}
+
+func newAPIServer(t *testing.T) *apiServer {
+	t.Helper()
+	s := &apiServer{methods: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		s.mu.Lock()
+		s.methods[method]++
+		s.mu.Unlock()
+
+		if method == "answerCallbackQuery" {
+			w.Write([]byte(`{"ok":true,"result":true}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *apiServer) count(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.methods[method]
+}
+
+func newBot(t *testing.T, url string) *tele.Bot {
+	t.Helper()
+	b, err := tele.NewBot(tele.Settings{Token: "token", URL: url, Offline: true, Synchronous: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func message(id int, chatID int64, text string) tele.Update {
+	return tele.Update{ID: id, Message: &tele.Message{
+		ID:     id,
+		Chat:   &tele.Chat{ID: chatID},
+		Sender: &tele.User{ID: chatID},
+		Text:   text,
+	}}
+}
+
+func callback(id int, chatID int64) tele.Update {
+	return tele.Update{ID: id, Callback: &tele.Callback{
+		ID:      strconv.Itoa(id),
+		Sender:  &tele.User{ID: chatID},
+		Message: &tele.Message{ID: id, Chat: &tele.Chat{ID: chatID}},
+	}}
+}
+
+// run starts polling and returns function, which stops it and waits until it returns.
+func run(t *testing.T, d *Dispatcher, b *tele.Bot) func() {
+	t.Helper()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		d.Poll(b, nil, stop)
+		close(done)
+	}()
+
+	return func() {
+		close(stop)
+		select {
+		case <-done:
+		case <-time.After(waitTimeout):
+			t.Fatal("Poll didn't return after stop")
+		}
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition isn't reached in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestUpdatesOfChatAreProcessedInOrder(t *testing.T) {
+	const chats, perChat = 5, 50
+
+	b := newBot(t, "")
+	var mu sync.Mutex
+	got := make(map[int64][]string)
+	b.Handle(tele.OnText, func(c tele.Context) error {
+		mu.Lock()
+		got[c.Chat().ID] = append(got[c.Chat().ID], c.Text())
+		mu.Unlock()
+		return nil
+	})
+
+	poller := &chanPoller{in: make(chan tele.Update)}
+	d := New(nopLogger{}, poller, chats*perChat, 0)
+	stop := run(t, d, b)
+
+	id := 0
+	for i := 0; i < perChat; i++ {
+		for chatID := int64(1); chatID <= chats; chatID++ {
+			id++
+			poller.in <- message(id, chatID, strconv.Itoa(i))
+		}
+	}
+	stop()
+
+	for chatID := int64(1); chatID <= chats; chatID++ {
+		if len(got[chatID]) != perChat {
+			t.Fatalf("chat %d: processed %d updates, want %d", chatID, len(got[chatID]), perChat)
+		}
+		for i, text := range got[chatID] {
+			if text != strconv.Itoa(i) {
+				t.Fatalf("chat %d: update %s is processed at position %d", chatID, text, i)
+			}
+		}
+	}
+
+	stats := d.Stats()
+	if stats.Received != chats*perChat || stats.Processed != chats*perChat || stats.Dropped != 0 {
+		t.Errorf("stats = %+v, want all %d updates processed", stats, chats*perChat)
+	}
+	if stats.Chats != 0 || stats.Queued != 0 {
+		t.Errorf("stats = %+v, want no chats and queued updates after stop", stats)
+	}
+}
+
+func TestChatsAreProcessedInParallel(t *testing.T) {
+	b := newBot(t, "")
+	released := make(chan struct{})
+	b.Handle(tele.OnText, func(c tele.Context) error {
+		if c.Chat().ID == 1 {
+			<-released
+		} else {
+			close(released)
+		}
+		return nil
+	})
+
+	poller := &chanPoller{in: make(chan tele.Update)}
+	d := New(nopLogger{}, poller, 1, 0)
+	stop := run(t, d, b)
+
+	poller.in <- message(1, 1, "wait")
+	poller.in <- message(2, 2, "release")
+	select {
+	case <-released:
+	case <-time.After(waitTimeout):
+		t.Fatal("chat 2 is blocked by chat 1")
+	}
+	stop()
+}
+
+func TestWorkerIsRemovedWhenQueueIsEmpty(t *testing.T) {
+	b := newBot(t, "")
+	b.Handle(tele.OnText, func(c tele.Context) error { return nil })
+
+	poller := &chanPoller{in: make(chan tele.Update)}
+	d := New(nopLogger{}, poller, 1, 0)
+	stop := run(t, d, b)
+	defer stop()
+
+	for i := 1; i <= 3; i++ {
+		poller.in <- message(i, 1, "text")
+		waitFor(t, func() bool {
+			stats := d.Stats()
+			return stats.Processed == uint64(i) && stats.Chats == 0
+		})
+	}
+}
+
+func TestFullQueueAnswersBusy(t *testing.T) {
+	api := newAPIServer(t)
+	b := newBot(t, api.URL)
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	b.Handle(tele.OnText, func(c tele.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+
+	poller := &chanPoller{in: make(chan tele.Update)}
+	d := New(nopLogger{}, poller, 1, 0)
+	stop := run(t, d, b)
+
+	// First update blocks worker, second one fills queue
+	poller.in <- message(1, 1, "first")
+	<-started
+	poller.in <- message(2, 1, "queued")
+
+	// Chat gets one busy message for all dropped messages, but every callback is answered
+	poller.in <- message(3, 1, "dropped")
+	poller.in <- message(4, 1, "dropped")
+	poller.in <- callback(5, 1)
+	poller.in <- callback(6, 1)
+	waitFor(t, func() bool { return d.Stats().Dropped == 4 })
+
+	close(release)
+	stop()
+
+	if n := api.count("sendMessage"); n != 1 {
+		t.Errorf("sent %d busy messages, want 1", n)
+	}
+	if n := api.count("answerCallbackQuery"); n != 2 {
+		t.Errorf("answered %d callbacks, want 2", n)
+	}
+	if stats := d.Stats(); stats.Processed != 2 {
+		t.Errorf("processed %d updates, want 2", stats.Processed)
+	}
+}
+
+func TestStopProcessesUpdatesSentAfterStop(t *testing.T) {
+	const afterStop = 10
+
+	b := newBot(t, "")
+	var mu sync.Mutex
+	processed := 0
+	b.Handle(tele.OnText, func(c tele.Context) error {
+		mu.Lock()
+		processed++
+		mu.Unlock()
+		return nil
+	})
+
+	// Updates don't fit into buffer of dispatcher, so poller blocks until they are taken
+	poller := &chanPoller{in: make(chan tele.Update)}
+	for i := 1; i <= afterStop; i++ {
+		poller.afterStop = append(poller.afterStop, message(i, int64(i), "text"))
+	}
+	d := New(nopLogger{}, poller, 1, 0)
+	run(t, d, b)()
+
+	if processed != afterStop {
+		t.Errorf("processed %d updates, want %d", processed, afterStop)
+	}
+}
@@ -0,0 +1,11 @@
+package dto
+
+type AddContributionDTO struct {
+	ChatID      int64
+	UserID      int64
+	Username    string
+	FirstName   string
+	LastName    string
+	Money       int
+	SessionName string
+}
@@ -1,9 +1,20 @@
 package dto
 
+import "collector-telegram-bot/internal"
+
 type AddExpenseDTO struct {
-	Product  string
-	ChatID   int64
-	UserID   int64
-	Username string
-	Cost     int
+	Product   string
+	ChatID    int64
+	UserID    int64
+	Username  string
+	FirstName string
+	LastName  string
+	Cost      int
+	FromPot   bool
+	// GuestName is set when expense is paid by guest and recorded by user
+	GuestName string
+	// SessionName selects one of active sessions of chat, default session is used if it is empty
+	SessionName string
+	// SessionUUID is set when expense is sent from private chat to chosen session
+	SessionUUID internal.UUID
 }
@@ -0,0 +1,11 @@
+package dto
+
+type AddGuestDTO struct {
+	ChatID      int64
+	UserID      int64
+	Username    string
+	FirstName   string
+	LastName    string
+	GuestName   string
+	SessionName string
+}
@@ -0,0 +1,8 @@
+package dto
+
+type AddRequisiteDTO struct {
+	UserID int64
+	Kind   string
+	Value  string
+	Bank   string
+}
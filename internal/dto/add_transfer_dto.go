@@ -0,0 +1,14 @@
+package dto
+
+type AddTransferDTO struct {
+	ChatID            int64
+	UserID            int64
+	Username          string
+	FirstName         string
+	LastName          string
+	RecipientUsername string
+	// RecipientTgID is set when recipient is mentioned without username
+	RecipientTgID int64
+	Money         int
+	SessionName   string
+}
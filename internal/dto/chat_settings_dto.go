@@ -0,0 +1,18 @@
+package dto
+
+import "collector-telegram-bot/internal/models"
+
+type GetChatSettingsDTO struct {
+	ChatID int64
+}
+
+type SaveChatSettingsDTO struct {
+	UserID   int64
+	IsAdmin  bool
+	Settings *models.ChatSettings
+}
+
+type SetRemindDTO struct {
+	UserID int64
+	Remind bool
+}
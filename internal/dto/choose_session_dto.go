@@ -0,0 +1,8 @@
+package dto
+
+import "collector-telegram-bot/internal"
+
+type ChooseSessionDTO struct {
+	UserID      int64
+	SessionUUID internal.UUID
+}
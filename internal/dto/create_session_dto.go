@@ -3,6 +3,10 @@ package dto
 type CreateSessionDTO struct {
 	UserID      int64
 	ChatID      int64
+	ChatTitle   string
 	Username    string
+	FirstName   string
+	LastName    string
 	SessionName string
+	Pot         bool
 }
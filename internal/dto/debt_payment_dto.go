@@ -0,0 +1,15 @@
+package dto
+
+type GetDebtsToPayDTO struct {
+	UserID int64
+}
+
+type MarkDebtPaidDTO struct {
+	UserID int64
+	DebtID uint64
+}
+
+type SetNotifyDTO struct {
+	UserID int64
+	Notify bool
+}
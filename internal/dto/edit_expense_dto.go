@@ -0,0 +1,16 @@
+package dto
+
+type EditExpenseDTO struct {
+	ChatID    int64
+	UserID    int64
+	Username  string
+	FirstName string
+	LastName  string
+	// CostID is number of expense shown in list of expenses
+	CostID      uint64
+	Product     string
+	Cost        int
+	SessionName string
+	// IsAdmin is set when user is administrator of chat
+	IsAdmin bool
+}
@@ -1,5 +1,9 @@
 package dto
 
 type FinishSessionDTO struct {
-	ChatID int64
+	ChatID      int64
+	UserID      int64
+	SessionName string
+	// IsAdmin is set when user is administrator of chat
+	IsAdmin bool
 }
@@ -0,0 +1,5 @@
+package dto
+
+type GetDashboardDTO struct {
+	UserID int64
+}
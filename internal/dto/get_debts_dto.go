@@ -1,5 +1,7 @@
 package dto
 
 type GetDebtsDTO struct {
-	ChatID int64
+	ChatID      int64
+	UserID      int64
+	SessionName string
 }
@@ -0,0 +1,10 @@
+package dto
+
+import "collector-telegram-bot/internal"
+
+type GetMembersDTO struct {
+	ChatID      int64
+	UserID      int64
+	SessionName string
+	SessionUUID internal.UUID
+}
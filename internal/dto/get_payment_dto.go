@@ -0,0 +1,11 @@
+package dto
+
+import "collector-telegram-bot/internal"
+
+type GetPaymentDTO struct {
+	ChatID      int64
+	SessionUUID internal.UUID
+	UserID      int64
+	CreditorID  uint64
+	DebtorID    uint64
+}
@@ -0,0 +1,5 @@
+package dto
+
+type GetRequisitesDTO struct {
+	UserID int64
+}
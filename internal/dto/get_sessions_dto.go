@@ -0,0 +1,13 @@
+package dto
+
+import "collector-telegram-bot/internal"
+
+type GetSessionsDTO struct {
+	UserID int64
+	Page   int
+}
+
+type GetSessionDetailsDTO struct {
+	UserID      int64
+	SessionUUID internal.UUID
+}
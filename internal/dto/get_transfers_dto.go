@@ -0,0 +1,7 @@
+package dto
+
+type GetTransfersDTO struct {
+	ChatID      int64
+	UserID      int64
+	SessionName string
+}
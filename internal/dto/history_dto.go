@@ -0,0 +1,12 @@
+package dto
+
+type GetHistoryDTO struct {
+	ChatID int64
+}
+
+type ShowSessionDTO struct {
+	ChatID int64
+	// Number is position of session in history starting from 1, it is used if session name is empty
+	Number      int
+	SessionName string
+}
@@ -0,0 +1,15 @@
+package dto
+
+type GetInviteDTO struct {
+	ChatID      int64
+	UserID      int64
+	SessionName string
+}
+
+type JoinByInviteDTO struct {
+	UserID    int64
+	Username  string
+	FirstName string
+	LastName  string
+	Token     string
+}
@@ -0,0 +1,13 @@
+package dto
+
+import "collector-telegram-bot/internal"
+
+type JoinSessionDTO struct {
+	ChatID      int64
+	UserID      int64
+	Username    string
+	FirstName   string
+	LastName    string
+	SessionName string
+	SessionUUID internal.UUID
+}
@@ -0,0 +1,10 @@
+package dto
+
+type LeaveSessionDTO struct {
+	ChatID      int64
+	UserID      int64
+	Username    string
+	FirstName   string
+	LastName    string
+	SessionName string
+}
@@ -0,0 +1,16 @@
+package dto
+
+type ManageMemberDTO struct {
+	ChatID         int64
+	UserID         int64
+	Username       string
+	FirstName      string
+	LastName       string
+	MemberUsername string
+	// MemberTgID is set when member is mentioned without username
+	MemberTgID    int64
+	MemberIsGuest bool
+	SessionName   string
+	// IsAdmin is set when user is administrator of chat
+	IsAdmin bool
+}
@@ -0,0 +1,7 @@
+package dto
+
+type ManageRequisiteDTO struct {
+	UserID int64
+	// Number is position of requisite in user's list, starting from 1
+	Number int
+}
@@ -0,0 +1,15 @@
+package dto
+
+type MergeGuestDTO struct {
+	ChatID    int64
+	UserID    int64
+	Username  string
+	FirstName string
+	LastName  string
+	GuestName string
+	// Member is user who becomes guest, sender if not set
+	MemberUsername string
+	MemberTgID     int64
+	SessionName    string
+	IsAdmin        bool
+}
@@ -0,0 +1,15 @@
+package dto
+
+type GetNettingsDTO struct {
+	UserID int64
+}
+
+type ProposeNettingDTO struct {
+	UserID         int64
+	CounterpartyID uint64
+}
+
+type ManageNettingDTO struct {
+	UserID    int64
+	NettingID uint64
+}
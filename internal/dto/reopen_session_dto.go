@@ -0,0 +1,11 @@
+package dto
+
+type ReopenSessionDTO struct {
+	ChatID    int64
+	UserID    int64
+	Username  string
+	FirstName string
+	LastName  string
+	// IsAdmin is set when user is administrator of chat
+	IsAdmin bool
+}
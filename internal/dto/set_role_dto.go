@@ -0,0 +1,22 @@
+package dto
+
+type SetRoleDTO struct {
+	ChatID         int64
+	UserID         int64
+	Username       string
+	FirstName      string
+	LastName       string
+	SessionName    string
+	MemberUsername string
+	// MemberTgID is set when member is mentioned without username
+	MemberTgID int64
+	Role       string
+}
+
+type SetAdminsTreasurersDTO struct {
+	ChatID  int64
+	UserID  int64
+	Enabled bool
+	// IsAdmin is set when user is administrator of chat
+	IsAdmin bool
+}
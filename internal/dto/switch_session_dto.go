@@ -0,0 +1,16 @@
+package dto
+
+type GetChatSessionsDTO struct {
+	ChatID int64
+	UserID int64
+}
+
+type SwitchSessionDTO struct {
+	ChatID      int64
+	UserID      int64
+	SessionName string
+	// Personal switches session only for user, otherwise for whole chat
+	Personal bool
+	// IsAdmin is set when user is administrator of chat
+	IsAdmin bool
+}
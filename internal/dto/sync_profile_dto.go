@@ -0,0 +1,9 @@
+package dto
+
+type SyncProfileDTO struct {
+	UserID       int64
+	Username     string
+	FirstName    string
+	LastName     string
+	LanguageCode string
+}
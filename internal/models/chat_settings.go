@@ -0,0 +1,34 @@
+package models
+
+const (
+	DefaultRemindIntervalDays = 3
+	DefaultRemindMax          = 3
+	DefaultAutoFinishGrace    = 2
+)
+
+// ChatSettings are settings of group chat, chat without saved settings uses defaults.
+type ChatSettings struct {
+	ChatID             int64
+	RemindEnabled      bool
+	RemindIntervalDays int
+	RemindMax          int
+	// RemindInGroup sends reminders to group chat instead of debtor's private chat
+	RemindInGroup bool
+	// AdminsAreTreasurers gives chat administrators rights of treasurer in every session of chat
+	AdminsAreTreasurers bool
+	// AutoFinishDays is count of days without expenses, after which session is warned, 0 turns it off
+	AutoFinishDays int
+	// AutoFinishGraceDays is count of days after warning, after which session is finished
+	AutoFinishGraceDays int
+}
+
+func NewChatSettings(chatID int64) *ChatSettings {
+	return &ChatSettings{
+		ChatID:              chatID,
+		RemindEnabled:       true,
+		RemindIntervalDays:  DefaultRemindIntervalDays,
+		RemindMax:           DefaultRemindMax,
+		AdminsAreTreasurers: true,
+		AutoFinishGraceDays: DefaultAutoFinishGrace,
+	}
+}
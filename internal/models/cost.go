@@ -1,8 +1,9 @@
 package models
 
 type Cost struct {
-	UserID uint64
-	Money  int
+	UserID  uint64
+	Money   int
+	FromPot bool
 }
 
 func NewEmptyCost() *Cost {
@@ -0,0 +1,65 @@
+package models
+
+import "sort"
+
+// SessionDebt is part of debt to counterparty made in one session,
+// positive Money is owed to user, negative is owed by user.
+type SessionDebt struct {
+	Session *Session
+	Money   int
+}
+
+type CounterpartyDebts struct {
+	User *User
+	// Balance is positive when counterparty owes user
+	Balance int
+	Debts   []SessionDebt
+}
+
+// Dashboard contains all unsettled debts of user grouped by counterparty.
+type Dashboard struct {
+	OwedToUser     int
+	OwedByUser     int
+	Counterparties []*CounterpartyDebts
+}
+
+// AddDebt adds debt with counterparty, money sign is the same as in SessionDebt.
+func (d *Dashboard) AddDebt(counterparty *User, session *Session, money int) {
+	var debts *CounterpartyDebts
+	for _, curDebts := range d.Counterparties {
+		if curDebts.User.ID == counterparty.ID {
+			debts = curDebts
+			break
+		}
+	}
+	if debts == nil {
+		debts = &CounterpartyDebts{User: counterparty}
+		d.Counterparties = append(d.Counterparties, debts)
+	}
+
+	debts.Balance += money
+	debts.Debts = append(debts.Debts, SessionDebt{Session: session, Money: money})
+}
+
+// Finalize counts totals and sorts counterparties by size of debt.
+func (d *Dashboard) Finalize() {
+	d.OwedToUser, d.OwedByUser = 0, 0
+	for _, debts := range d.Counterparties {
+		if debts.Balance > 0 {
+			d.OwedToUser += debts.Balance
+		} else {
+			d.OwedByUser -= debts.Balance
+		}
+	}
+
+	sort.Slice(d.Counterparties, func(i, j int) bool {
+		return abs(d.Counterparties[i].Balance) > abs(d.Counterparties[j].Balance)
+	})
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
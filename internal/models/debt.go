@@ -0,0 +1,20 @@
+package models
+
+const (
+	DebtPending = "pending"
+	DebtPayed   = "payed"
+)
+
+// Debt is debt between session members, which is saved when session is finished.
+type Debt struct {
+	ID       uint64
+	Session  *Session
+	Creditor *User
+	Debtor   *User
+	Money    int
+	Status   string
+}
+
+func NewEmptyDebt() *Debt {
+	return &Debt{Session: NewEmptySession(), Creditor: NewUser(), Debtor: NewUser()}
+}
@@ -1,18 +1,20 @@
 package models
 
 type Expanse struct {
-	Username    string
+	ID          uint64
+	User        *User
 	Cost        int
 	Description string
+	FromPot     bool
 }
 
 func NewEmptyExpanse() *Expanse {
-	return &Expanse{}
+	return &Expanse{User: NewUser()}
 }
 
-func NewExpanse(username string, cost int, description string) *Expanse {
+func NewExpanse(user *User, cost int, description string) *Expanse {
 	return &Expanse{
-		Username:    username,
+		User:        user,
 		Cost:        cost,
 		Description: description,
 	}
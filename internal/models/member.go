@@ -2,10 +2,19 @@ package models
 
 import "collector-telegram-bot/internal"
 
+const (
+	RoleCreator   = "creator"
+	RoleTreasurer = "treasurer"
+	RoleMember    = "member"
+	// RoleObserver sees session, but doesn't share its expenses and can't record anything
+	RoleObserver = "observer"
+)
+
 type Member struct {
 	ID          uint64
 	SessionUUID internal.UUID
 	UserID      uint64
+	Role        string
 }
 
 func NewEmptyMember() *Member {
@@ -17,5 +26,22 @@ func NewMember(sessionUUID internal.UUID, userID uint64) *Member {
 		ID:          0,
 		SessionUUID: sessionUUID,
 		UserID:      userID,
+		Role:        RoleMember,
 	}
 }
+
+// CanManage checks that member can finish session and manage its members.
+func (m *Member) CanManage() bool {
+	return m.Role == RoleCreator || m.Role == RoleTreasurer
+}
+
+// SessionMember is user with his role in session.
+type SessionMember struct {
+	User *User
+	Role string
+}
+
+// Participates checks that member shares expenses of session.
+func (m *SessionMember) Participates() bool {
+	return m.Role != RoleObserver
+}
@@ -0,0 +1,51 @@
+package models
+
+const (
+	NettingProposed = "proposed"
+	NettingDone     = "done"
+	NettingDeclined = "declined"
+)
+
+// Netting replaces offsetting debts between two users from different sessions
+// by single transfer. It is done when both users confirm it.
+type Netting struct {
+	ID         uint64
+	FirstUser  *User
+	SecondUser *User
+	// Money is positive when second user pays to first one
+	Money           int
+	FirstConfirmed  bool
+	SecondConfirmed bool
+	Status          string
+	Debts           []*Debt
+}
+
+func NewEmptyNetting() *Netting {
+	return &Netting{FirstUser: NewUser(), SecondUser: NewUser()}
+}
+
+// Counterparty returns other user of netting for one of its users.
+func (n *Netting) Counterparty(userID uint64) *User {
+	if n.FirstUser.ID == userID {
+		return n.SecondUser
+	}
+	return n.FirstUser
+}
+
+// BalanceFor returns netting sum from user's side: positive when user receives money.
+func (n *Netting) BalanceFor(userID uint64) int {
+	if n.FirstUser.ID == userID {
+		return n.Money
+	}
+	return -n.Money
+}
+
+// Confirm marks confirmation of one of netting users.
+func (n *Netting) Confirm(userID uint64) {
+	switch userID {
+	case n.FirstUser.ID:
+		n.FirstConfirmed = true
+	case n.SecondUser.ID:
+		n.SecondConfirmed = true
+	}
+}
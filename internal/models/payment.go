@@ -0,0 +1,42 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Payment is debt which debtor pays to creditor's requisite.
+type Payment struct {
+	Creditor  *User
+	Requisite *Requisite
+	Money     int
+	Purpose   string
+}
+
+// Payload returns plain text of transfer for QR code. Requisite is only card number or phone,
+// bank account and BIC needed for ST00012 format of bank apps are unknown, so bank app can't
+// fill transfer by itself, but any scanner shows text, from which requisite is copied.
+func (p *Payment) Payload() string {
+	name := strings.TrimSpace(p.Creditor.FirstName + " " + p.Creditor.LastName)
+	if name == "" {
+		name = p.Creditor.DisplayName()
+	}
+
+	kind := "Карта"
+	if p.Requisite.Kind == RequisitePhone {
+		kind = "СБП по номеру телефона"
+	}
+
+	lines := []string{
+		"Получатель: " + name,
+		fmt.Sprintf("%s: %s", kind, p.Requisite.Value),
+	}
+	if p.Requisite.Bank != "" {
+		lines = append(lines, "Банк: "+p.Requisite.Bank)
+	}
+	lines = append(lines, fmt.Sprintf("Сумма: %d руб.", p.Money))
+	if p.Purpose != "" {
+		lines = append(lines, "Назначение: "+p.Purpose)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,39 @@
+package models
+
+import "testing"
+
+func TestPaymentPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		payment Payment
+		want    string
+	}{
+		{
+			name: "card",
+			payment: Payment{
+				Creditor:  &User{FirstName: "Иван", LastName: "Петров", Username: "ivan"},
+				Requisite: &Requisite{Kind: RequisiteCard, Value: "4111111111111111", Bank: "Тинькофф"},
+				Money:     500,
+				Purpose:   "Долг за Поездка",
+			},
+			want: "Получатель: Иван Петров\nКарта: 4111111111111111\nБанк: Тинькофф\nСумма: 500 руб.\n" +
+				"Назначение: Долг за Поездка",
+		},
+		{
+			name: "phone without bank and purpose",
+			payment: Payment{
+				Creditor:  &User{TgID: 42, Username: "ivan"},
+				Requisite: &Requisite{Kind: RequisitePhone, Value: "+79991234567"},
+				Money:     1,
+			},
+			want: "Получатель: @ivan\nСБП по номеру телефона: +79991234567\nСумма: 1 руб.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.payment.Payload(); got != tt.want {
+				t.Errorf("Payload() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
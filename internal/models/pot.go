@@ -0,0 +1,27 @@
+package models
+
+type Contribution struct {
+	UserID uint64
+	Money  int
+}
+
+func NewEmptyContribution() *Contribution {
+	return &Contribution{}
+}
+
+// PotMemberBalance describes member's position against the pot:
+// positive Balance means refund from the pot, negative means top-up.
+type PotMemberBalance struct {
+	User        *User
+	Contributed int
+	Spent       int
+	Share       int
+	Balance     int
+}
+
+type PotState struct {
+	Contributed int
+	Spent       int
+	Balance     int
+	Members     map[uint64]PotMemberBalance
+}
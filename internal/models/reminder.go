@@ -0,0 +1,13 @@
+package models
+
+// Reminder is scheduled nudge to debtor about pending debt.
+type Reminder struct {
+	ID     uint64
+	DebtID uint64
+	ChatID int64
+	Sent   int
+	// DebtPending is false when debt is already paid, then reminder is dropped
+	DebtPending bool
+	// DebtorRemind is false when debtor has turned off reminders
+	DebtorRemind bool
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	RequisitePhone = "phone"
+	RequisiteCard  = "card"
+)
+
+type Requisite struct {
+	ID     uint64
+	UserID uint64
+	Kind   string
+	// Value is empty until requisite is revealed for its owner or debtors,
+	// in db it is stored only as Encrypted
+	Value     string
+	Encrypted string
+	// EncryptedFor is previous owner, which value is still bound to after merge of duplicate users,
+	// it is 0 when value is bound to UserID
+	EncryptedFor uint64
+	Bank         string
+	IsDefault    bool
+}
+
+func NewEmptyRequisite() *Requisite {
+	return &Requisite{}
+}
+
+// NormalizePhone brings russian phone number to +7XXXXXXXXXX form.
+func NormalizePhone(phone string) (string, bool) {
+	digits := onlyDigits(phone)
+	if len(digits) == 11 && (digits[0] == '7' || digits[0] == '8') {
+		return "+7" + digits[1:], true
+	}
+	return "", false
+}
+
+// NormalizeCard removes separators from card number and checks it with Luhn algorithm.
+func NormalizeCard(card string) (string, bool) {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(card)
+	if len(digits) < 16 || len(digits) > 19 || onlyDigits(digits) != digits {
+		return "", false
+	}
+
+	sum := 0
+	for i := range digits {
+		digit := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return digits, sum%10 == 0
+}
+
+func onlyDigits(value string) string {
+	var digits strings.Builder
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	return digits.String()
+}
+
+// Masked hides card number except last digits. Phone is shown as is,
+// because it is needed for SBP transfer and is usually known to chat members.
+func (r *Requisite) Masked() string {
+	if r.Kind == RequisiteCard && len(r.Value) > 4 {
+		return "•••• " + r.Value[len(r.Value)-4:]
+	}
+	return r.Value
+}
+
+// Format returns requisite description with given (full or masked) value.
+func (r *Requisite) Format(value string) string {
+	if r.Kind == RequisitePhone {
+		return fmt.Sprintf("СБП %s %s", r.Bank, value)
+	}
+	return fmt.Sprintf("карта %s %s", r.Bank, value)
+}
@@ -0,0 +1,65 @@
+package models
+
+import "testing"
+
+func TestNormalizeCard(t *testing.T) {
+	tests := []struct {
+		card  string
+		want  string
+		valid bool
+	}{
+		{card: "4111111111111111", want: "4111111111111111", valid: true},
+		{card: "4111 1111 1111 1111", want: "4111111111111111", valid: true},
+		{card: "2200-7000-1234-5678", want: "2200700012345678", valid: false},
+		{card: "2200 7000 1234 5673", want: "2200700012345673", valid: true},
+		// 19 digits, doubled digits above 9 are reduced
+		{card: "6762 0000 0000 0000 009", want: "6762000000000000009", valid: true},
+		{card: "4111111111111112", want: "4111111111111112", valid: false},
+		{card: "411111111111111", valid: false},
+		{card: "41111111111111111111", valid: false},
+		{card: "4111 1111 1111 111a", valid: false},
+		{card: "4111.1111.1111.1111", valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.card, func(t *testing.T) {
+			got, valid := NormalizeCard(tt.card)
+			if valid != tt.valid || (tt.want != "" && got != tt.want) {
+				t.Errorf("NormalizeCard(%q) = %q, %v, want %q, %v", tt.card, got, valid, tt.want, tt.valid)
+			}
+		})
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string
+		valid bool
+	}{
+		{phone: "+7 (999) 123-45-67", want: "+79991234567", valid: true},
+		{phone: "89991234567", want: "+79991234567", valid: true},
+		{phone: "79991234567", want: "+79991234567", valid: true},
+		{phone: "9991234567", valid: false},
+		{phone: "+1 999 123 45 67", valid: false},
+		{phone: "+7 999 123 45 678", valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phone, func(t *testing.T) {
+			got, valid := NormalizePhone(tt.phone)
+			if got != tt.want || valid != tt.valid {
+				t.Errorf("NormalizePhone(%q) = %q, %v, want %q, %v", tt.phone, got, valid, tt.want, tt.valid)
+			}
+		})
+	}
+}
+
+func TestRequisiteMasked(t *testing.T) {
+	card := Requisite{Kind: RequisiteCard, Value: "4111111111111111"}
+	if got := card.Masked(); got != "•••• 1111" {
+		t.Errorf("Masked() = %q, want card with last digits", got)
+	}
+	phone := Requisite{Kind: RequisitePhone, Value: "+79991234567"}
+	if got := phone.Masked(); got != "+79991234567" {
+		t.Errorf("Masked() = %q, want full phone", got)
+	}
+}
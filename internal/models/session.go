@@ -1,16 +1,32 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
 
-const SessionActive = "active"
+	"github.com/google/uuid"
+)
+
+const (
+	SessionActive = "active"
+	// SessionSettling is finished session, which debts aren't paid yet, it is closed after last payment
+	SessionSettling = "settling"
+	SessionClosed   = "closed"
+
+	SessionModeRegular = "regular"
+	SessionModePot     = "pot"
+)
 
 type Session struct {
 	UUID        uuid.UUID
 	CreatorID   uint64
 	ChatID      int64
 	SessionName string
+	ChatTitle   string
 	StartedAt   string
-	State       string
+	// FinishedAt is empty for active session
+	FinishedAt string
+	State      string
+	Mode       string
 }
 
 func NewSession(UUID uuid.UUID, creatorID uint64, chatID int64, sessionName string) *Session {
@@ -20,9 +36,66 @@ func NewSession(UUID uuid.UUID, creatorID uint64, chatID int64, sessionName stri
 		ChatID:      chatID,
 		SessionName: sessionName,
 		State:       SessionActive,
+		Mode:        SessionModeRegular,
 	}
 }
 
 func NewEmptySession() *Session {
 	return &Session{}
 }
+
+// SessionSummary is user's result in one of his sessions.
+type SessionSummary struct {
+	Session *Session
+	// Spent is sum of user's own expenses
+	Spent int
+	// Balance is positive when others owe user and negative when user owes
+	Balance int
+}
+
+// ArchivedSession is finished session in history of chat.
+type ArchivedSession struct {
+	Session *Session
+	// Total is sum of all expenses of session
+	Total   int
+	Members int
+}
+
+// SessionArchive is final result of finished session.
+type SessionArchive struct {
+	Session *Session
+	Costs   map[uint64]AllUserCosts
+	// Debts are saved at finish of regular session, pot session has Pot settlement instead
+	Debts []*Debt
+	Pot   *PotState
+}
+
+type SessionsPage struct {
+	Summaries []*SessionSummary
+	Page      int
+	Pages     int
+}
+
+// SessionDetails is session as it is seen by one of its members.
+type SessionDetails struct {
+	Summary  *SessionSummary
+	Expenses []*Expanse
+	// Debts are user's debts in regular session, user is either creditor or debtor
+	Debts []UserSessionDebt
+}
+
+type UserSessionDebt struct {
+	Creditor *User
+	Debtor   *User
+	Money    int
+}
+
+// InactiveSession is active session without expenses for longer, than chat allows.
+type InactiveSession struct {
+	Session        *Session
+	InactiveDays   int
+	GraceDays      int
+	LastActivityAt time.Time
+	// WarnedAt is zero, when chat hasn't been warned yet
+	WarnedAt time.Time
+}
@@ -0,0 +1,21 @@
+package models
+
+type Transfer struct {
+	SenderID    uint64
+	RecipientID uint64
+	Money       int
+}
+
+func NewEmptyTransfer() *Transfer {
+	return &Transfer{}
+}
+
+type UserTransfer struct {
+	Sender    *User
+	Recipient *User
+	Money     int
+}
+
+func NewEmptyUserTransfer() *UserTransfer {
+	return &UserTransfer{Sender: NewUser(), Recipient: NewUser()}
+}
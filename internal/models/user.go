@@ -1,13 +1,53 @@
 package models
 
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
 type User struct {
-	ID         uint64
-	Username   string
-	CreatedAt  string
-	Requisites string
-	TgID       int64
+	ID        uint64
+	Username  string
+	FirstName string
+	LastName  string
+	// LanguageCode is IETF language tag of user's telegram client
+	LanguageCode string
+	CreatedAt    string
+	Requisites   string
+	// TgID is zero for guests, who don't have telegram account
+	TgID int64
+	// GuestCreatorID is user who added guest, zero for users and for guests added before it was saved
+	GuestCreatorID uint64
+	// Notify is set when user wants personal notifications in private chat
+	Notify bool
 }
 
 func NewUser() *User {
 	return &User{}
 }
+
+func (u *User) IsGuest() bool {
+	return u.TgID == 0
+}
+
+// DisplayName returns @username if user has it, otherwise full name.
+// Guest's name is stored as username.
+func (u *User) DisplayName() string {
+	switch {
+	case u.IsGuest():
+		return u.Username
+	case u.Username != "":
+		return "@" + u.Username
+	default:
+		return strings.TrimSpace(u.FirstName + " " + u.LastName)
+	}
+}
+
+// Mention returns HTML link to user, which works even without username.
+func (u *User) Mention() string {
+	if u.IsGuest() {
+		return html.EscapeString(u.DisplayName())
+	}
+	return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>`, u.TgID, html.EscapeString(u.DisplayName()))
+}
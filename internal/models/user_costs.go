@@ -3,11 +3,15 @@ package models
 import "sort"
 
 type UserCost struct {
+	// ID is number of expense, by which it is edited
+	ID          uint64
 	Money       int
 	Description string
+	FromPot     bool
 }
 
 type AllUserCosts struct {
+	User  *User
 	Sum   int
 	Costs []UserCost
 }
@@ -3,12 +3,15 @@ package models
 import "sort"
 
 type UserDebt struct {
-	DebtorName string
-	Money      int
+	Debtor *User
+	Money  int
 }
 
 type AllUserDebts struct {
-	Debts []UserDebt
+	Creditor *User
+	// Requisite is creditor's preferred requisite, nil if he has none
+	Requisite *Requisite
+	Debts     []UserDebt
 }
 
 func (d *AllUserDebts) SortByDebt() {
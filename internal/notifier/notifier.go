@@ -0,0 +1,89 @@
+// Package notifier delivers personal messages to users in private chat with bot.
+package notifier
+
+import (
+	"collector-telegram-bot/internal"
+	"errors"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+const (
+	queueSize   = 256
+	maxAttempts = 3
+	retryDelay  = time.Second
+)
+
+// Notifier sends message to chat asynchronously, so usecases don't wait for telegram.
+// Private chat with user has the same id as user.
+type Notifier interface {
+	Notify(chatID int64, text string)
+}
+
+type notification struct {
+	chatID  int64
+	text    string
+	attempt int
+	// delay is wait before next attempt, it grows after every failure
+	delay time.Duration
+}
+
+type TgNotifier struct {
+	log   internal.Logger
+	bot   *tele.Bot
+	queue chan notification
+}
+
+func New(log internal.Logger, bot *tele.Bot) *TgNotifier {
+	return &TgNotifier{log: log, bot: bot, queue: make(chan notification, queueSize)}
+}
+
+// Notify puts HTML message to queue, message is dropped when queue is full.
+func (n *TgNotifier) Notify(chatID int64, text string) {
+	n.enqueue(notification{chatID: chatID, text: text, attempt: 1, delay: retryDelay})
+}
+
+func (n *TgNotifier) enqueue(msg notification) {
+	select {
+	case n.queue <- msg:
+	default:
+		n.log.Warnf("Notification queue is full, message to %d is dropped", msg.chatID)
+	}
+}
+
+// Run sends queued messages one by one, it must be started in separate goroutine.
+func (n *TgNotifier) Run() {
+	for msg := range n.queue {
+		n.send(msg)
+	}
+}
+
+// send makes one attempt to deliver message. Failed message is queued again after growing delay,
+// so retries don't hold other messages, except errors which won't go away.
+func (n *TgNotifier) send(msg notification) {
+	_, err := n.bot.Send(&tele.Chat{ID: msg.chatID}, msg.text, tele.ModeHTML)
+	switch {
+	case err == nil:
+		return
+	case errors.Is(err, tele.ErrBlockedByUser) || errors.Is(err, tele.ErrNotStartedByUser) ||
+		errors.Is(err, tele.ErrUserIsDeactivated) || errors.Is(err, tele.ErrChatNotFound):
+		n.log.Infof("Notification to %d is not delivered: %v", msg.chatID, err)
+		return
+	case msg.attempt == maxAttempts:
+		n.log.Warnf("Notification to %d is not delivered after %d attempts: %v", msg.chatID, msg.attempt, err)
+		return
+	}
+
+	// Telegram tells how long to wait when messages are sent too often
+	delay := msg.delay
+	var floodErr tele.FloodError
+	if errors.As(err, &floodErr) {
+		delay = time.Duration(floodErr.RetryAfter) * time.Second
+	}
+	msg.attempt++
+	msg.delay = delay * 2
+	time.AfterFunc(delay, func() {
+		n.enqueue(msg)
+	})
+}
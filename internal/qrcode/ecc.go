@@ -0,0 +1,68 @@
+package qrcode
+
+// bitBuffer collects bits of data codewords, most significant bit first.
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value int, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, bit(value, i))
+	}
+}
+
+func (b *bitBuffer) len() int {
+	return len(*b)
+}
+
+func (b *bitBuffer) bytes() []byte {
+	result := make([]byte, len(*b)/8)
+	for i, dark := range *b {
+		if dark {
+			result[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+	return result
+}
+
+// reedSolomonDivisor returns coefficients of generator polynomial of given degree,
+// highest term is omitted.
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func reedSolomonRemainder(data []byte, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies elements of GF(2^8) with modulus x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		if bit(int(y), i) {
+			z ^= int(x)
+		}
+	}
+	return byte(z)
+}
@@ -0,0 +1,88 @@
+package qrcode
+
+const (
+	penaltyRun     = 3
+	penaltyBlock   = 3
+	penaltyFinder  = 40
+	penaltyBalance = 10
+
+	// finderQuietZone is light area of finder-like pattern
+	finderQuietZone = 4
+)
+
+// finderLike is 1:1:3:1:1 pattern with 4 light modules on one side, it confuses scanners.
+var finderLike = [][]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// penalty scores masked code by rules of standard, mask with lowest score is used.
+func (q *Code) penalty() int {
+	result := 0
+	for i := 0; i < q.size; i++ {
+		row := make([]bool, q.size)
+		column := make([]bool, q.size)
+		for j := 0; j < q.size; j++ {
+			row[j] = q.modules[i][j]
+			column[j] = q.modules[j][i]
+		}
+		result += linePenalty(row) + linePenalty(column)
+	}
+
+	dark := 0
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size {
+				color := q.modules[y][x]
+				if color == q.modules[y][x+1] && color == q.modules[y+1][x] && color == q.modules[y+1][x+1] {
+					result += penaltyBlock
+				}
+			}
+		}
+	}
+
+	// Every 5% of deviation from half of dark modules
+	total := q.size * q.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	result += k * penaltyBalance
+	return result
+}
+
+func linePenalty(line []bool) int {
+	result := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			result += penaltyRun + run - 5
+		}
+		run = 1
+	}
+
+	// Quiet zone around code is light, so light part of pattern may lie outside of code
+	padded := make([]bool, len(line)+2*finderQuietZone)
+	copy(padded[finderQuietZone:], line)
+	for i := 0; i+len(finderLike[0]) <= len(padded); i++ {
+		for _, pattern := range finderLike {
+			if matches(padded[i:], pattern) {
+				result += penaltyFinder
+			}
+		}
+	}
+	return result
+}
+
+func matches(line []bool, pattern []bool) bool {
+	for i, dark := range pattern {
+		if line[i] != dark {
+			return false
+		}
+	}
+	return true
+}
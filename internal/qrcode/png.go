@@ -0,0 +1,40 @@
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// quietZone is light border around code in modules, required by scanners.
+const quietZone = 4
+
+// PNG encodes text and renders code with given size of module in pixels.
+func PNG(text string, scale int) ([]byte, error) {
+	code, err := Encode([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+
+	side := (code.Size() + quietZone*2) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < code.Size(); y++ {
+		for x := 0; x < code.Size(); x++ {
+			if !code.Dark(x, y) {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex((x+quietZone)*scale+dx, (y+quietZone)*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
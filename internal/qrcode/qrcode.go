@@ -0,0 +1,378 @@
+// Package qrcode encodes text to QR code (ISO/IEC 18004) in byte mode
+// with medium error correction level and renders it to PNG.
+package qrcode
+
+import (
+	"fmt"
+)
+
+const (
+	minVersion = 1
+	maxVersion = 40
+
+	modeByte = 0x4
+)
+
+var TooLongErr = fmt.Errorf("data is too long for qr code")
+
+// Medium error correction level: codewords per block and number of blocks by version.
+var (
+	eccCodewordsPerBlock = [maxVersion + 1]int{-1,
+		10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26,
+		26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28}
+	eccBlocks = [maxVersion + 1]int{-1,
+		1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16,
+		17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49}
+)
+
+// formatLevelBits are error correction level bits of format information for medium level.
+const formatLevelBits = 0x0
+
+// Code is square matrix of modules, true is dark module.
+type Code struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// Encode builds QR code of smallest version which fits data.
+func Encode(data []byte) (*Code, error) {
+	version, err := chooseVersion(len(data))
+	if err != nil {
+		return nil, err
+	}
+
+	code := build(data, version)
+	mask := code.bestMask()
+	code.applyMask(mask)
+	code.drawFormatBits(mask)
+	return code, nil
+}
+
+func chooseVersion(length int) (int, error) {
+	for version := minVersion; version <= maxVersion; version++ {
+		if dataBits(length, version) <= dataCodewords(version)*8 {
+			return version, nil
+		}
+	}
+	return 0, TooLongErr
+}
+
+// build draws function patterns and unmasked data of code.
+func build(data []byte, version int) *Code {
+	code := newCode(version)
+	code.drawFunctionPatterns(version)
+	code.drawCodewords(addEccAndInterleave(encodeData(data, version), version))
+	return code
+}
+
+// bestMask returns mask with the lowest penalty, code is left unmasked.
+func (q *Code) bestMask() int {
+	bestMask, minPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormatBits(mask)
+		penalty := q.penalty()
+		if minPenalty < 0 || penalty < minPenalty {
+			bestMask, minPenalty = mask, penalty
+		}
+		// Mask is xor, second application reverts it
+		q.applyMask(mask)
+	}
+	return bestMask
+}
+
+// Size returns number of modules on side of code.
+func (q *Code) Size() int {
+	return q.size
+}
+
+// Dark reports whether module at column x and row y is dark.
+func (q *Code) Dark(x, y int) bool {
+	return q.modules[y][x]
+}
+
+func newCode(version int) *Code {
+	size := version*4 + 17
+	code := &Code{size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for i := 0; i < size; i++ {
+		code.modules[i] = make([]bool, size)
+		code.function[i] = make([]bool, size)
+	}
+	return code
+}
+
+func charCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+func dataBits(length int, version int) int {
+	return 4 + charCountBits(version) + length*8
+}
+
+// rawDataModules is number of modules available for data and ecc codewords.
+func rawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func dataCodewords(version int) int {
+	return rawDataModules(version)/8 - eccCodewordsPerBlock[version]*eccBlocks[version]
+}
+
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	numAlign := version/7 + 2
+	step := (version*4 + numAlign*2 + 1) / (numAlign*2 - 2) * 2
+	if version == 32 {
+		step = 26
+	}
+
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+// encodeData makes data codewords: byte mode segment, terminator and padding.
+func encodeData(data []byte, version int) []byte {
+	var bits bitBuffer
+	bits.append(modeByte, 4)
+	bits.append(len(data), charCountBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	capacity := dataCodewords(version) * 8
+	terminator := capacity - bits.len()
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-bits.len()%8)%8)
+	for pad := 0xEC; bits.len() < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	return bits.bytes()
+}
+
+func addEccAndInterleave(data []byte, version int) []byte {
+	numBlocks := eccBlocks[version]
+	blockEccLen := eccCodewordsPerBlock[version]
+	rawCodewords := rawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(blockEccLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		datLen := shortBlockLen - blockEccLen
+		if i >= numShortBlocks {
+			datLen++
+		}
+		dat := data[k : k+datLen]
+		k += datLen
+
+		block := make([]byte, 0, shortBlockLen+1)
+		block = append(block, dat...)
+		// Short blocks get placeholder to make all blocks of equal length
+		if i < numShortBlocks {
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, reedSolomonRemainder(dat, divisor)...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortBlockLen-blockEccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+func (q *Code) setFunction(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+func (q *Code) drawFunctionPatterns(version int) {
+	for i := 0; i < q.size; i++ {
+		q.setFunction(6, i, i%2 == 0)
+		q.setFunction(i, 6, i%2 == 0)
+	}
+
+	q.drawFinderPattern(3, 3)
+	q.drawFinderPattern(q.size-4, 3)
+	q.drawFinderPattern(3, q.size-4)
+
+	positions := alignmentPositions(version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// Skip places of finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			q.drawAlignmentPattern(x, y)
+		}
+	}
+
+	// Reserve format modules, real bits are drawn after mask is chosen
+	q.drawFormatBits(0)
+	q.drawVersion(version)
+}
+
+func (q *Code) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= q.size || yy < 0 || yy >= q.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			q.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (q *Code) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			q.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+func (q *Code) drawFormatBits(mask int) {
+	data := formatLevelBits<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	// First copy around top left finder
+	for i := 0; i <= 5; i++ {
+		q.setFunction(8, i, bit(bits, i))
+	}
+	q.setFunction(8, 7, bit(bits, 6))
+	q.setFunction(8, 8, bit(bits, 7))
+	q.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		q.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	// Second copy near top right and bottom left finders
+	for i := 0; i < 8; i++ {
+		q.setFunction(q.size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunction(8, q.size-15+i, bit(bits, i))
+	}
+	q.setFunction(8, q.size-8, true)
+}
+
+func (q *Code) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		a, b := q.size-11+i%3, i/3
+		q.setFunction(a, b, bit(bits, i))
+		q.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// drawCodewords places data in zigzag order of two-module columns from bottom right corner.
+func (q *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		// Vertical timing pattern is skipped
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if !q.function[y][x] && i < len(data)*8 {
+					q.modules[y][x] = bit(int(data[i>>3]), 7-i&7)
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (q *Code) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.function[y][x] {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+func bit(value int, i int) bool {
+	return (value>>i)&1 != 0
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
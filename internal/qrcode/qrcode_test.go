@@ -0,0 +1,234 @@
+package qrcode
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Vectors are rendered by independent encoder at its own mask choice, so code is compared at the same mask.
+var vectors = []struct {
+	name    string
+	data    string
+	version int
+	mask    int
+}{
+	{name: "hello", data: "hello", version: 1, mask: 2},
+	{name: "link", data: "https://t.me/collector_bot?start=abc", version: 3, mask: 3},
+	{name: "cyrillic", data: "Перевод Ивану Петрову", version: 3, mask: 1},
+	{name: "long", data: strings.Repeat("x", 300), version: 13, mask: 0},
+}
+
+// formatM are format information strings of medium error correction level by mask.
+var formatM = [8]string{
+	"101010000010010",
+	"101000100100101",
+	"101111001111100",
+	"101101101001011",
+	"100010111111001",
+	"100000011001110",
+	"100111110010111",
+	"100101010100000",
+}
+
+func render(code *Code) string {
+	var b strings.Builder
+	for y := 0; y < code.Size(); y++ {
+		for x := 0; x < code.Size(); x++ {
+			if code.Dark(x, y) {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func parseCode(rows ...string) *Code {
+	code := &Code{size: len(rows), modules: make([][]bool, len(rows))}
+	for y, row := range rows {
+		code.modules[y] = make([]bool, len(row))
+		for x := range row {
+			code.modules[y][x] = row[x] == '#'
+		}
+	}
+	return code
+}
+
+func masked(data []byte, version int, mask int) *Code {
+	code := build(data, version)
+	code.applyMask(mask)
+	code.drawFormatBits(mask)
+	return code
+}
+
+// formatBits reads both copies of format information.
+func formatBits(code *Code) (int, int) {
+	var first, second int
+	set := func(value *int, i int, x, y int) {
+		if code.Dark(x, y) {
+			*value |= 1 << i
+		}
+	}
+
+	for i := 0; i <= 5; i++ {
+		set(&first, i, 8, i)
+	}
+	set(&first, 6, 8, 7)
+	set(&first, 7, 8, 8)
+	set(&first, 8, 7, 8)
+	for i := 9; i < 15; i++ {
+		set(&first, i, 14-i, 8)
+	}
+
+	for i := 0; i < 8; i++ {
+		set(&second, i, code.Size()-1-i, 8)
+	}
+	for i := 8; i < 15; i++ {
+		set(&second, i, 8, code.Size()-15+i)
+	}
+	return first, second
+}
+
+func TestEncodeVectors(t *testing.T) {
+	for _, tt := range vectors {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := os.ReadFile(filepath.Join("testdata", tt.name+".txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			version, err := chooseVersion(len(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != tt.version {
+				t.Fatalf("chooseVersion() = %d, want %d", version, tt.version)
+			}
+
+			code := masked([]byte(tt.data), version, tt.mask)
+			if got := render(code); got != string(want) {
+				t.Errorf("code differs from vector:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestChooseVersion(t *testing.T) {
+	// Capacity of byte mode with medium error correction level
+	capacities := []struct {
+		version int
+		length  int
+	}{
+		{1, 14}, {2, 26}, {3, 42}, {4, 62}, {5, 84}, {6, 106},
+		{7, 122}, {8, 152}, {9, 180}, {10, 213}, {40, 2331},
+	}
+	for _, tt := range capacities {
+		t.Run(strconv.Itoa(tt.version), func(t *testing.T) {
+			if version, err := chooseVersion(tt.length); err != nil || version != tt.version {
+				t.Errorf("chooseVersion(%d) = %d, %v, want %d", tt.length, version, err, tt.version)
+			}
+			if tt.version == maxVersion {
+				return
+			}
+			if version, err := chooseVersion(tt.length + 1); err != nil || version != tt.version+1 {
+				t.Errorf("chooseVersion(%d) = %d, %v, want %d", tt.length+1, version, err, tt.version+1)
+			}
+		})
+	}
+
+	if _, err := Encode(make([]byte, 2332)); err != TooLongErr {
+		t.Errorf("Encode() err = %v, want %v", err, TooLongErr)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	for mask, format := range formatM {
+		want, err := strconv.ParseInt(format, 2, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range vectors {
+			code := masked([]byte(tt.data), tt.version, mask)
+			if first, second := formatBits(code); first != int(want) || second != int(want) {
+				t.Errorf("mask %d, %s: format bits %015b and %015b, want %s", mask, tt.name, first, second, format)
+			}
+		}
+	}
+}
+
+func TestEncodeChoosesMaskWithLowestPenalty(t *testing.T) {
+	for _, tt := range vectors {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Encode([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bestMask, minPenalty := -1, 0
+			for mask := 0; mask < 8; mask++ {
+				penalty := masked([]byte(tt.data), tt.version, mask).penalty()
+				if bestMask < 0 || penalty < minPenalty {
+					bestMask, minPenalty = mask, penalty
+				}
+			}
+
+			if got := render(code); got != render(masked([]byte(tt.data), tt.version, bestMask)) {
+				t.Errorf("code isn't masked with mask %d of lowest penalty %d", bestMask, minPenalty)
+			}
+		})
+	}
+}
+
+func TestLinePenalty(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want int
+	}{
+		{name: "no runs", line: "#.#.", want: 0},
+		{name: "run of 4", line: "####.", want: 0},
+		{name: "run of 5", line: "#####.", want: penaltyRun},
+		{name: "run of 6", line: ".######", want: penaltyRun + 1},
+		{name: "two runs", line: ".....#####", want: 2 * penaltyRun},
+		// Light modules of quiet zone complete pattern on both sides
+		{name: "finder at edges", line: "#.###.#", want: 2 * penaltyFinder},
+		{name: "finder inside", line: "#....#.###.##", want: penaltyFinder},
+		{name: "finder without light side", line: "##.#.###.#.##", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := make([]bool, len(tt.line))
+			for i := range tt.line {
+				line[i] = tt.line[i] == '#'
+			}
+			if got := linePenalty(line); got != tt.want {
+				t.Errorf("linePenalty(%s) = %d, want %d", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPenalty(t *testing.T) {
+	tests := []struct {
+		name string
+		code *Code
+		want int
+	}{
+		// 5 of 9 modules are dark, deviation from half is 5-10%
+		{name: "balanced", code: parseCode("#.#", ".#.", "#.#"), want: penaltyBalance},
+		{name: "block", code: parseCode("##.", "##.", "..."), want: penaltyBlock + penaltyBalance},
+		{name: "all light", code: parseCode("...", "...", "..."), want: 4*penaltyBlock + 9*penaltyBalance},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.code.penalty(); got != tt.want {
+				t.Errorf("penalty() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,45 +3,171 @@ package repo
 import (
 	"collector-telegram-bot/internal"
 	"collector-telegram-bot/internal/models"
+	"collector-telegram-bot/internal/secret"
 	"database/sql"
 	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const (
-	UserTable     = "users"
-	SessionTable  = "sessions"
-	MembersTable  = "members"
-	CostsTable    = "costs"
-	ClosedSession = "closed"
+	UserTable      = "users"
+	SessionTable   = "sessions"
+	MembersTable   = "members"
+	CostsTable     = "costs"
+	TransferTable  = "transfers"
+	PotTable       = "pot_contributions"
+	DebtsTable     = "debts"
+	HistoryTable   = "user_profile_history"
+	RequisiteTable = "requisites"
+	NettingTable   = "nettings"
+	NettingDebts   = "netting_debts"
+	SettingsTable  = "chat_settings"
+	ReminderTable  = "reminders"
+	DefaultsTable  = "default_sessions"
+
+	// activeNameIndex keeps names of active sessions of chat unique
+	activeNameIndex = "sessions_active_name_idx"
+	uniqueViolation = "23505"
+)
+
+var (
+	// SessionExistsErr is returned, when chat already has active session with same name.
+	SessionExistsErr = fmt.Errorf("active session with same name exists")
+	// SessionFinishedErr is returned, when session has been finished meanwhile.
+	SessionFinishedErr = fmt.Errorf("session is already finished")
+	// NettingExistsErr is returned, when some of debts are waiting for confirmation of other netting.
+	NettingExistsErr = fmt.Errorf("debts are already proposed for netting")
 )
 
 type Repository interface {
-	GetUserSessions()
+	GetUserSessions(userID uint64) ([]*models.Session, error)
+	GetLastSession(userID uint64) (internal.UUID, error)
+	SetLastSession(userID uint64, sessionUUID internal.UUID) error
 	CreateUser(user *models.User) (uint64, error)
+	CreateGuest(chatID int64, name string, creatorID uint64) (uint64, error)
+	GetGuest(chatID int64, name string) (*models.User, error)
+	MergeUsers(guestID uint64, userID uint64) error
 	GetUser(tgID int64) (*models.User, error)
+	UpdateUserProfile(user *models.User) error
 	CreateNewSession(session *models.Session) error
-	GetActiveSessionByChatID(chatID int64) (*models.Session, error)
-	AddMemberToSession(sessionUUID internal.UUID, userID uint64) (uint64, error)
+	GetActiveSessions(chatID int64) ([]*models.Session, error)
+	GetActiveSessionByName(chatID int64, sessionName string) (*models.Session, error)
+	GetDefaultSession(chatID int64, tgID int64) (internal.UUID, error)
+	SetDefaultSession(chatID int64, tgID int64, sessionUUID internal.UUID) error
+	GetSessionByInviteToken(token string) (*models.Session, error)
+	SetInviteToken(sessionUUID internal.UUID, token string) (string, error)
+	GetSessionByUUID(sessionUUID internal.UUID) (*models.Session, error)
+	AddMemberToSession(sessionUUID internal.UUID, userID uint64, role string) (uint64, error)
 	GetMemberBySession(sessionUUID internal.UUID, userID uint64) (*models.Member, error)
-	AddUserCosts(memberID uint64, money int, description string) error
+	SetMemberRole(memberID uint64, role string) error
+	GetSessionMembers(sessionUUID internal.UUID) ([]*models.SessionMember, error)
+	RemoveMember(memberID uint64) error
+	CountMemberRecords(memberID uint64) (int, error)
+	AddUserCosts(memberID uint64, money int, description string, fromPot bool) error
 	GetUsersCosts(sessionUUID internal.UUID) ([]*models.Expanse, error)
+	GetSessionCost(sessionUUID internal.UUID, costID uint64) (*models.Expanse, error)
+	UpdateUserCosts(costID uint64, money int, description string) error
 	GetAllCosts(sessionUUID internal.UUID) ([]*models.Cost, error)
 	GetAllUsers(sessionUUID internal.UUID) ([]*models.User, error)
 	GetUserById(ID uint64) (*models.User, error)
-	FinishSession(sessionUUID internal.UUID) error
+	GetUserByUsername(username string) (*models.User, error)
+	AddTransfer(senderID uint64, recipientID uint64, money int) error
+	GetAllTransfers(sessionUUID internal.UUID) ([]*models.Transfer, error)
+	GetUsersTransfers(sessionUUID internal.UUID) ([]*models.UserTransfer, error)
+	AddPotContribution(memberID uint64, money int) error
+	GetPotContributions(sessionUUID internal.UUID) ([]*models.Contribution, error)
+	FinishSession(sessionUUID internal.UUID, debts []*models.Debt) error
+	GetLastClosedSession(chatID int64) (*models.Session, time.Time, error)
+	GetClosedSessions(chatID int64) ([]*models.ArchivedSession, error)
+	GetSessionDebts(sessionUUID internal.UUID) ([]*models.Debt, error)
+	ReopenSession(sessionUUID internal.UUID) (bool, error)
+	GetInactiveSessions(now time.Time) ([]*models.InactiveSession, error)
+	SetInactivityWarned(sessionUUID internal.UUID, warnedAt time.Time) error
+	GetUserPendingDebts(userID uint64) ([]*models.Debt, error)
+	GetDebt(debtID uint64) (*models.Debt, error)
+	MarkDebtPayed(debtID uint64) (bool, error)
+	CloseSession(sessionUUID internal.UUID) error
+	SetNotify(userID uint64, notify bool) error
+	SetRemind(userID uint64, remind bool) error
+	GetChatSettings(chatID int64) (*models.ChatSettings, error)
+	SaveChatSettings(settings *models.ChatSettings) error
+	CreateReminders(sessionUUID internal.UUID, chatID int64, nextAt time.Time) error
+	GetDueReminders(now time.Time) ([]*models.Reminder, error)
+	UpdateReminder(reminderID uint64, sent int, nextAt time.Time) error
+	DeleteReminder(reminderID uint64) error
+	CreateNetting(netting *models.Netting) (uint64, error)
+	GetNetting(nettingID uint64) (*models.Netting, error)
+	UpdateNettingConfirmation(netting *models.Netting) error
+	CompleteNetting(nettingID uint64) (bool, error)
+	DeclineNetting(nettingID uint64) error
+	GetUserRequisites(userID uint64) ([]*models.Requisite, error)
+	GetDefaultRequisite(userID uint64) (*models.Requisite, error)
+	AddRequisite(requisite *models.Requisite) (uint64, error)
+	SetDefaultRequisite(userID uint64, requisiteID uint64) error
+	DeleteRequisite(requisiteID uint64) error
+	RevealRequisite(requisite *models.Requisite) error
+	ReencryptRequisites() (int, error)
 }
 
 type PgRepository struct {
-	log  internal.Logger
-	Conn *sql.DB
+	log    internal.Logger
+	Conn   *sql.DB
+	cipher *secret.Cipher
+}
+
+func NewPgRepository(log internal.Logger, conn *sql.DB, cipher *secret.Cipher) Repository {
+	return &PgRepository{log: log, Conn: conn, cipher: cipher}
+}
+
+// GetUserSessions returns all sessions, where user is member, from newest to oldest.
+func (r *PgRepository) GetUserSessions(userID uint64) ([]*models.Session, error) {
+	result := make([]*models.Session, 0)
+
+	queryString := fmt.Sprintf(`SELECT S.uuid, S.creator_id, S.chat_id, S.session_name, S.chat_title,
+		to_char(S.started_at, 'DD.MM.YYYY'), coalesce(to_char(S.finished_at, 'DD.MM.YYYY'), ''), S.state, S.mode
+	FROM`+" %s "+`as S JOIN`+" %s "+`as M on S.uuid = M.session_id
+	WHERE M.user_id = $1
+	ORDER BY S.started_at DESC, S.session_name`, SessionTable, MembersTable)
+
+	rows, err := r.Conn.Query(queryString, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tmpSession = models.NewEmptySession()
+		err = rows.Scan(&tmpSession.UUID, &tmpSession.CreatorID, &tmpSession.ChatID, &tmpSession.SessionName,
+			&tmpSession.ChatTitle, &tmpSession.StartedAt, &tmpSession.FinishedAt, &tmpSession.State, &tmpSession.Mode)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tmpSession)
+	}
+	return result, rows.Err()
 }
 
-func NewPgRepository(log internal.Logger, conn *sql.DB) Repository {
-	return &PgRepository{log: log, Conn: conn}
+// GetLastSession returns session, which user has chosen last time in private chat, or uuid.Nil.
+func (r *PgRepository) GetLastSession(userID uint64) (internal.UUID, error) {
+	var sessionUUID uuid.NullUUID
+	queryString := fmt.Sprintf(`SELECT last_session_id FROM`+" %s "+`WHERE id = $1`, UserTable)
+
+	err := r.Conn.QueryRow(queryString, userID).Scan(&sessionUUID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, nil
+	}
+	return sessionUUID.UUID, err
 }
 
-func (r *PgRepository) GetUserSessions() {
+func (r *PgRepository) SetLastSession(userID uint64, sessionUUID internal.UUID) error {
+	queryString := fmt.Sprintf(`UPDATE`+" %s "+`SET last_session_id = $2 WHERE id = $1`, UserTable)
 
+	_, err := r.Conn.Exec(queryString, userID, sessionUUID)
+	return err
 }
 
 func (r *PgRepository) GetUser(tgID int64) (*models.User, error) {
@@ -51,16 +177,21 @@ func (r *PgRepository) GetUser(tgID int64) (*models.User, error) {
 	)
 	queryString := fmt.Sprintf(`SELECT 
 	id, 
-	tg_id, 
+	coalesce(tg_id, 0), 
 	username, 
+	first_name, 
+	last_name, 
+	language_code, 
 	created_at, 
-	requisites
+	requisites,
+	notify
 	FROM`+" %s "+`WHERE tg_id = $1;`, UserTable)
 
 	rows, err := r.Conn.Query(queryString, tgID)
 	if err == nil {
 		for rows.Next() {
-			err = rows.Scan(&user.ID, &user.TgID, &user.Username, &user.CreatedAt, &user.Requisites)
+			err = rows.Scan(&user.ID, &user.TgID, &user.Username, &user.FirstName, &user.LastName,
+				&user.LanguageCode, &user.CreatedAt, &user.Requisites, &user.Notify)
 		}
 	}
 	return user, err
@@ -73,43 +204,306 @@ func (r *PgRepository) GetUserById(ID uint64) (*models.User, error) {
 	)
 	queryString := fmt.Sprintf(`SELECT 
 	id, 
-	tg_id, 
+	coalesce(tg_id, 0), 
 	username, 
+	first_name, 
+	last_name, 
 	created_at, 
-	requisites
+	requisites,
+	notify
 	FROM`+" %s "+`WHERE id = $1;`, UserTable)
 
 	rows, err := r.Conn.Query(queryString, ID)
 	if err == nil {
 		for rows.Next() {
-			err = rows.Scan(&user.ID, &user.TgID, &user.Username, &user.CreatedAt, &user.Requisites)
+			err = rows.Scan(&user.ID, &user.TgID, &user.Username, &user.FirstName, &user.LastName,
+				&user.CreatedAt, &user.Requisites, &user.Notify)
+		}
+	}
+	return user, err
+}
+
+func (r *PgRepository) GetUserByUsername(username string) (*models.User, error) {
+	var (
+		user = models.NewUser()
+		err  error
+	)
+	queryString := fmt.Sprintf(`SELECT 
+	id, 
+	coalesce(tg_id, 0), 
+	username, 
+	first_name, 
+	last_name, 
+	created_at, 
+	requisites
+	FROM`+" %s "+`WHERE username = $1 AND username <> '' AND tg_id IS NOT NULL;`, UserTable)
+
+	rows, err := r.Conn.Query(queryString, username)
+	if err == nil {
+		for rows.Next() {
+			err = rows.Scan(&user.ID, &user.TgID, &user.Username, &user.FirstName, &user.LastName,
+				&user.CreatedAt, &user.Requisites)
 		}
 	}
 	return user, err
 }
 
+// CreateUser returns id of existing user, if it was created by concurrent update.
 func (r *PgRepository) CreateUser(user *models.User) (uint64, error) {
 	var id uint64
 	queryString := fmt.Sprintf(`INSERT INTO`+" %s "+`
-		(tg_id, username, created_at, requisites) VALUES 
-		($1, $2, current_timestamp, $3) returning id;`, UserTable)
+		(tg_id, username, first_name, last_name, language_code, created_at, requisites) VALUES 
+		($1, $2, $3, $4, $5, current_timestamp, $6)
+		ON CONFLICT (tg_id) DO UPDATE SET tg_id = excluded.tg_id
+		returning id;`, UserTable)
+
+	row := r.Conn.QueryRow(queryString, user.TgID, user.Username, user.FirstName, user.LastName,
+		user.LanguageCode, user.Requisites)
+	err := row.Scan(&id)
+	return id, err
+}
+
+// UpdateUserProfile saves previous profile of user to history and updates it.
+func (r *PgRepository) UpdateUserProfile(user *models.User) error {
+	tx, err := r.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	historyQuery := fmt.Sprintf(`INSERT INTO`+" %s "+`
+		(user_id, username, first_name, last_name, language_code, changed_at)
+		SELECT id, username, first_name, last_name, language_code, current_timestamp
+		FROM`+" %s "+`WHERE id = $1;`, HistoryTable, UserTable)
+
+	if _, err = tx.Exec(historyQuery, user.ID); err != nil {
+		return err
+	}
+
+	updateQuery := fmt.Sprintf(`UPDATE`+" %s "+`SET username = $2, first_name = $3, last_name = $4,
+		language_code = $5 WHERE id = $1;`, UserTable)
+
+	_, err = tx.Exec(updateQuery, user.ID, user.Username, user.FirstName, user.LastName, user.LanguageCode)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PgRepository) CreateGuest(chatID int64, name string, creatorID uint64) (uint64, error) {
+	var id uint64
+	queryString := fmt.Sprintf(`INSERT INTO`+" %s "+`
+		(guest_chat_id, username, created_at, requisites, guest_creator_id) VALUES 
+		($1, $2, current_timestamp, '', $3) returning id;`, UserTable)
 
-	row := r.Conn.QueryRow(queryString, user.TgID, user.Username, user.Requisites)
+	row := r.Conn.QueryRow(queryString, chatID, name, creatorID)
 	err := row.Scan(&id)
 	return id, err
 }
 
+func (r *PgRepository) GetGuest(chatID int64, name string) (*models.User, error) {
+	var (
+		user = models.NewUser()
+		err  error
+	)
+	queryString := fmt.Sprintf(`SELECT 
+	id, 
+	username, 
+	first_name, 
+	last_name, 
+	created_at, 
+	requisites,
+	coalesce(guest_creator_id, 0)
+	FROM`+" %s "+`WHERE guest_chat_id = $1 AND username = $2;`, UserTable)
+
+	rows, err := r.Conn.Query(queryString, chatID, name)
+	if err == nil {
+		for rows.Next() {
+			err = rows.Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName, &user.CreatedAt,
+				&user.Requisites, &user.GuestCreatorID)
+		}
+	}
+	return user, err
+}
+
+// MergeUsers moves all guest's memberships with costs, transfers, pot contributions
+// and debts to user and deletes guest. If both are members of the same session,
+// guest's records are reassigned to user's member.
+func (r *PgRepository) MergeUsers(guestID uint64, userID uint64) error {
+	tx, err := r.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	moveQueries := []string{
+		fmt.Sprintf(`UPDATE`+" %s "+`SET member_id = $2 WHERE member_id = $1;`, CostsTable),
+		fmt.Sprintf(`UPDATE`+" %s "+`SET member_id = $2 WHERE member_id = $1;`, PotTable),
+		fmt.Sprintf(`UPDATE`+" %s "+`SET sender_id = $2 WHERE sender_id = $1;`, TransferTable),
+		fmt.Sprintf(`UPDATE`+" %s "+`SET recipient_id = $2 WHERE recipient_id = $1;`, TransferTable),
+		fmt.Sprintf(`UPDATE`+" %s "+`SET creditor_id = $2 WHERE creditor_id = $1;`, DebtsTable),
+		fmt.Sprintf(`UPDATE`+" %s "+`SET debtor_id = $2 WHERE debtor_id = $1;`, DebtsTable),
+	}
+
+	// Pairs of guest's and user's members in common sessions
+	queryString := fmt.Sprintf(`SELECT G.id, U.id
+	FROM`+" %s "+`as G JOIN`+" %s "+`as U on G.session_id = U.session_id
+	WHERE G.user_id = $1 AND U.user_id = $2`, MembersTable, MembersTable)
+
+	rows, err := tx.Query(queryString, guestID, userID)
+	if err != nil {
+		return err
+	}
+	pairs := make([][2]uint64, 0)
+	for rows.Next() {
+		var pair [2]uint64
+		if err = rows.Scan(&pair[0], &pair[1]); err != nil {
+			rows.Close()
+			return err
+		}
+		pairs = append(pairs, pair)
+	}
+	rows.Close()
+
+	for _, pair := range pairs {
+		for _, moveQuery := range moveQueries {
+			if _, err = tx.Exec(moveQuery, pair[0], pair[1]); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec(fmt.Sprintf(`DELETE FROM`+" %s "+`WHERE id = $1;`, MembersTable), pair[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	// Debts between guest and user don't make sense anymore
+	_, err = tx.Exec(fmt.Sprintf(`DELETE FROM`+" %s "+`WHERE creditor_id = debtor_id;`, DebtsTable))
+	if err != nil {
+		return err
+	}
+
+	// Remaining guest's memberships just change owner
+	_, err = tx.Exec(fmt.Sprintf(`UPDATE`+" %s "+`SET user_id = $2 WHERE user_id = $1;`, MembersTable),
+		guestID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`DELETE FROM`+" %s "+`WHERE id = $1;`, UserTable), guestID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateNewSession saves session together with its creator as member. SessionExistsErr is
+// returned, if active session with same name has been created in chat meanwhile.
 func (r *PgRepository) CreateNewSession(session *models.Session) error {
+	tx, err := r.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	queryString := fmt.Sprintf(`INSERT INTO`+" %s "+`
-		(uuid, creator_id, chat_id, session_name, started_at, state) VALUES 
-		($1, $2, $3, $4, current_timestamp, $5);`, SessionTable)
+		(uuid, creator_id, chat_id, session_name, chat_title, started_at, state, mode) VALUES
+		($1, $2, $3, $4, $5, current_timestamp, $6, $7);`, SessionTable)
+
+	_, err = tx.Exec(queryString, session.UUID, session.CreatorID, session.ChatID,
+		session.SessionName, session.ChatTitle, session.State, session.Mode)
+	if isViolated(err, activeNameIndex) {
+		return SessionExistsErr
+	}
+	if err != nil {
+		return err
+	}
+
+	queryString = fmt.Sprintf(`INSERT INTO`+" %s "+`(session_id, user_id, role) VALUES ($1, $2, $3)`,
+		MembersTable)
+	if _, err = tx.Exec(queryString, session.UUID, session.CreatorID, models.RoleCreator); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// isViolated reports whether err is violation of unique constraint or index with given name.
+func isViolated(err error, constraint string) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == uniqueViolation && pqErr.Constraint == constraint
+}
+
+// GetActiveSessions returns active sessions of chat from oldest to newest.
+func (r *PgRepository) GetActiveSessions(chatID int64) ([]*models.Session, error) {
+	result := make([]*models.Session, 0)
+
+	queryString := fmt.Sprintf(`SELECT uuid, creator_id, chat_id, session_name, chat_title,
+		to_char(started_at, 'DD.MM.YYYY'), state, mode
+	FROM`+" %s "+`WHERE chat_id = $1 AND state = 'active'
+	ORDER BY started_at, session_name`, SessionTable)
+
+	rows, err := r.Conn.Query(queryString, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tmpSession = models.NewEmptySession()
+		err = rows.Scan(&tmpSession.UUID, &tmpSession.CreatorID, &tmpSession.ChatID, &tmpSession.SessionName,
+			&tmpSession.ChatTitle, &tmpSession.StartedAt, &tmpSession.State, &tmpSession.Mode)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tmpSession)
+	}
+	return result, rows.Err()
+}
+
+// GetActiveSessionByName returns active session of chat with given name or empty session.
+func (r *PgRepository) GetActiveSessionByName(chatID int64, sessionName string) (*models.Session, error) {
+	var session = models.NewEmptySession()
+
+	queryString := fmt.Sprintf(`SELECT uuid, creator_id, chat_id, session_name, chat_title,
+		to_char(started_at, 'DD.MM.YYYY'), state, mode
+	FROM`+" %s "+`WHERE chat_id = $1 AND state = 'active' AND lower(session_name) = lower($2)`, SessionTable)
+
+	err := r.Conn.QueryRow(queryString, chatID, sessionName).Scan(&session.UUID, &session.CreatorID,
+		&session.ChatID, &session.SessionName, &session.ChatTitle, &session.StartedAt, &session.State, &session.Mode)
+	if err == sql.ErrNoRows {
+		return session, nil
+	}
+	return session, err
+}
+
+// GetDefaultSession returns active session chosen by user or, if user has not chosen, by chat.
+// It returns uuid.Nil if there is no such session.
+func (r *PgRepository) GetDefaultSession(chatID int64, tgID int64) (internal.UUID, error) {
+	var sessionUUID internal.UUID
 
-	_, err := r.Conn.Exec(queryString, session.UUID, session.CreatorID, session.ChatID,
-		session.SessionName, session.State)
+	queryString := fmt.Sprintf(`SELECT D.session_id
+	FROM`+" %s "+`as D JOIN`+" %s "+`as S on S.uuid = D.session_id
+	WHERE D.chat_id = $1 AND D.tg_id IN (0, $2) AND S.state = 'active'
+	ORDER BY D.tg_id = 0
+	LIMIT 1`, DefaultsTable, SessionTable)
+
+	err := r.Conn.QueryRow(queryString, chatID, tgID).Scan(&sessionUUID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, nil
+	}
+	return sessionUUID, err
+}
+
+// SetDefaultSession sets session for commands of user, tgID 0 sets session for whole chat.
+func (r *PgRepository) SetDefaultSession(chatID int64, tgID int64, sessionUUID internal.UUID) error {
+	queryString := fmt.Sprintf(`INSERT INTO`+" %s "+`(chat_id, tg_id, session_id) VALUES ($1, $2, $3)
+	ON CONFLICT (chat_id, tg_id) DO UPDATE SET session_id = excluded.session_id`, DefaultsTable)
+
+	_, err := r.Conn.Exec(queryString, chatID, tgID, sessionUUID)
 	return err
 }
 
-func (r *PgRepository) GetActiveSessionByChatID(chatID int64) (*models.Session, error) {
+func (r *PgRepository) GetSessionByUUID(sessionUUID internal.UUID) (*models.Session, error) {
 	var (
 		session = models.NewEmptySession()
 		err     error
@@ -119,26 +513,57 @@ func (r *PgRepository) GetActiveSessionByChatID(chatID int64) (*models.Session,
 	creator_id, 
 	chat_id, 
 	session_name,
-	state
-	FROM`+" %s "+`WHERE chat_id = $1 AND state='active';`, SessionTable)
+	chat_title,
+	to_char(started_at, 'DD.MM.YYYY'),
+	coalesce(to_char(finished_at, 'DD.MM.YYYY'), ''),
+	state,
+	mode
+	FROM`+" %s "+`WHERE uuid = $1;`, SessionTable)
 
-	rows, err := r.Conn.Query(queryString, chatID)
+	rows, err := r.Conn.Query(queryString, sessionUUID)
 	if err == nil {
 		for rows.Next() {
 			err = rows.Scan(&session.UUID, &session.CreatorID, &session.ChatID, &session.SessionName,
-				&session.State)
+				&session.ChatTitle, &session.StartedAt, &session.FinishedAt, &session.State, &session.Mode)
 		}
 	}
 	return session, err
 }
 
-func (r *PgRepository) AddMemberToSession(sessionUUID internal.UUID, userID uint64) (uint64, error) {
+func (r *PgRepository) GetSessionByInviteToken(token string) (*models.Session, error) {
+	var session = models.NewEmptySession()
+
+	queryString := fmt.Sprintf(`SELECT uuid, creator_id, chat_id, session_name, chat_title, state, mode
+	FROM`+" %s "+`WHERE invite_token = $1;`, SessionTable)
+
+	err := r.Conn.QueryRow(queryString, token).Scan(&session.UUID, &session.CreatorID, &session.ChatID,
+		&session.SessionName, &session.ChatTitle, &session.State, &session.Mode)
+	if err == sql.ErrNoRows {
+		return session, nil
+	}
+	return session, err
+}
+
+// SetInviteToken sets token if session has no token yet and returns actual token of session.
+func (r *PgRepository) SetInviteToken(sessionUUID internal.UUID, token string) (string, error) {
+	queryString := fmt.Sprintf(`UPDATE`+" %s "+`SET invite_token = coalesce(invite_token, $2)
+		WHERE uuid = $1 returning invite_token;`, SessionTable)
+
+	err := r.Conn.QueryRow(queryString, sessionUUID, token).Scan(&token)
+	return token, err
+}
+
+// AddMemberToSession returns id of new member. If user has become member meanwhile, id of existing
+// member is returned and its role isn't changed.
+func (r *PgRepository) AddMemberToSession(sessionUUID internal.UUID, userID uint64, role string) (uint64, error) {
 	var id uint64
-	queryString := fmt.Sprintf(`INSERT INTO`+" %s "+`
-		(session_id, user_id) VALUES 
-		($1, $2) returning id;`, MembersTable)
+	queryString := fmt.Sprintf(`INSERT INTO`+" %s "+`as M
+		(session_id, user_id, role) VALUES
+		($1, $2, $3)
+	ON CONFLICT (session_id, user_id) DO UPDATE SET role = M.role
+	returning id;`, MembersTable)
 
-	row := r.Conn.QueryRow(queryString, sessionUUID, userID)
+	row := r.Conn.QueryRow(queryString, sessionUUID, userID, role)
 	err := row.Scan(&id)
 	return id, err
 }
@@ -151,31 +576,91 @@ func (r *PgRepository) GetMemberBySession(sessionUUID internal.UUID, userID uint
 	queryString := fmt.Sprintf(`SELECT 
 	id, 
 	session_id, 
-	user_id
+	user_id,
+	role
 	FROM`+" %s "+`WHERE session_id = $1 AND user_id = $2;`, MembersTable)
 
 	rows, err := r.Conn.Query(queryString, sessionUUID, userID)
 	if err == nil {
 		for rows.Next() {
-			err = rows.Scan(&member.ID, &member.SessionUUID, &member.UserID)
+			err = rows.Scan(&member.ID, &member.SessionUUID, &member.UserID, &member.Role)
 		}
 	}
 	return member, err
 }
 
-func (r *PgRepository) AddUserCosts(memberID uint64, money int, description string) error {
-	queryString := fmt.Sprintf(`INSERT INTO`+" %s "+`
-		(member_id, money, description, created_at) VALUES 
-		($1, $2, $3, current_timestamp);`, CostsTable)
+func (r *PgRepository) SetMemberRole(memberID uint64, role string) error {
+	queryString := fmt.Sprintf(`UPDATE`+" %s "+`SET role = $2 WHERE id = $1`, MembersTable)
+
+	_, err := r.Conn.Exec(queryString, memberID, role)
+	return err
+}
+
+// GetSessionMembers returns users of session with their roles in order of joining.
+func (r *PgRepository) GetSessionMembers(sessionUUID internal.UUID) ([]*models.SessionMember, error) {
+	result := make([]*models.SessionMember, 0)
+
+	queryString := fmt.Sprintf(`SELECT U.id, coalesce(U.tg_id, 0), U.username, U.first_name, U.last_name,
+		U.created_at, U.requisites, U.notify, M.role
+	FROM`+" %s "+`as U JOIN`+" %s "+`as M on U.id = M.user_id WHERE M.session_id = $1
+	ORDER BY M.id`, UserTable, MembersTable)
+
+	rows, err := r.Conn.Query(queryString, sessionUUID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var member = &models.SessionMember{User: &models.User{}}
+		err = rows.Scan(&member.User.ID, &member.User.TgID, &member.User.Username, &member.User.FirstName,
+			&member.User.LastName, &member.User.CreatedAt, &member.User.Requisites, &member.User.Notify, &member.Role)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, member)
+	}
+	return result, rows.Err()
+}
+
+func (r *PgRepository) RemoveMember(memberID uint64) error {
+	queryString := fmt.Sprintf(`DELETE FROM`+" %s "+`WHERE id = $1;`, MembersTable)
+
+	_, err := r.Conn.Exec(queryString, memberID)
+	return err
+}
+
+// CountMemberRecords returns number of costs, transfers and pot contributions
+// recorded for member, because they would be lost on member removal.
+func (r *PgRepository) CountMemberRecords(memberID uint64) (int, error) {
+	var count int
+	queryString := fmt.Sprintf(`SELECT
+	(SELECT count(*) FROM`+" %s "+`WHERE member_id = $1) +
+	(SELECT count(*) FROM`+" %s "+`WHERE sender_id = $1 OR recipient_id = $1) +
+	(SELECT count(*) FROM`+" %s "+`WHERE member_id = $1);`, CostsTable, TransferTable, PotTable)
 
-	_, err := r.Conn.Exec(queryString, memberID, money, description)
+	row := r.Conn.QueryRow(queryString, memberID)
+	err := row.Scan(&count)
+	return count, err
+}
+
+// AddUserCosts saves expense and marks session as active, so it isn't finished for inactivity.
+func (r *PgRepository) AddUserCosts(memberID uint64, money int, description string, fromPot bool) error {
+	queryString := fmt.Sprintf(`WITH C as (INSERT INTO`+" %s "+`
+		(member_id, money, description, created_at, from_pot) VALUES
+		($1, $2, $3, current_timestamp, $4))
+	UPDATE`+" %s "+`SET last_activity_at = current_timestamp, inactivity_warned_at = NULL
+	WHERE uuid = (SELECT session_id FROM`+" %s "+`WHERE id = $1)`, CostsTable, SessionTable, MembersTable)
+
+	_, err := r.Conn.Exec(queryString, memberID, money, description, fromPot)
 	return err
 }
 
 func (r *PgRepository) GetUsersCosts(sessionUUID internal.UUID) ([]*models.Expanse, error) {
 	result := make([]*models.Expanse, 0)
 
-	queryString := fmt.Sprintf(`SELECT U.username, C.money, C.description 
+	queryString := fmt.Sprintf(`SELECT C.id, U.id, coalesce(U.tg_id, 0), U.username, U.first_name, U.last_name,
+		C.money, C.description, C.from_pot 
 	FROM`+" %s "+`as M JOIN `+" %s "+`as C on M.id = C.member_id
 		JOIN`+" %s "+`as U on M.user_id = U.id
 	WHERE M.session_id = $1`, MembersTable, CostsTable, UserTable)
@@ -183,8 +668,10 @@ func (r *PgRepository) GetUsersCosts(sessionUUID internal.UUID) ([]*models.Expan
 	rows, err := r.Conn.Query(queryString, sessionUUID)
 	if err == nil {
 		for rows.Next() {
-			var tmpExpenses = &models.Expanse{}
-			err = rows.Scan(&tmpExpenses.Username, &tmpExpenses.Cost, &tmpExpenses.Description)
+			var tmpExpenses = models.NewEmptyExpanse()
+			err = rows.Scan(&tmpExpenses.ID, &tmpExpenses.User.ID, &tmpExpenses.User.TgID, &tmpExpenses.User.Username,
+				&tmpExpenses.User.FirstName, &tmpExpenses.User.LastName, &tmpExpenses.Cost,
+				&tmpExpenses.Description, &tmpExpenses.FromPot)
 			if err == nil {
 				result = append(result, tmpExpenses)
 			}
@@ -194,10 +681,44 @@ func (r *PgRepository) GetUsersCosts(sessionUUID internal.UUID) ([]*models.Expan
 	return result, err
 }
 
+// GetSessionCost returns expense of session with its payer, ID is 0 if session has no such expense.
+func (r *PgRepository) GetSessionCost(sessionUUID internal.UUID, costID uint64) (*models.Expanse, error) {
+	var expense = models.NewEmptyExpanse()
+
+	queryString := fmt.Sprintf(`SELECT C.id, U.id, coalesce(U.tg_id, 0), U.username, U.first_name, U.last_name,
+		C.money, C.description, C.from_pot
+	FROM`+" %s "+`as M JOIN`+" %s "+`as C on M.id = C.member_id
+		JOIN`+" %s "+`as U on M.user_id = U.id
+	WHERE M.session_id = $1 AND C.id = $2`, MembersTable, CostsTable, UserTable)
+
+	err := r.Conn.QueryRow(queryString, sessionUUID, costID).Scan(&expense.ID, &expense.User.ID, &expense.User.TgID,
+		&expense.User.Username, &expense.User.FirstName, &expense.User.LastName, &expense.Cost,
+		&expense.Description, &expense.FromPot)
+	if err == sql.ErrNoRows {
+		return expense, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return expense, nil
+}
+
+// UpdateUserCosts changes expense and marks session as active, like new expense does.
+func (r *PgRepository) UpdateUserCosts(costID uint64, money int, description string) error {
+	queryString := fmt.Sprintf(`WITH C as (UPDATE`+" %s "+`SET money = $2, description = $3
+		WHERE id = $1 returning member_id)
+	UPDATE`+" %s "+`SET last_activity_at = current_timestamp, inactivity_warned_at = NULL
+	WHERE uuid = (SELECT M.session_id FROM`+" %s "+`as M JOIN C on M.id = C.member_id)`,
+		CostsTable, SessionTable, MembersTable)
+
+	_, err := r.Conn.Exec(queryString, costID, money, description)
+	return err
+}
+
 func (r *PgRepository) GetAllCosts(sessionUUID internal.UUID) ([]*models.Cost, error) {
 	result := make([]*models.Cost, 0)
 
-	queryString := fmt.Sprintf(`SELECT M.user_id, C.money
+	queryString := fmt.Sprintf(`SELECT M.user_id, C.money, C.from_pot
 	FROM`+" %s "+`as M JOIN `+" %s "+`as C on M.id = C.member_id
 	WHERE M.session_id = $1`, MembersTable, CostsTable)
 
@@ -209,7 +730,7 @@ func (r *PgRepository) GetAllCosts(sessionUUID internal.UUID) ([]*models.Cost, e
 
 	for rows.Next() {
 		var tmpCosts = &models.Cost{}
-		err = rows.Scan(&tmpCosts.UserID, &tmpCosts.Money)
+		err = rows.Scan(&tmpCosts.UserID, &tmpCosts.Money, &tmpCosts.FromPot)
 		if err != nil {
 			return nil, err
 		}
@@ -219,32 +740,834 @@ func (r *PgRepository) GetAllCosts(sessionUUID internal.UUID) ([]*models.Cost, e
 	return result, err
 }
 
-func (r *PgRepository) FinishSession(sessionUUID internal.UUID) error {
-	queryString := fmt.Sprintf(`UPDATE`+" %s "+`SET state = $1 WHERE uuid = $2`, SessionTable)
+// FinishSession saves final debts of session, which are set by user ids. Session with debts is
+// settling until they are paid, session without debts is closed at once. Only active session
+// is finished, otherwise SessionFinishedErr is returned.
+func (r *PgRepository) FinishSession(sessionUUID internal.UUID, debts []*models.Debt) error {
+	tx, err := r.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	_, err := r.Conn.Exec(queryString, ClosedSession, sessionUUID)
-	return err
+	state := models.SessionClosed
+	if len(debts) != 0 {
+		state = models.SessionSettling
+	}
+
+	// Row lock makes concurrent finish of the same session wait and see it finished
+	queryString := fmt.Sprintf(`UPDATE`+" %s "+`SET state = $1, finished_at = current_timestamp
+		WHERE uuid = $2 AND state = $3`, SessionTable)
+
+	result, err := tx.Exec(queryString, state, sessionUUID, models.SessionActive)
+	if err != nil {
+		return err
+	}
+	finished, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if finished == 0 {
+		return SessionFinishedErr
+	}
+
+	queryString = fmt.Sprintf(`INSERT INTO`+" %s "+`(creditor_id, debtor_id, money)
+	SELECT C.id, D.id, $4 FROM`+" %s "+`as C JOIN`+" %s "+`as D on C.session_id = D.session_id
+	WHERE C.session_id = $1 AND C.user_id = $2 AND D.user_id = $3;`, DebtsTable, MembersTable, MembersTable)
+
+	for _, debt := range debts {
+		if _, err = tx.Exec(queryString, sessionUUID, debt.Creditor.ID, debt.Debtor.ID, debt.Money); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-func (r *PgRepository) GetAllUsers(sessionUUID internal.UUID) ([]*models.User, error) {
-	result := make([]*models.User, 0)
+// debtsQuery selects debts with their sessions and users, condition is appended to it.
+var debtsQuery = fmt.Sprintf(`SELECT D.id, D.money, D.status,
+		S.uuid, S.chat_id, S.session_name, S.chat_title, S.state, S.mode,
+		CU.id, coalesce(CU.tg_id, 0), CU.username, CU.first_name, CU.last_name,
+		DU.id, coalesce(DU.tg_id, 0), DU.username, DU.first_name, DU.last_name
+	FROM`+" %s "+`as D
+		JOIN`+" %s "+`as CM on D.creditor_id = CM.id
+		JOIN`+" %s "+`as DM on D.debtor_id = DM.id
+		JOIN`+" %s "+`as S on CM.session_id = S.uuid
+		JOIN`+" %s "+`as CU on CM.user_id = CU.id
+		JOIN`+" %s "+`as DU on DM.user_id = DU.id
+	`, DebtsTable, MembersTable, MembersTable, SessionTable, UserTable, UserTable)
 
-	queryString := fmt.Sprintf(`select U.id, U.tg_id, U.username, U.created_at, U.requisites 
-	from`+" %s "+`as U join`+" %s "+`as M on U.id = M.user_id where M.session_id = $1`, UserTable, MembersTable)
+// GetLastClosedSession returns the most recently finished session of chat, settling or closed,
+// with time of finish or empty session.
+func (r *PgRepository) GetLastClosedSession(chatID int64) (*models.Session, time.Time, error) {
+	var (
+		session    = models.NewEmptySession()
+		finishedAt time.Time
+	)
 
-	rows, err := r.Conn.Query(queryString, sessionUUID)
+	queryString := fmt.Sprintf(`SELECT uuid, creator_id, chat_id, session_name, chat_title,
+		to_char(started_at, 'DD.MM.YYYY'), to_char(finished_at, 'DD.MM.YYYY'), finished_at, state, mode
+	FROM`+" %s "+`WHERE chat_id = $1 AND state IN ($2, $3)
+	ORDER BY finished_at DESC
+	LIMIT 1`, SessionTable)
+
+	err := r.Conn.QueryRow(queryString, chatID, models.SessionSettling, models.SessionClosed).Scan(&session.UUID,
+		&session.CreatorID, &session.ChatID, &session.SessionName, &session.ChatTitle, &session.StartedAt,
+		&session.FinishedAt, &finishedAt, &session.State, &session.Mode)
+	if err == sql.ErrNoRows {
+		return session, finishedAt, nil
+	}
+	return session, finishedAt, err
+}
+
+// GetClosedSessions returns finished sessions of chat, settling or closed, from newest to oldest
+// with their totals.
+func (r *PgRepository) GetClosedSessions(chatID int64) ([]*models.ArchivedSession, error) {
+	result := make([]*models.ArchivedSession, 0)
 
+	queryString := fmt.Sprintf(`SELECT S.uuid, S.creator_id, S.chat_id, S.session_name, S.chat_title,
+		to_char(S.started_at, 'DD.MM.YYYY'), to_char(S.finished_at, 'DD.MM.YYYY'), S.state, S.mode,
+		(SELECT coalesce(sum(C.money), 0) FROM`+" %s "+`as C JOIN`+" %s "+`as M on C.member_id = M.id
+			WHERE M.session_id = S.uuid),
+		(SELECT count(*) FROM`+" %s "+`as M WHERE M.session_id = S.uuid)
+	FROM`+" %s "+`as S
+	WHERE S.chat_id = $1 AND S.state IN ($2, $3)
+	ORDER BY S.finished_at DESC`, CostsTable, MembersTable, MembersTable, SessionTable)
+
+	rows, err := r.Conn.Query(queryString, chatID, models.SessionSettling, models.SessionClosed)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var tmpUser = &models.User{}
-		err = rows.Scan(&tmpUser.ID, &tmpUser.TgID, &tmpUser.Username, &tmpUser.CreatedAt, &tmpUser.Requisites)
+		var archived = &models.ArchivedSession{Session: models.NewEmptySession()}
+		session := archived.Session
+		err = rows.Scan(&session.UUID, &session.CreatorID, &session.ChatID, &session.SessionName,
+			&session.ChatTitle, &session.StartedAt, &session.FinishedAt, &session.State, &session.Mode,
+			&archived.Total, &archived.Members)
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, tmpUser)
+		result = append(result, archived)
 	}
-	return result, err
+	return result, rows.Err()
+}
+
+// GetSessionDebts returns debts saved at finish of session.
+func (r *PgRepository) GetSessionDebts(sessionUUID internal.UUID) ([]*models.Debt, error) {
+	return r.queryDebts(debtsQuery+`WHERE S.uuid = $1 ORDER BY CU.id, D.money DESC`, sessionUUID)
+}
+
+// ReopenSession makes finished session active again and deletes debts saved at finish, they are
+// calculated again at next finish. Session isn't reopened, if some of its debts are already paid,
+// and SessionExistsErr is returned, if chat has active session with same name.
+func (r *PgRepository) ReopenSession(sessionUUID internal.UUID) (bool, error) {
+	tx, err := r.Conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var paid int
+	queryString := fmt.Sprintf(`SELECT count(*) FROM (SELECT D.status FROM`+" %s "+`as D JOIN`+" %s "+`as M
+		on D.creditor_id = M.id WHERE M.session_id = $1 FOR UPDATE OF D) as L WHERE L.status <> 'pending'`,
+		DebtsTable, MembersTable)
+	if err = tx.QueryRow(queryString, sessionUUID).Scan(&paid); err != nil {
+		return false, err
+	}
+	if paid != 0 {
+		return false, nil
+	}
+
+	// Proposed nettings would settle debts which no longer exist
+	queryString = fmt.Sprintf(`UPDATE`+" %s "+`SET status = 'declined'
+	WHERE status = 'proposed' AND id in (SELECT ND.netting_id FROM`+" %s "+`as ND
+		JOIN`+" %s "+`as D on ND.debt_id = D.id JOIN`+" %s "+`as M on D.creditor_id = M.id
+		WHERE M.session_id = $1)`, NettingTable, NettingDebts, DebtsTable, MembersTable)
+	if _, err = tx.Exec(queryString, sessionUUID); err != nil {
+		return false, err
+	}
+
+	// Reminders and links to nettings are deleted by cascade
+	queryString = fmt.Sprintf(`DELETE FROM`+" %s "+`
+	WHERE creditor_id in (SELECT id FROM`+" %s "+`WHERE session_id = $1)`, DebtsTable, MembersTable)
+	if _, err = tx.Exec(queryString, sessionUUID); err != nil {
+		return false, err
+	}
+
+	queryString = fmt.Sprintf(`UPDATE`+" %s "+`SET state = 'active', finished_at = NULL,
+		last_activity_at = current_timestamp, inactivity_warned_at = NULL
+		WHERE uuid = $1`, SessionTable)
+	_, err = tx.Exec(queryString, sessionUUID)
+	if isViolated(err, activeNameIndex) {
+		return false, SessionExistsErr
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (r *PgRepository) queryDebts(queryString string, args ...any) ([]*models.Debt, error) {
+	result := make([]*models.Debt, 0)
+
+	rows, err := r.Conn.Query(queryString, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tmpDebt = models.NewEmptyDebt()
+		err = rows.Scan(&tmpDebt.ID, &tmpDebt.Money, &tmpDebt.Status,
+			&tmpDebt.Session.UUID, &tmpDebt.Session.ChatID, &tmpDebt.Session.SessionName,
+			&tmpDebt.Session.ChatTitle, &tmpDebt.Session.State, &tmpDebt.Session.Mode,
+			&tmpDebt.Creditor.ID, &tmpDebt.Creditor.TgID, &tmpDebt.Creditor.Username,
+			&tmpDebt.Creditor.FirstName, &tmpDebt.Creditor.LastName,
+			&tmpDebt.Debtor.ID, &tmpDebt.Debtor.TgID, &tmpDebt.Debtor.Username,
+			&tmpDebt.Debtor.FirstName, &tmpDebt.Debtor.LastName)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tmpDebt)
+	}
+	return result, rows.Err()
+}
+
+// GetInactiveSessions returns active sessions, which had no expenses for longer, than their chat allows.
+func (r *PgRepository) GetInactiveSessions(now time.Time) ([]*models.InactiveSession, error) {
+	result := make([]*models.InactiveSession, 0)
+
+	queryString := fmt.Sprintf(`SELECT S.uuid, S.creator_id, S.chat_id, S.session_name, S.chat_title,
+		to_char(S.started_at, 'DD.MM.YYYY'), S.state, S.mode, C.autofinish_days, C.autofinish_grace_days,
+		S.last_activity_at, S.inactivity_warned_at
+	FROM`+" %s "+`as S JOIN`+" %s "+`as C on S.chat_id = C.chat_id
+	WHERE S.state = 'active' AND C.autofinish_days > 0
+		AND S.last_activity_at <= $1 - make_interval(days => C.autofinish_days)
+	ORDER BY S.last_activity_at`, SessionTable, SettingsTable)
+
+	rows, err := r.Conn.Query(queryString, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tmpSession = &models.InactiveSession{Session: models.NewEmptySession()}
+		var warnedAt sql.NullTime
+		err = rows.Scan(&tmpSession.Session.UUID, &tmpSession.Session.CreatorID, &tmpSession.Session.ChatID,
+			&tmpSession.Session.SessionName, &tmpSession.Session.ChatTitle, &tmpSession.Session.StartedAt,
+			&tmpSession.Session.State, &tmpSession.Session.Mode, &tmpSession.InactiveDays, &tmpSession.GraceDays,
+			&tmpSession.LastActivityAt, &warnedAt)
+		if err != nil {
+			return nil, err
+		}
+		tmpSession.WarnedAt = warnedAt.Time
+		result = append(result, tmpSession)
+	}
+	return result, rows.Err()
+}
+
+func (r *PgRepository) SetInactivityWarned(sessionUUID internal.UUID, warnedAt time.Time) error {
+	queryString := fmt.Sprintf(`UPDATE`+" %s "+`SET inactivity_warned_at = $2 WHERE uuid = $1`, SessionTable)
+
+	_, err := r.Conn.Exec(queryString, sessionUUID, warnedAt)
+	return err
+}
+
+// GetUserPendingDebts returns unpaid debts of finished sessions, where user is creditor or debtor.
+func (r *PgRepository) GetUserPendingDebts(userID uint64) ([]*models.Debt, error) {
+	return r.queryDebts(debtsQuery+`WHERE D.status = 'pending' AND (CM.user_id = $1 OR DM.user_id = $1)
+	ORDER BY S.started_at DESC, D.id`, userID)
+}
+
+// GetDebt returns debt with its session and users, ID is 0 if debt doesn't exist.
+func (r *PgRepository) GetDebt(debtID uint64) (*models.Debt, error) {
+	debts, err := r.queryDebts(debtsQuery+`WHERE D.id = $1`, debtID)
+	if err != nil || len(debts) == 0 {
+		return models.NewEmptyDebt(), err
+	}
+	return debts[0], nil
+}
+
+// MarkDebtPayed marks pending debt paid, false is returned, if debt isn't pending anymore.
+func (r *PgRepository) MarkDebtPayed(debtID uint64) (bool, error) {
+	queryString := fmt.Sprintf(`UPDATE`+" %s "+`SET status = 'payed' WHERE id = $1 AND status = 'pending'`,
+		DebtsTable)
+
+	result, err := r.Conn.Exec(queryString, debtID)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated != 0, err
+}
+
+// CloseSession closes settling session, whose debts are paid.
+func (r *PgRepository) CloseSession(sessionUUID internal.UUID) error {
+	queryString := fmt.Sprintf(`UPDATE`+" %s "+`SET state = 'closed' WHERE uuid = $1 AND state = 'settling'`,
+		SessionTable)
+
+	_, err := r.Conn.Exec(queryString, sessionUUID)
+	return err
+}
+
+func (r *PgRepository) SetNotify(userID uint64, notify bool) error {
+	queryString := fmt.Sprintf(`UPDATE`+" %s "+`SET notify = $2 WHERE id = $1`, UserTable)
+
+	_, err := r.Conn.Exec(queryString, userID, notify)
+	return err
+}
+
+func (r *PgRepository) SetRemind(userID uint64, remind bool) error {
+	queryString := fmt.Sprintf(`UPDATE`+" %s "+`SET remind = $2 WHERE id = $1`, UserTable)
+
+	_, err := r.Conn.Exec(queryString, userID, remind)
+	return err
+}
+
+// GetChatSettings returns default settings for chat, which hasn't changed them.
+func (r *PgRepository) GetChatSettings(chatID int64) (*models.ChatSettings, error) {
+	var settings = models.NewChatSettings(chatID)
+
+	queryString := fmt.Sprintf(`SELECT remind_enabled, remind_interval_days, remind_max, remind_in_group,
+		admins_treasurers, autofinish_days, autofinish_grace_days
+	FROM`+" %s "+`WHERE chat_id = $1`, SettingsTable)
+
+	err := r.Conn.QueryRow(queryString, chatID).Scan(&settings.RemindEnabled, &settings.RemindIntervalDays,
+		&settings.RemindMax, &settings.RemindInGroup, &settings.AdminsAreTreasurers, &settings.AutoFinishDays,
+		&settings.AutoFinishGraceDays)
+	if err == sql.ErrNoRows {
+		return settings, nil
+	}
+	return settings, err
+}
+
+func (r *PgRepository) SaveChatSettings(settings *models.ChatSettings) error {
+	queryString := fmt.Sprintf(`INSERT INTO`+" %s "+`
+		(chat_id, remind_enabled, remind_interval_days, remind_max, remind_in_group, admins_treasurers,
+		autofinish_days, autofinish_grace_days) VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (chat_id) DO UPDATE SET remind_enabled = $2, remind_interval_days = $3,
+		remind_max = $4, remind_in_group = $5, admins_treasurers = $6, autofinish_days = $7,
+		autofinish_grace_days = $8`, SettingsTable)
+
+	_, err := r.Conn.Exec(queryString, settings.ChatID, settings.RemindEnabled, settings.RemindIntervalDays,
+		settings.RemindMax, settings.RemindInGroup, settings.AdminsAreTreasurers, settings.AutoFinishDays,
+		settings.AutoFinishGraceDays)
+	return err
+}
+
+// CreateReminders schedules first reminder for every pending debt of session.
+func (r *PgRepository) CreateReminders(sessionUUID internal.UUID, chatID int64, nextAt time.Time) error {
+	queryString := fmt.Sprintf(`INSERT INTO`+" %s "+`(debt_id, chat_id, next_at)
+	SELECT D.id, $2, $3 FROM`+" %s "+`as D JOIN`+" %s "+`as M on D.creditor_id = M.id
+	WHERE M.session_id = $1 AND D.status = 'pending'
+	ON CONFLICT (debt_id) DO NOTHING`, ReminderTable, DebtsTable, MembersTable)
+
+	_, err := r.Conn.Exec(queryString, sessionUUID, chatID, nextAt)
+	return err
+}
+
+// GetDueReminders returns reminders, which time has come.
+func (r *PgRepository) GetDueReminders(now time.Time) ([]*models.Reminder, error) {
+	result := make([]*models.Reminder, 0)
+
+	queryString := fmt.Sprintf(`SELECT R.id, R.debt_id, R.chat_id, R.sent, D.status = 'pending', U.remind
+	FROM`+" %s "+`as R
+		JOIN`+" %s "+`as D on R.debt_id = D.id
+		JOIN`+" %s "+`as M on D.debtor_id = M.id
+		JOIN`+" %s "+`as U on M.user_id = U.id
+	WHERE R.next_at <= $1
+	ORDER BY R.next_at`, ReminderTable, DebtsTable, MembersTable, UserTable)
+
+	rows, err := r.Conn.Query(queryString, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tmpReminder = &models.Reminder{}
+		err = rows.Scan(&tmpReminder.ID, &tmpReminder.DebtID, &tmpReminder.ChatID, &tmpReminder.Sent,
+			&tmpReminder.DebtPending, &tmpReminder.DebtorRemind)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tmpReminder)
+	}
+	return result, rows.Err()
+}
+
+func (r *PgRepository) UpdateReminder(reminderID uint64, sent int, nextAt time.Time) error {
+	queryString := fmt.Sprintf(`UPDATE`+" %s "+`SET sent = $2, next_at = $3 WHERE id = $1`, ReminderTable)
+
+	_, err := r.Conn.Exec(queryString, reminderID, sent, nextAt)
+	return err
+}
+
+func (r *PgRepository) DeleteReminder(reminderID uint64) error {
+	queryString := fmt.Sprintf(`DELETE FROM`+" %s "+`WHERE id = $1`, ReminderTable)
+
+	_, err := r.Conn.Exec(queryString, reminderID)
+	return err
+}
+
+// CreateNetting saves proposed netting. Debts are locked, so NettingExistsErr is returned,
+// if some of them are proposed for other netting, even when it is being created meanwhile.
+func (r *PgRepository) CreateNetting(netting *models.Netting) (uint64, error) {
+	var id uint64
+	tx, err := r.Conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, 0, len(netting.Debts))
+	for _, debt := range netting.Debts {
+		ids = append(ids, int64(debt.ID))
+	}
+	queryString := fmt.Sprintf(`SELECT id FROM`+" %s "+`WHERE id = any($1) ORDER BY id FOR UPDATE`, DebtsTable)
+	if _, err = tx.Exec(queryString, pq.Array(ids)); err != nil {
+		return 0, err
+	}
+
+	var exists bool
+	queryString = fmt.Sprintf(`SELECT exists(SELECT 1 FROM`+" %s "+`as ND JOIN`+" %s "+`as N
+		on ND.netting_id = N.id WHERE N.status = 'proposed' AND ND.debt_id = any($1))`, NettingDebts, NettingTable)
+	if err = tx.QueryRow(queryString, pq.Array(ids)).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, NettingExistsErr
+	}
+
+	queryString = fmt.Sprintf(`INSERT INTO`+" %s "+`
+		(first_user_id, second_user_id, money, first_confirmed, second_confirmed) VALUES
+		($1, $2, $3, $4, $5) returning id;`, NettingTable)
+
+	err = tx.QueryRow(queryString, netting.FirstUser.ID, netting.SecondUser.ID, netting.Money,
+		netting.FirstConfirmed, netting.SecondConfirmed).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	queryString = fmt.Sprintf(`INSERT INTO`+" %s "+`(netting_id, debt_id) VALUES ($1, $2);`, NettingDebts)
+	for _, debt := range netting.Debts {
+		if _, err = tx.Exec(queryString, id, debt.ID); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+// GetNetting returns netting with its users and debts, ID is 0 if netting doesn't exist.
+func (r *PgRepository) GetNetting(nettingID uint64) (*models.Netting, error) {
+	var netting = models.NewEmptyNetting()
+
+	queryString := fmt.Sprintf(`SELECT N.id, N.money, N.first_confirmed, N.second_confirmed, N.status,
+		F.id, coalesce(F.tg_id, 0), F.username, F.first_name, F.last_name,
+		S.id, coalesce(S.tg_id, 0), S.username, S.first_name, S.last_name
+	FROM`+" %s "+`as N
+		JOIN`+" %s "+`as F on N.first_user_id = F.id
+		JOIN`+" %s "+`as S on N.second_user_id = S.id
+	WHERE N.id = $1`, NettingTable, UserTable, UserTable)
+
+	err := r.Conn.QueryRow(queryString, nettingID).Scan(&netting.ID, &netting.Money, &netting.FirstConfirmed,
+		&netting.SecondConfirmed, &netting.Status,
+		&netting.FirstUser.ID, &netting.FirstUser.TgID, &netting.FirstUser.Username,
+		&netting.FirstUser.FirstName, &netting.FirstUser.LastName,
+		&netting.SecondUser.ID, &netting.SecondUser.TgID, &netting.SecondUser.Username,
+		&netting.SecondUser.FirstName, &netting.SecondUser.LastName)
+	if err == sql.ErrNoRows {
+		return netting, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	netting.Debts, err = r.queryDebts(debtsQuery+fmt.Sprintf(`WHERE D.id in
+		(SELECT debt_id FROM`+" %s "+`WHERE netting_id = $1)
+	ORDER BY S.started_at DESC, D.id`, NettingDebts), nettingID)
+	return netting, err
+}
+
+func (r *PgRepository) UpdateNettingConfirmation(netting *models.Netting) error {
+	queryString := fmt.Sprintf(`UPDATE`+" %s "+`SET first_confirmed = $2, second_confirmed = $3
+		WHERE id = $1`, NettingTable)
+
+	_, err := r.Conn.Exec(queryString, netting.ID, netting.FirstConfirmed, netting.SecondConfirmed)
+	return err
+}
+
+// CompleteNetting marks debts of netting as payed and saves pending debt of their balance from
+// net debtor to net creditor. Debt is saved in the latest session, where net debtor owes net creditor.
+// Reminder about saved debt is scheduled in chat of this session, if reminders are enabled there.
+// If some of debts were paid or netted already, netting is declined and false is returned.
+func (r *PgRepository) CompleteNetting(nettingID uint64) (bool, error) {
+	tx, err := r.Conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var outdated int
+	queryString := fmt.Sprintf(`SELECT count(*) FROM (SELECT D.status FROM`+" %s "+`as D JOIN`+" %s "+`as ND
+		on D.id = ND.debt_id WHERE ND.netting_id = $1 FOR UPDATE OF D) as L WHERE L.status <> 'pending'`,
+		DebtsTable, NettingDebts)
+	if err = tx.QueryRow(queryString, nettingID).Scan(&outdated); err != nil {
+		return false, err
+	}
+
+	status := models.NettingDone
+	if outdated != 0 {
+		status = models.NettingDeclined
+	} else {
+		nettingDebts := fmt.Sprintf(`SELECT debt_id FROM`+" %s "+`WHERE netting_id = $1`, NettingDebts)
+		queryString = fmt.Sprintf(`UPDATE`+" %s "+`SET status = 'payed' WHERE id in (`+nettingDebts+`)`,
+			DebtsTable)
+		if _, err = tx.Exec(queryString, nettingID); err != nil {
+			return false, err
+		}
+
+		queryString = fmt.Sprintf(`INSERT INTO`+" %s "+`(creditor_id, debtor_id, money)
+		SELECT D.creditor_id, D.debtor_id, abs(N.money) FROM`+" %s "+`as N
+			JOIN`+" %s "+`as ND on N.id = ND.netting_id
+			JOIN`+" %s "+`as D on ND.debt_id = D.id
+			JOIN`+" %s "+`as C on D.creditor_id = C.id
+		WHERE N.id = $1 AND N.money <> 0
+			AND C.user_id = CASE WHEN N.money > 0 THEN N.first_user_id ELSE N.second_user_id END
+		ORDER BY D.id DESC LIMIT 1
+		RETURNING id`, DebtsTable, NettingTable, NettingDebts, DebtsTable, MembersTable)
+		var debtID uint64
+		err = tx.QueryRow(queryString, nettingID).Scan(&debtID)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return false, err
+		default:
+			if err = createNetDebtReminder(tx, debtID); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	queryString = fmt.Sprintf(`UPDATE`+" %s "+`SET status = $2 WHERE id = $1`, NettingTable)
+	if _, err = tx.Exec(queryString, nettingID, status); err != nil {
+		return false, err
+	}
+	return outdated == 0, tx.Commit()
+}
+
+// createNetDebtReminder reminds about debt left after netting like about debts saved at finish,
+// reminders of netted debts stop, because they are paid.
+func createNetDebtReminder(tx *sql.Tx, debtID uint64) error {
+	queryString := fmt.Sprintf(`INSERT INTO`+" %s "+`(debt_id, chat_id, next_at)
+	SELECT D.id, S.chat_id, current_timestamp + make_interval(days => coalesce(CS.remind_interval_days, $2))
+	FROM`+" %s "+`as D
+		JOIN`+" %s "+`as M on D.creditor_id = M.id
+		JOIN`+" %s "+`as S on M.session_id = S.uuid
+		LEFT JOIN`+" %s "+`as CS on S.chat_id = CS.chat_id
+	WHERE D.id = $1 AND coalesce(CS.remind_enabled, true)
+	ON CONFLICT (debt_id) DO NOTHING`, ReminderTable, DebtsTable, MembersTable, SessionTable, SettingsTable)
+
+	_, err := tx.Exec(queryString, debtID, models.DefaultRemindIntervalDays)
+	return err
+}
+
+func (r *PgRepository) DeclineNetting(nettingID uint64) error {
+	queryString := fmt.Sprintf(`UPDATE`+" %s "+`SET status = 'declined' WHERE id = $1`, NettingTable)
+
+	_, err := r.Conn.Exec(queryString, nettingID)
+	return err
+}
+
+func (r *PgRepository) GetAllUsers(sessionUUID internal.UUID) ([]*models.User, error) {
+	result := make([]*models.User, 0)
+
+	queryString := fmt.Sprintf(`select U.id, coalesce(U.tg_id, 0), U.username, U.first_name, U.last_name,
+		U.created_at, U.requisites, U.notify 
+	from`+" %s "+`as U join`+" %s "+`as M on U.id = M.user_id where M.session_id = $1
+	order by M.id`, UserTable, MembersTable)
+
+	rows, err := r.Conn.Query(queryString, sessionUUID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var tmpUser = &models.User{}
+		err = rows.Scan(&tmpUser.ID, &tmpUser.TgID, &tmpUser.Username, &tmpUser.FirstName, &tmpUser.LastName,
+			&tmpUser.CreatedAt, &tmpUser.Requisites, &tmpUser.Notify)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tmpUser)
+	}
+	return result, err
+}
+
+func (r *PgRepository) AddTransfer(senderID uint64, recipientID uint64, money int) error {
+	queryString := fmt.Sprintf(`INSERT INTO`+" %s "+`
+		(sender_id, recipient_id, money, created_at) VALUES 
+		($1, $2, $3, current_timestamp);`, TransferTable)
+
+	_, err := r.Conn.Exec(queryString, senderID, recipientID, money)
+	return err
+}
+
+func (r *PgRepository) GetAllTransfers(sessionUUID internal.UUID) ([]*models.Transfer, error) {
+	result := make([]*models.Transfer, 0)
+
+	queryString := fmt.Sprintf(`SELECT S.user_id, R.user_id, T.money
+	FROM`+" %s "+`as T JOIN`+" %s "+`as S on S.id = T.sender_id
+		JOIN`+" %s "+`as R on R.id = T.recipient_id
+	WHERE S.session_id = $1`, TransferTable, MembersTable, MembersTable)
+
+	rows, err := r.Conn.Query(queryString, sessionUUID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var tmpTransfer = models.NewEmptyTransfer()
+		err = rows.Scan(&tmpTransfer.SenderID, &tmpTransfer.RecipientID, &tmpTransfer.Money)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tmpTransfer)
+	}
+
+	return result, err
+}
+
+func (r *PgRepository) GetUsersTransfers(sessionUUID internal.UUID) ([]*models.UserTransfer, error) {
+	result := make([]*models.UserTransfer, 0)
+
+	queryString := fmt.Sprintf(`SELECT SU.id, coalesce(SU.tg_id, 0), SU.username, SU.first_name, SU.last_name,
+		RU.id, coalesce(RU.tg_id, 0), RU.username, RU.first_name, RU.last_name, T.money
+	FROM`+" %s "+`as T JOIN`+" %s "+`as S on S.id = T.sender_id
+		JOIN`+" %s "+`as R on R.id = T.recipient_id
+		JOIN`+" %s "+`as SU on SU.id = S.user_id
+		JOIN`+" %s "+`as RU on RU.id = R.user_id
+	WHERE S.session_id = $1
+	ORDER BY T.id`, TransferTable, MembersTable, MembersTable, UserTable, UserTable)
+
+	rows, err := r.Conn.Query(queryString, sessionUUID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var tmpTransfer = models.NewEmptyUserTransfer()
+		err = rows.Scan(&tmpTransfer.Sender.ID, &tmpTransfer.Sender.TgID, &tmpTransfer.Sender.Username,
+			&tmpTransfer.Sender.FirstName, &tmpTransfer.Sender.LastName, &tmpTransfer.Recipient.ID,
+			&tmpTransfer.Recipient.TgID, &tmpTransfer.Recipient.Username, &tmpTransfer.Recipient.FirstName,
+			&tmpTransfer.Recipient.LastName, &tmpTransfer.Money)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tmpTransfer)
+	}
+
+	return result, err
+}
+
+func (r *PgRepository) AddPotContribution(memberID uint64, money int) error {
+	queryString := fmt.Sprintf(`INSERT INTO`+" %s "+`
+		(member_id, money, created_at) VALUES 
+		($1, $2, current_timestamp);`, PotTable)
+
+	_, err := r.Conn.Exec(queryString, memberID, money)
+	return err
+}
+
+func (r *PgRepository) GetPotContributions(sessionUUID internal.UUID) ([]*models.Contribution, error) {
+	result := make([]*models.Contribution, 0)
+
+	queryString := fmt.Sprintf(`SELECT M.user_id, P.money
+	FROM`+" %s "+`as M JOIN`+" %s "+`as P on M.id = P.member_id
+	WHERE M.session_id = $1`, MembersTable, PotTable)
+
+	rows, err := r.Conn.Query(queryString, sessionUUID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var tmpContribution = models.NewEmptyContribution()
+		err = rows.Scan(&tmpContribution.UserID, &tmpContribution.Money)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tmpContribution)
+	}
+
+	return result, err
+}
+
+func (r *PgRepository) GetUserRequisites(userID uint64) ([]*models.Requisite, error) {
+	result := make([]*models.Requisite, 0)
+
+	queryString := fmt.Sprintf(`SELECT id, user_id, kind, value, coalesce(encrypted_for, 0), bank, is_default
+	FROM`+" %s "+`WHERE user_id = $1
+	ORDER BY id`, RequisiteTable)
+
+	rows, err := r.Conn.Query(queryString, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var tmpRequisite = models.NewEmptyRequisite()
+		err = rows.Scan(&tmpRequisite.ID, &tmpRequisite.UserID, &tmpRequisite.Kind, &tmpRequisite.Encrypted,
+			&tmpRequisite.EncryptedFor, &tmpRequisite.Bank, &tmpRequisite.IsDefault)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tmpRequisite)
+	}
+
+	return result, err
+}
+
+func (r *PgRepository) GetDefaultRequisite(userID uint64) (*models.Requisite, error) {
+	var (
+		requisite = models.NewEmptyRequisite()
+		err       error
+	)
+	queryString := fmt.Sprintf(`SELECT id, user_id, kind, value, coalesce(encrypted_for, 0), bank, is_default
+	FROM`+" %s "+`WHERE user_id = $1 AND is_default;`, RequisiteTable)
+
+	rows, err := r.Conn.Query(queryString, userID)
+	if err == nil {
+		for rows.Next() {
+			err = rows.Scan(&requisite.ID, &requisite.UserID, &requisite.Kind, &requisite.Encrypted,
+				&requisite.EncryptedFor, &requisite.Bank, &requisite.IsDefault)
+		}
+	}
+	return requisite, err
+}
+
+func (r *PgRepository) AddRequisite(requisite *models.Requisite) (uint64, error) {
+	var id uint64
+	encrypted, err := r.cipher.Encrypt(requisite.Value, requisiteOwner(requisite.UserID))
+	if err != nil {
+		return 0, err
+	}
+
+	queryString := fmt.Sprintf(`INSERT INTO`+" %s "+`
+		(user_id, kind, value, bank, is_default, created_at) VALUES 
+		($1, $2, $3, $4, $5, current_timestamp) returning id;`, RequisiteTable)
+
+	row := r.Conn.QueryRow(queryString, requisite.UserID, requisite.Kind, encrypted, requisite.Bank,
+		requisite.IsDefault)
+	err = row.Scan(&id)
+	return id, err
+}
+
+func (r *PgRepository) SetDefaultRequisite(userID uint64, requisiteID uint64) error {
+	tx, err := r.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queryString := fmt.Sprintf(`UPDATE`+" %s "+`SET is_default = false WHERE user_id = $1;`, RequisiteTable)
+	if _, err = tx.Exec(queryString, userID); err != nil {
+		return err
+	}
+
+	queryString = fmt.Sprintf(`UPDATE`+" %s "+`SET is_default = true WHERE user_id = $1 AND id = $2;`,
+		RequisiteTable)
+	if _, err = tx.Exec(queryString, userID, requisiteID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PgRepository) DeleteRequisite(requisiteID uint64) error {
+	queryString := fmt.Sprintf(`DELETE FROM`+" %s "+`WHERE id = $1;`, RequisiteTable)
+
+	_, err := r.Conn.Exec(queryString, requisiteID)
+	return err
+}
+
+// requisiteOwner is additional data for requisite encryption.
+func requisiteOwner(userID uint64) string {
+	return strconv.FormatUint(userID, 10)
+}
+
+// RevealRequisite decrypts requisite value, it must be called only
+// when requisite is shown to its owner or his debtors.
+func (r *PgRepository) RevealRequisite(requisite *models.Requisite) error {
+	owner := requisite.UserID
+	if requisite.EncryptedFor != 0 {
+		owner = requisite.EncryptedFor
+	}
+
+	value, err := r.cipher.Decrypt(requisite.Encrypted, requisiteOwner(owner))
+	if err != nil {
+		return err
+	}
+	requisite.Value = value
+	return nil
+}
+
+// reencrypt returns requisite value encrypted with current key and bound to its current owner.
+func (r *PgRepository) reencrypt(requisite *models.Requisite) (string, error) {
+	if err := r.RevealRequisite(requisite); err != nil {
+		return "", err
+	}
+	return r.cipher.Encrypt(requisite.Value, requisiteOwner(requisite.UserID))
+}
+
+// ReencryptRequisites encrypts with current key all requisites, which are stored
+// unencrypted, encrypted with old key or for previous owner, and returns number of updated rows.
+func (r *PgRepository) ReencryptRequisites() (int, error) {
+	tx, err := r.Conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	queryString := fmt.Sprintf(`SELECT id, user_id, value, coalesce(encrypted_for, 0) FROM`+" %s "+`FOR UPDATE`,
+		RequisiteTable)
+
+	rows, err := tx.Query(queryString)
+	if err != nil {
+		return 0, err
+	}
+
+	outdated := make([]*models.Requisite, 0)
+	for rows.Next() {
+		var tmpRequisite = models.NewEmptyRequisite()
+		err = rows.Scan(&tmpRequisite.ID, &tmpRequisite.UserID, &tmpRequisite.Encrypted, &tmpRequisite.EncryptedFor)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !r.cipher.IsCurrent(tmpRequisite.Encrypted) || tmpRequisite.EncryptedFor != 0 {
+			outdated = append(outdated, tmpRequisite)
+		}
+	}
+	rows.Close()
+
+	queryString = fmt.Sprintf(`UPDATE`+" %s "+`SET value = $2, encrypted_for = NULL WHERE id = $1;`, RequisiteTable)
+	for _, requisite := range outdated {
+		encrypted, err := r.reencrypt(requisite)
+		if err != nil {
+			return 0, fmt.Errorf("requisite %d: %v", requisite.ID, err)
+		}
+
+		if _, err = tx.Exec(queryString, requisite.ID, encrypted); err != nil {
+			return 0, err
+		}
+	}
+	return len(outdated), tx.Commit()
 }
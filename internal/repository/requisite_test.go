@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"collector-telegram-bot/config"
+	"collector-telegram-bot/internal/models"
+	"collector-telegram-bot/internal/secret"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+)
+
+func newRepository(t *testing.T) *PgRepository {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+
+	cipher, err := secret.NewCipher(config.EncryptionParams{
+		CurrentKey: "v1",
+		Keys:       map[string]string{"v1": base64.StdEncoding.EncodeToString(key)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &PgRepository{cipher: cipher}
+}
+
+func TestRequisiteDecryptsAfterUsersMerge(t *testing.T) {
+	r := newRepository(t)
+	encrypted, err := r.cipher.Encrypt("2200700012345673", requisiteOwner(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Migration moves requisite of duplicate user 5 to user 3 and keeps previous owner
+	merged := &models.Requisite{ID: 1, UserID: 3, EncryptedFor: 5, Encrypted: encrypted}
+	if err = r.RevealRequisite(merged); err != nil || merged.Value != "2200700012345673" {
+		t.Fatalf("RevealRequisite() = %q, %v, want value of merged requisite", merged.Value, err)
+	}
+
+	withoutOwner := &models.Requisite{ID: 1, UserID: 3, Encrypted: encrypted}
+	if err = r.RevealRequisite(withoutOwner); err == nil {
+		t.Error("value is decrypted for user, which it isn't bound to")
+	}
+
+	reencrypted, err := r.reencrypt(&models.Requisite{ID: 1, UserID: 3, EncryptedFor: 5, Encrypted: encrypted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebound := &models.Requisite{ID: 1, UserID: 3, Encrypted: reencrypted}
+	if err = r.RevealRequisite(rebound); err != nil || rebound.Value != "2200700012345673" {
+		t.Errorf("RevealRequisite() = %q, %v after reencryption, want value bound to new owner", rebound.Value, err)
+	}
+}
@@ -0,0 +1,101 @@
+package secret
+
+import (
+	"collector-telegram-bot/config"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// keySeparator divides key id and encrypted payload: "<key id>:<base64(nonce|ciphertext)>".
+// Plain requisites never contain it, so values without separator are unencrypted legacy ones.
+const keySeparator = ":"
+
+var UnknownKeyErr = fmt.Errorf("unknown encryption key")
+
+// Cipher encrypts secrets with AES-GCM. Every value keeps id of its key,
+// so old keys stay in config for decryption after rotation.
+type Cipher struct {
+	currentKey string
+	keys       map[string]cipher.AEAD
+}
+
+func NewCipher(params config.EncryptionParams) (*Cipher, error) {
+	keys := make(map[string]cipher.AEAD, len(params.Keys))
+	for keyID, encodedKey := range params.Keys {
+		if strings.Contains(keyID, keySeparator) {
+			return nil, fmt.Errorf("key id %q contains %q", keyID, keySeparator)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %v", keyID, err)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %v", keyID, err)
+		}
+
+		keys[keyID], err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %v", keyID, err)
+		}
+	}
+
+	if _, ok := keys[params.CurrentKey]; !ok {
+		return nil, fmt.Errorf("current key %q: %w", params.CurrentKey, UnknownKeyErr)
+	}
+	return &Cipher{currentKey: params.CurrentKey, keys: keys}, nil
+}
+
+// Encrypt encrypts value with current key. Additional data binds ciphertext
+// to its owner, so it can't be moved to another row.
+func (c *Cipher) Encrypt(value string, additionalData string) (string, error) {
+	aead := c.keys[c.currentKey]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(additionalData))
+	return c.currentKey + keySeparator + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts value with key it was encrypted by.
+// Unencrypted legacy values are returned as is.
+func (c *Cipher) Decrypt(value string, additionalData string) (string, error) {
+	keyID, payload, found := strings.Cut(value, keySeparator)
+	if !found {
+		return value, nil
+	}
+
+	aead, ok := c.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("key %s: %w", keyID, UnknownKeyErr)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("encrypted value is too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(additionalData))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// IsCurrent reports whether value is encrypted with current key.
+func (c *Cipher) IsCurrent(value string) bool {
+	return strings.HasPrefix(value, c.currentKey+keySeparator)
+}
@@ -0,0 +1,163 @@
+package secret
+
+import (
+	"collector-telegram-bot/config"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func newKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func newCipher(t *testing.T, currentKey string, keys map[string]string) *Cipher {
+	t.Helper()
+	c, err := NewCipher(config.EncryptionParams{CurrentKey: currentKey, Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	c := newCipher(t, "v1", map[string]string{"v1": newKey(t)})
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "card", value: "2200700012345678"},
+		{name: "phone", value: "+79991234567"},
+		{name: "empty", value: ""},
+		{name: "unicode", value: "Тинькофф: 2200 7000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := c.Encrypt(tt.value, "42")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encrypted == tt.value {
+				t.Fatal("value is not encrypted")
+			}
+			if !c.IsCurrent(encrypted) {
+				t.Errorf("IsCurrent(%q) = false", encrypted)
+			}
+
+			decrypted, err := c.Decrypt(encrypted, "42")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decrypted != tt.value {
+				t.Errorf("Decrypt() = %q, want %q", decrypted, tt.value)
+			}
+		})
+	}
+}
+
+func TestCipherLegacyValue(t *testing.T) {
+	c := newCipher(t, "v1", map[string]string{"v1": newKey(t)})
+
+	decrypted, err := c.Decrypt("2200700012345678", "42")
+	if err != nil || decrypted != "2200700012345678" {
+		t.Errorf("Decrypt() = %q, %v, want unencrypted value", decrypted, err)
+	}
+}
+
+func TestCipherWrongKey(t *testing.T) {
+	c := newCipher(t, "v1", map[string]string{"v1": newKey(t)})
+	encrypted, err := c.Encrypt("2200700012345678", "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("other key with same id", func(t *testing.T) {
+		other := newCipher(t, "v1", map[string]string{"v1": newKey(t)})
+		if _, err := other.Decrypt(encrypted, "42"); err == nil {
+			t.Error("value is decrypted with wrong key")
+		}
+	})
+
+	t.Run("other owner", func(t *testing.T) {
+		if _, err := c.Decrypt(encrypted, "43"); err == nil {
+			t.Error("value is decrypted with additional data of other owner")
+		}
+	})
+
+	t.Run("unknown key id", func(t *testing.T) {
+		other := newCipher(t, "v2", map[string]string{"v2": newKey(t)})
+		if _, err := other.Decrypt(encrypted, "42"); !errors.Is(err, UnknownKeyErr) {
+			t.Errorf("Decrypt() err = %v, want %v", err, UnknownKeyErr)
+		}
+	})
+
+	t.Run("truncated value", func(t *testing.T) {
+		if _, err := c.Decrypt("v1:AAAA", "42"); err == nil {
+			t.Error("truncated value is decrypted")
+		}
+	})
+}
+
+func TestCipherKeySwitch(t *testing.T) {
+	oldKey, newKey := newKey(t), newKey(t)
+	old := newCipher(t, "v1", map[string]string{"v1": oldKey})
+	encrypted, err := old.Encrypt("2200700012345678", "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := newCipher(t, "v2", map[string]string{"v1": oldKey, "v2": newKey})
+	if rotated.IsCurrent(encrypted) {
+		t.Error("value encrypted with old key is reported as current")
+	}
+
+	decrypted, err := rotated.Decrypt(encrypted, "42")
+	if err != nil || decrypted != "2200700012345678" {
+		t.Fatalf("Decrypt() = %q, %v, want value encrypted with old key", decrypted, err)
+	}
+
+	reencrypted, err := rotated.Encrypt(decrypted, "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rotated.IsCurrent(reencrypted) {
+		t.Error("reencrypted value is not encrypted with current key")
+	}
+
+	// Old key is removed from config after reencryption
+	current := newCipher(t, "v2", map[string]string{"v2": newKey})
+	if decrypted, err = current.Decrypt(reencrypted, "42"); err != nil || decrypted != "2200700012345678" {
+		t.Errorf("Decrypt() = %q, %v, want reencrypted value", decrypted, err)
+	}
+}
+
+func TestNewCipherInvalidParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params config.EncryptionParams
+	}{
+		{name: "no keys", params: config.EncryptionParams{CurrentKey: "v1"}},
+		{name: "unknown current key", params: config.EncryptionParams{CurrentKey: "v2",
+			Keys: map[string]string{"v1": newKey(t)}}},
+		{name: "not base64", params: config.EncryptionParams{CurrentKey: "v1",
+			Keys: map[string]string{"v1": "not base64!"}}},
+		{name: "short key", params: config.EncryptionParams{CurrentKey: "v1",
+			Keys: map[string]string{"v1": base64.StdEncoding.EncodeToString([]byte("short"))}}},
+		{name: "separator in id", params: config.EncryptionParams{CurrentKey: "v:1",
+			Keys: map[string]string{"v:1": newKey(t)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCipher(tt.params); err == nil {
+				t.Error("NewCipher() err = nil")
+			}
+		})
+	}
+}
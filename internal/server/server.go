@@ -3,11 +3,17 @@ package server
 import (
 	"collector-telegram-bot/config"
 	"collector-telegram-bot/internal/delivery/group_handler"
+	"collector-telegram-bot/internal/delivery/middleware"
 	"collector-telegram-bot/internal/delivery/private_handler"
+	"collector-telegram-bot/internal/dispatcher"
 	"collector-telegram-bot/internal/models"
+	"collector-telegram-bot/internal/notifier"
 	repo "collector-telegram-bot/internal/repository"
+	"collector-telegram-bot/internal/secret"
 	"collector-telegram-bot/internal/usecase/group_usecase"
 	"collector-telegram-bot/internal/usecase/private_usecase"
+	"collector-telegram-bot/internal/usecase/reminder_usecase"
+	"collector-telegram-bot/internal/usecase/user_usecase"
 	"fmt"
 	"time"
 
@@ -16,6 +22,9 @@ import (
 	"gopkg.in/telebot.v3"
 )
 
+// schedulerTick is how often due reminders and inactive sessions are checked.
+const schedulerTick = time.Minute
+
 type Server struct {
 	config *config.ServerConfig
 	logger *logrus.Entry
@@ -27,9 +36,13 @@ func CreateServer(config *config.ServerConfig, logger *logrus.Entry, token strin
 }
 
 func (s *Server) Start() {
+	// Updates of one chat are processed one by one, so handlers must run synchronously
+	updatesDispatcher := dispatcher.New(s.logger, &telebot.LongPoller{Timeout: 10 * time.Second},
+		s.config.DispatcherParams.QueueSize, s.config.DispatcherParams.StatsInterval)
 	pref := telebot.Settings{
-		Token:  s.token,
-		Poller: &telebot.LongPoller{Timeout: 10 * time.Second},
+		Token:       s.token,
+		Poller:      updatesDispatcher,
+		Synchronous: true,
 	}
 
 	b, err := telebot.NewBot(pref)
@@ -37,26 +50,111 @@ func (s *Server) Start() {
 		s.logger.Fatalf("Server error: %s", fmt.Sprintf("%v", err))
 	}
 
-	connection := models.NewPgSQLConnection(s.config.DatabaseParams)
+	repository := s.createRepository()
+
+	// Notifications are sent in background until bot is stopped
+	tgNotifier := notifier.New(s.logger, b)
+	go tgNotifier.Run()
 
-	repository := repo.NewPgRepository(s.logger, connection)
+	privateUsecase := private_usecase.New(s.logger, repository, tgNotifier)
+	groupUsecase := group_usecase.New(s.logger, repository, tgNotifier, s.config.SessionParams.ReopenGracePeriod)
+	userUsecase := user_usecase.New(s.logger, repository)
 
-	privateUsecase := private_usecase.New(s.logger, repository)
-	groupUsecase := group_usecase.New(s.logger, repository)
+	reminderUsecase := reminder_usecase.New(s.logger, repository, tgNotifier)
+	go s.runScheduler(reminderUsecase, groupUsecase)
 
-	privateHandler := private_handler.New(s.logger, privateUsecase)
+	privateHandler := private_handler.New(s.logger, privateUsecase, groupUsecase)
 	groupHandler := group_handler.New(s.logger, groupUsecase)
 
+	b.Use(middleware.SyncProfile(s.logger, userUsecase))
+
 	b.Handle("/info", privateHandler.Info)
-	b.Handle("/сессии", privateHandler.Sessions)
+	b.Handle("/сессии", privateHandler.Sessions, middleware.PrivateOnly)
+	b.Handle("/sessions", privateHandler.Sessions, middleware.PrivateOnly)
+	b.Handle("/me", privateHandler.Me, middleware.PrivateOnly)
+	b.Handle("/notify", privateHandler.Notify, middleware.PrivateOnly)
+	b.Handle("/paid", privateHandler.Paid, middleware.PrivateOnly)
+	b.Handle(&private_handler.DebtPaidBtn, privateHandler.MarkDebtPaid)
+	b.Handle("/netting", privateHandler.Netting, middleware.PrivateOnly)
+	b.Handle(&private_handler.NettingProposeBtn, privateHandler.ProposeNetting)
+	b.Handle(&private_handler.NettingConfirmBtn, privateHandler.ConfirmNetting)
+	b.Handle(&private_handler.NettingDeclineBtn, privateHandler.DeclineNetting)
+	b.Handle(&private_handler.SessionsPageBtn, privateHandler.SessionsPage)
+	b.Handle(&private_handler.SessionDetailsBtn, privateHandler.SessionDetails)
+	b.Handle("/requisites", privateHandler.Requisites, middleware.PrivateOnly)
+	b.Handle("/requisites_add", privateHandler.AddRequisite, middleware.PrivateOnly)
+	b.Handle("/requisites_default", privateHandler.SetDefaultRequisite, middleware.PrivateOnly)
+	b.Handle("/requisites_del", privateHandler.DeleteRequisite, middleware.PrivateOnly)
 
-	b.Handle("/start", groupHandler.StartSession)
-	b.Handle("/add", groupHandler.AddExpense)
+	b.Handle("/start", middleware.ByChatType(privateHandler.Start, groupHandler.StartSession))
+	b.Handle("/invite", groupHandler.Invite)
+	b.Handle("/switch", groupHandler.Switch)
+	b.Handle("/remind", middleware.ByChatType(privateHandler.Remind, groupHandler.Remind))
+	b.Handle("/autofinish", groupHandler.AutoFinish)
+	b.Handle("/add", middleware.ByChatType(privateHandler.AddExpense, groupHandler.AddExpense))
+	b.Handle(&private_handler.ExpenseSessionBtn, privateHandler.AddExpenseToSession)
+	b.Handle(&private_handler.ExpenseNotifyBtn, privateHandler.SwitchExpenseNotify)
+	b.Handle("/edit", groupHandler.EditExpense)
+	b.Handle("/transfer", groupHandler.AddTransfer)
+	b.Handle("/contribute", groupHandler.AddContribution)
+	b.Handle("/pot", groupHandler.GetPot)
 	b.Handle("/debts", groupHandler.GetDebts)
 	b.Handle("/count", groupHandler.GetCosts)
 	b.Handle("/finish", groupHandler.FinishSession)
+	b.Handle("/reopen", groupHandler.Reopen)
+	b.Handle("/history", groupHandler.History)
+	b.Handle("/show", groupHandler.Show)
+	b.Handle("/join", groupHandler.JoinSession)
+	b.Handle("/add_member", groupHandler.AddMember)
+	b.Handle("/leave", groupHandler.LeaveSession)
+	b.Handle("/kick", groupHandler.KickMember)
+	b.Handle("/role", groupHandler.Role)
+	b.Handle("/members", groupHandler.GetMembers)
+	b.Handle(&group_handler.JoinBtn, groupHandler.JoinSessionByButton)
+	b.Handle(&group_handler.PayQRBtn, groupHandler.PayByQR)
+	b.Handle("/guest", groupHandler.AddGuest)
+	b.Handle("/add_for", groupHandler.AddGuestExpense)
+	b.Handle("/merge_guest", groupHandler.MergeGuest)
 
 	s.logger.Info("Server is working")
 
 	b.Start()
 }
+
+// runScheduler sends due debt reminders and closes inactive sessions on every tick until process exits.
+func (s *Server) runScheduler(reminderUsecase reminder_usecase.ReminderUsecase,
+	groupUsecase group_usecase.GroupUsecase) {
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if err := reminderUsecase.SendDueReminders(now); err != nil {
+			s.logger.Warnf("Send reminders err: %v", err)
+		}
+		if err := groupUsecase.CloseInactiveSessions(now); err != nil {
+			s.logger.Warnf("Close inactive sessions err: %v", err)
+		}
+	}
+}
+
+func (s *Server) createRepository() repo.Repository {
+	cipher, err := secret.NewCipher(s.config.EncryptionParams)
+	if err != nil {
+		s.logger.Fatalf("Encryption config error: %v", err)
+	}
+
+	connection := models.NewPgSQLConnection(s.config.DatabaseParams)
+	return repo.NewPgRepository(s.logger, connection, cipher)
+}
+
+// Reencrypt encrypts stored requisites with current key. It must be run
+// after key rotation, before old key is removed from config.
+func (s *Server) Reencrypt() {
+	repository := s.createRepository()
+
+	updated, err := repository.ReencryptRequisites()
+	if err != nil {
+		s.logger.Fatalf("Reencryption error: %v", err)
+	}
+	s.logger.Infof("Reencrypted %d requisites", updated)
+}
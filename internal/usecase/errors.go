@@ -3,6 +3,36 @@ package usecase
 import "fmt"
 
 var (
-	SessionExistsErr    = fmt.Errorf("there is active session")
-	SessionNotExistsErr = fmt.Errorf("no active session")
+	SessionExistsErr          = fmt.Errorf("there is active session with same name")
+	SessionNotExistsErr       = fmt.Errorf("no active session")
+	SessionNotChosenErr       = fmt.Errorf("several active sessions, none is chosen")
+	UserNotExistsErr          = fmt.Errorf("user not found")
+	SelfTransferErr           = fmt.Errorf("transfer to yourself")
+	NotPotSessionErr          = fmt.Errorf("session is not in pot mode")
+	PotSessionErr             = fmt.Errorf("session is in pot mode")
+	PotInsufficientErr        = fmt.Errorf("not enough money in pot")
+	AlreadyMemberErr          = fmt.Errorf("user is already member of session")
+	NotMemberErr              = fmt.Errorf("user is not member of session")
+	NotCreatorErr             = fmt.Errorf("only session creator can do it")
+	CreatorLeaveErr           = fmt.Errorf("session creator can't leave session")
+	MemberHasRecordsErr       = fmt.Errorf("member has expenses in session")
+	GuestNotExistsErr         = fmt.Errorf("guest not found")
+	InvalidRequisiteErr       = fmt.Errorf("invalid requisite")
+	RequisiteNotExistsErr     = fmt.Errorf("requisite not found")
+	DebtNotExistsErr          = fmt.Errorf("debt not found")
+	NotDebtorErr              = fmt.Errorf("user is not debtor")
+	NoRequisiteErr            = fmt.Errorf("creditor has no requisites")
+	NettingNotExistsErr       = fmt.Errorf("netting not found")
+	NettingProposedErr        = fmt.Errorf("debts are already proposed for netting")
+	NettingOutdatedErr        = fmt.Errorf("debts of netting are already settled")
+	InvalidSettingsErr        = fmt.Errorf("invalid chat settings")
+	ClosedSessionNotExistsErr = fmt.Errorf("no closed session")
+	ReopenExpiredErr          = fmt.Errorf("grace period of reopening is over")
+	SessionSettledErr         = fmt.Errorf("debts of session are already paid")
+	SessionFinishedErr        = fmt.Errorf("session is already finished")
+	NoPermissionErr           = fmt.Errorf("user has no permission")
+	ObserverErr               = fmt.Errorf("observer can't record expenses")
+	InvalidRoleErr            = fmt.Errorf("invalid role")
+	CostNotExistsErr          = fmt.Errorf("expense not found")
+	CreatorRoleErr            = fmt.Errorf("role of session creator can't be changed")
 )
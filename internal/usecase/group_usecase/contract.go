@@ -3,12 +3,39 @@ package group_usecase
 import (
 	"collector-telegram-bot/internal/dto"
 	"collector-telegram-bot/internal/models"
+	"time"
 )
 
 type GroupUsecase interface {
-	CreateSession(info dto.CreateSessionDTO) error
+	CreateSession(info dto.CreateSessionDTO) (*models.Session, error)
 	AddExpenseToSession(info dto.AddExpenseDTO) error
-	GetAllExpenses(info dto.GetCostsDTO) (map[string]models.AllUserCosts, error)
-	GetAllDebts(info dto.GetDebtsDTO) (map[string]models.AllUserDebts, error)
+	EditExpense(info dto.EditExpenseDTO) error
+	GetAllExpenses(info dto.GetCostsDTO) (map[uint64]models.AllUserCosts, error)
+	GetAllDebts(info dto.GetDebtsDTO) (*models.Session, map[uint64]models.AllUserDebts, error)
+	GetPayment(info dto.GetPaymentDTO) (*models.Payment, error)
+	AddTransferToSession(info dto.AddTransferDTO) error
+	GetAllTransfers(info dto.GetTransfersDTO) ([]*models.UserTransfer, error)
+	AddContributionToPot(info dto.AddContributionDTO) error
+	GetPot(info dto.GetPotDTO) (*models.PotState, error)
 	FinishSession(info dto.FinishSessionDTO) error
+	CanFinish(info dto.FinishSessionDTO) error
+	JoinSession(info dto.JoinSessionDTO) error
+	CloseInactiveSessions(now time.Time) error
+	ReopenSession(info dto.ReopenSessionDTO) (*models.Session, error)
+	SetRole(info dto.SetRoleDTO) error
+	SetAdminsTreasurers(info dto.SetAdminsTreasurersDTO) error
+	GetHistory(info dto.GetHistoryDTO) ([]*models.ArchivedSession, error)
+	ShowSession(info dto.ShowSessionDTO) (*models.SessionArchive, error)
+	GetChatSettings(info dto.GetChatSettingsDTO) (*models.ChatSettings, error)
+	SaveChatSettings(info dto.SaveChatSettingsDTO) error
+	GetChatSessions(info dto.GetChatSessionsDTO) ([]*models.Session, *models.Session, error)
+	SwitchSession(info dto.SwitchSessionDTO) (*models.Session, error)
+	GetInviteToken(info dto.GetInviteDTO) (string, error)
+	JoinByInvite(info dto.JoinByInviteDTO) (*models.Session, error)
+	AddMember(info dto.ManageMemberDTO) error
+	LeaveSession(info dto.LeaveSessionDTO) error
+	KickMember(info dto.ManageMemberDTO) error
+	GetMembers(info dto.GetMembersDTO) (*models.Session, []*models.SessionMember, error)
+	AddGuest(info dto.AddGuestDTO) error
+	MergeGuest(info dto.MergeGuestDTO) error
 }
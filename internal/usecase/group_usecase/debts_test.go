@@ -0,0 +1,32 @@
+package group_usecase
+
+import (
+	"collector-telegram-bot/internal/dto"
+	"collector-telegram-bot/internal/models"
+	"testing"
+)
+
+func TestGetAllDebtsSkipsRequisiteWhichCantBeDecrypted(t *testing.T) {
+	r := newFakeRepo()
+	session := r.addSession(member(first, models.RoleCreator), member(second, models.RoleMember),
+		member(third, models.RoleMember))
+	r.costs[session.UUID] = []*models.Cost{{UserID: 1, Money: 300}, {UserID: 2, Money: 600}}
+	r.requisites[1] = &models.Requisite{ID: 1, UserID: 1, Kind: models.RequisiteCard, Encrypted: "2200700012345673"}
+	r.requisites[2] = &models.Requisite{ID: 2, UserID: 2, Kind: models.RequisiteCard, Encrypted: brokenValue}
+
+	uc := New(nopLogger{}, r, nil, 0)
+	_, debts, err := uc.GetAllDebts(dto.GetDebtsDTO{ChatID: session.ChatID, UserID: first.TgID})
+	if err != nil {
+		t.Fatalf("GetAllDebts() err = %v, want debts without broken requisite", err)
+	}
+
+	if requisite := debts[1].Requisite; requisite == nil || requisite.Value != "2200700012345673" {
+		t.Errorf("requisite of first creditor = %+v, want revealed one", requisite)
+	}
+	if requisite := debts[2].Requisite; requisite != nil {
+		t.Errorf("requisite of second creditor = %+v, want nil", requisite)
+	}
+	if len(debts[2].Debts) != 2 {
+		t.Errorf("second creditor has %d debtors, want 2", len(debts[2].Debts))
+	}
+}
@@ -0,0 +1,170 @@
+package group_usecase
+
+import (
+	"collector-telegram-bot/internal"
+	"collector-telegram-bot/internal/models"
+	repo "collector-telegram-bot/internal/repository"
+	"fmt"
+	"time"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Debugf(string, ...interface{}) {}
+func (nopLogger) Warnf(string, ...interface{})  {}
+
+// brokenValue is encrypted value of requisite, which can't be decrypted.
+const brokenValue = "broken"
+
+// fakeRepo keeps sessions of chats in memory, methods which tests don't use panic on nil Repository.
+type fakeRepo struct {
+	repo.Repository
+	sessions   []*models.Session
+	members    map[internal.UUID][]*models.SessionMember
+	costs      map[internal.UUID][]*models.Cost
+	requisites map[uint64]*models.Requisite
+	settings   map[int64]*models.ChatSettings
+	finishedAt map[internal.UUID]time.Time
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{
+		members:    make(map[internal.UUID][]*models.SessionMember),
+		costs:      make(map[internal.UUID][]*models.Cost),
+		requisites: make(map[uint64]*models.Requisite),
+		settings:   make(map[int64]*models.ChatSettings),
+		finishedAt: make(map[internal.UUID]time.Time),
+	}
+}
+
+func (r *fakeRepo) GetUser(tgID int64) (*models.User, error) {
+	for _, members := range r.members {
+		for _, member := range members {
+			if member.User.TgID == tgID {
+				return member.User, nil
+			}
+		}
+	}
+	return models.NewUser(), nil
+}
+
+func (r *fakeRepo) GetMemberBySession(sessionUUID internal.UUID, userID uint64) (*models.Member, error) {
+	for _, member := range r.members[sessionUUID] {
+		if member.User.ID == userID {
+			return &models.Member{SessionUUID: sessionUUID, UserID: userID, Role: member.Role}, nil
+		}
+	}
+	return models.NewEmptyMember(), nil
+}
+
+func (r *fakeRepo) GetChatSettings(chatID int64) (*models.ChatSettings, error) {
+	if settings, ok := r.settings[chatID]; ok {
+		return settings, nil
+	}
+	return models.NewChatSettings(chatID), nil
+}
+
+func (r *fakeRepo) GetActiveSessions(chatID int64) ([]*models.Session, error) {
+	result := make([]*models.Session, 0)
+	for _, session := range r.sessions {
+		if session.ChatID == chatID && session.State == models.SessionActive {
+			result = append(result, session)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeRepo) GetActiveSessionByName(chatID int64, sessionName string) (*models.Session, error) {
+	for _, session := range r.sessions {
+		if session.ChatID == chatID && session.SessionName == sessionName && session.State == models.SessionActive {
+			return session, nil
+		}
+	}
+	return models.NewEmptySession(), nil
+}
+
+func (r *fakeRepo) GetLastClosedSession(chatID int64) (*models.Session, time.Time, error) {
+	var (
+		last       = models.NewEmptySession()
+		finishedAt time.Time
+	)
+	for _, session := range r.sessions {
+		if session.ChatID == chatID && session.State != models.SessionActive &&
+			r.finishedAt[session.UUID].After(finishedAt) {
+			last, finishedAt = session, r.finishedAt[session.UUID]
+		}
+	}
+	return last, finishedAt, nil
+}
+
+func (r *fakeRepo) ReopenSession(sessionUUID internal.UUID) (bool, error) {
+	for _, session := range r.sessions {
+		if session.UUID == sessionUUID {
+			session.State = models.SessionActive
+			delete(r.finishedAt, sessionUUID)
+		}
+	}
+	return true, nil
+}
+
+func (r *fakeRepo) GetSessionMembers(sessionUUID internal.UUID) ([]*models.SessionMember, error) {
+	return r.members[sessionUUID], nil
+}
+
+func (r *fakeRepo) GetAllUsers(sessionUUID internal.UUID) ([]*models.User, error) {
+	users := make([]*models.User, 0)
+	for _, member := range r.members[sessionUUID] {
+		users = append(users, member.User)
+	}
+	return users, nil
+}
+
+func (r *fakeRepo) GetAllCosts(sessionUUID internal.UUID) ([]*models.Cost, error) {
+	return r.costs[sessionUUID], nil
+}
+
+func (r *fakeRepo) GetAllTransfers(internal.UUID) ([]*models.Transfer, error) {
+	return nil, nil
+}
+
+func (r *fakeRepo) GetDefaultRequisite(userID uint64) (*models.Requisite, error) {
+	requisite, ok := r.requisites[userID]
+	if !ok {
+		return models.NewEmptyRequisite(), nil
+	}
+	copied := *requisite
+	return &copied, nil
+}
+
+func (r *fakeRepo) RevealRequisite(requisite *models.Requisite) error {
+	if requisite.Encrypted == brokenValue {
+		return fmt.Errorf("cipher: message authentication failed")
+	}
+	requisite.Value = requisite.Encrypted
+	return nil
+}
+
+var (
+	first  = &models.User{ID: 1, TgID: 11, Username: "first"}
+	second = &models.User{ID: 2, TgID: 12, Username: "second"}
+	third  = &models.User{ID: 3, TgID: 13, Username: "third"}
+)
+
+// addSession adds active session of chat 100 with given members, first of them is its creator.
+func (r *fakeRepo) addSession(members ...*models.SessionMember) *models.Session {
+	session := models.NewSession(internal.UUID{byte(len(r.sessions) + 1)}, members[0].User.ID, 100, "session")
+	r.sessions = append(r.sessions, session)
+	r.members[session.UUID] = members
+	return session
+}
+
+func member(user *models.User, role string) *models.SessionMember {
+	return &models.SessionMember{User: user, Role: role}
+}
+
+// finish makes session settling, as if it was finished given time ago.
+func (r *fakeRepo) finish(session *models.Session, ago time.Duration) {
+	session.State = models.SessionSettling
+	r.finishedAt[session.UUID] = time.Now().Add(-ago)
+}
@@ -0,0 +1,35 @@
+package group_usecase
+
+import (
+	"collector-telegram-bot/internal/dto"
+	"collector-telegram-bot/internal/models"
+	"collector-telegram-bot/internal/usecase"
+	"testing"
+)
+
+func TestCanFinish(t *testing.T) {
+	r := newFakeRepo()
+	session := r.addSession(member(first, models.RoleCreator), member(second, models.RoleMember))
+	uc := New(nopLogger{}, r, nil, 0)
+
+	tests := []struct {
+		name string
+		user *models.User
+		want error
+	}{
+		{name: "creator", user: first, want: nil},
+		{name: "member", user: second, want: usecase.NoPermissionErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := uc.CanFinish(dto.FinishSessionDTO{ChatID: session.ChatID, UserID: tt.user.TgID})
+			if err != tt.want {
+				t.Errorf("CanFinish() err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if err := uc.CanFinish(dto.FinishSessionDTO{ChatID: 200, UserID: first.TgID}); err != usecase.SessionNotExistsErr {
+		t.Errorf("CanFinish() in chat without session err = %v, want %v", err, usecase.SessionNotExistsErr)
+	}
+}
@@ -4,63 +4,75 @@ import (
 	"collector-telegram-bot/internal"
 	"collector-telegram-bot/internal/dto"
 	"collector-telegram-bot/internal/models"
+	"collector-telegram-bot/internal/notifier"
 	repo "collector-telegram-bot/internal/repository"
 	"collector-telegram-bot/internal/usecase"
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const (
-	ActiveSession = "active"
-	EmptyString   = ""
+	EmptyString = ""
+
+	// inviteTokenBytes gives 22 characters token, telegram allows up to 64 in start payload
+	inviteTokenBytes = 16
 )
 
 type AppGroupUsecase struct {
-	log  internal.Logger
-	repo repo.Repository
+	log      internal.Logger
+	repo     repo.Repository
+	notifier notifier.Notifier
+	// reopenGrace is time after finish, during which session can be reopened
+	reopenGrace time.Duration
 }
 
-func New(log internal.Logger, repo repo.Repository) GroupUsecase {
-	return &AppGroupUsecase{log: log, repo: repo}
+func New(log internal.Logger, repo repo.Repository, notifier notifier.Notifier,
+	reopenGrace time.Duration) GroupUsecase {
+	return &AppGroupUsecase{log: log, repo: repo, notifier: notifier, reopenGrace: reopenGrace}
 }
 
-func (uc *AppGroupUsecase) CreateSession(info dto.CreateSessionDTO) error {
+func (uc *AppGroupUsecase) CreateSession(info dto.CreateSessionDTO) (*models.Session, error) {
 	sessionUUID := uuid.New()
 
-	userID, err := uc.upsertUser(info.UserID, info.Username)
+	userID, err := uc.upsertUser(info.UserID, info.Username, info.FirstName, info.LastName)
 
 	if err != nil {
-		return fmt.Errorf("usecase: %v", err.Error())
+		return nil, fmt.Errorf("usecase: %v", err.Error())
 	}
 
-	// Check, that aren't any active sessions in chat
-	curSession, err := uc.repo.GetActiveSessionByChatID(info.ChatID)
+	// Several sessions can be active in chat, but their names must differ
+	curSession, err := uc.repo.GetActiveSessionByName(info.ChatID, info.SessionName)
 	switch {
 	case err != nil:
-		return err
-	case curSession.State == ActiveSession:
-		return usecase.SessionExistsErr
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	case curSession.State == models.SessionActive:
+		return nil, usecase.SessionExistsErr
 	default:
 	}
 
 	session := models.NewSession(sessionUUID, userID, info.ChatID, info.SessionName)
+	session.ChatTitle = info.ChatTitle
+	if info.Pot {
+		session.Mode = models.SessionModePot
+	}
+	// Check above is only for clear answer, concurrent /start is caught by unique index
 	err = uc.repo.CreateNewSession(session)
-	if err != nil {
-		return fmt.Errorf("usecase: %v", err.Error())
+	if err == repo.SessionExistsErr {
+		return nil, usecase.SessionExistsErr
 	}
-
-	// Check that session is created and get uuid
-	curSession, err = uc.repo.GetActiveSessionByChatID(info.ChatID)
 	if err != nil {
-		return fmt.Errorf("usecase: %v", err.Error())
+		return nil, fmt.Errorf("usecase: %v", err.Error())
 	}
-	// Add creator to members
-	_, err = uc.repo.AddMemberToSession(curSession.UUID, userID)
-	return err
+	return session, nil
 }
 
-func (uc *AppGroupUsecase) upsertUser(userID int64, username string) (uint64, error) {
+func (uc *AppGroupUsecase) upsertUser(userID int64, username string, firstName string,
+	lastName string) (uint64, error) {
 	user, err := uc.repo.GetUser(userID)
 
 	if err != nil {
@@ -70,6 +82,8 @@ func (uc *AppGroupUsecase) upsertUser(userID int64, username string) (uint64, er
 	// If user not exists, you should create user
 	if user.ID == 0 {
 		user.Username = username
+		user.FirstName = firstName
+		user.LastName = lastName
 		user.TgID = userID
 		user.ID, err = uc.repo.CreateUser(user)
 		if err != nil {
@@ -79,175 +93,1071 @@ func (uc *AppGroupUsecase) upsertUser(userID int64, username string) (uint64, er
 	return user.ID, nil
 }
 
+// findUser looks for user by telegram id if it is known (mention of user
+// without username), otherwise by username.
+func (uc *AppGroupUsecase) findUser(tgID int64, username string) (*models.User, error) {
+	var (
+		user *models.User
+		err  error
+	)
+	if tgID != 0 {
+		user, err = uc.repo.GetUser(tgID)
+	} else {
+		user, err = uc.repo.GetUserByUsername(username)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	return user, nil
+}
+
 func (uc *AppGroupUsecase) AddExpenseToSession(info dto.AddExpenseDTO) error {
-	// Get session by chat id
-	session, err := uc.repo.GetActiveSessionByChatID(info.ChatID)
+	// Get session by selector or, for expense from private chat, by uuid
+	session, err := uc.getSession(info.ChatID, info.SessionUUID, info.UserID, info.SessionName)
 	if err != nil {
-		return fmt.Errorf("usecase: %v", err.Error())
+		return err
 	}
 
-	// If session not exist -- return error
-	if session.State != ActiveSession {
-		return usecase.SessionNotExistsErr
+	// Paying from pot is allowed only in pot mode and within pot balance
+	if info.FromPot {
+		if session.Mode != models.SessionModePot {
+			return usecase.NotPotSessionErr
+		}
+		pot, err := usecase.FormPotState(uc.repo, session.UUID)
+		if err != nil {
+			return err
+		}
+		if pot.Balance < info.Cost {
+			return usecase.PotInsufficientErr
+		}
 	}
 
 	// Check user is exists in db
-	userID, upsertErr := uc.upsertUser(info.UserID, info.Username)
+	senderID, upsertErr := uc.upsertUser(info.UserID, info.Username, info.FirstName, info.LastName)
 	if upsertErr != nil {
-		return fmt.Errorf("usecase: %v", err.Error())
+		return fmt.Errorf("usecase: %v", upsertErr.Error())
 	}
+	userID := senderID
 
-	// Check, that user is member of session
-	member, err := uc.repo.GetMemberBySession(session.UUID, userID)
-	if err != nil {
-		return fmt.Errorf("usecase: %v", err.Error())
+	// Expense paid by guest is recorded by another member
+	if info.GuestName != EmptyString {
+		guest, err := uc.repo.GetGuest(info.ChatID, info.GuestName)
+		if err != nil {
+			return fmt.Errorf("usecase: %v", err.Error())
+		}
+		if guest.ID == 0 {
+			return usecase.GuestNotExistsErr
+		}
+		userID = guest.ID
 	}
 
-	var memberID = member.ID
-
-	// If user not in session, add as member
-	if memberID == 0 {
-		memberID, err = uc.repo.AddMemberToSession(session.UUID, userID)
+	// Observer can't record expenses of guests either
+	if senderID != userID {
+		sender, err := uc.repo.GetMemberBySession(session.UUID, senderID)
 		if err != nil {
 			return fmt.Errorf("usecase: %v", err.Error())
 		}
+		if sender.Role == models.RoleObserver {
+			return usecase.ObserverErr
+		}
+	}
+
+	// If user not in session, add as member
+	memberID, err := uc.getOrAddParticipant(session.UUID, userID)
+	if err != nil {
+		return err
 	}
 
 	// Add user costs
-	return uc.repo.AddUserCosts(memberID, info.Cost, info.Product)
+	if err = uc.repo.AddUserCosts(memberID, info.Cost, info.Product, info.FromPot); err != nil {
+		return err
+	}
+
+	uc.notifyExpense(session, senderID, userID, info)
+	return nil
+}
+
+// EditExpense changes name and price of expense of active session, its payer or manager of session can do it.
+// Members, who have turned on notifications, are told about the change.
+func (uc *AppGroupUsecase) EditExpense(info dto.EditExpenseDTO) error {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return err
+	}
+
+	userID, err := uc.upsertUser(info.UserID, info.Username, info.FirstName, info.LastName)
+	if err != nil {
+		return err
+	}
+
+	expense, err := uc.repo.GetSessionCost(session.UUID, info.CostID)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	if expense.ID == 0 {
+		return usecase.CostNotExistsErr
+	}
+	if expense.User.ID != userID {
+		if err = uc.checkManager(session, userID, info.IsAdmin); err != nil {
+			return err
+		}
+	}
+
+	// Expense paid from pot may grow only within pot balance
+	if expense.FromPot && info.Cost > expense.Cost {
+		pot, err := usecase.FormPotState(uc.repo, session.UUID)
+		if err != nil {
+			return err
+		}
+		if pot.Balance < info.Cost-expense.Cost {
+			return usecase.PotInsufficientErr
+		}
+	}
+
+	if err = uc.repo.UpdateUserCosts(expense.ID, info.Cost, info.Product); err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	uc.notifyExpenseEdit(session, userID, expense, info)
+	return nil
+}
+
+func (uc *AppGroupUsecase) AddContributionToPot(info dto.AddContributionDTO) error {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return err
+	}
+
+	if session.Mode != models.SessionModePot {
+		return usecase.NotPotSessionErr
+	}
+
+	userID, err := uc.upsertUser(info.UserID, info.Username, info.FirstName, info.LastName)
+	if err != nil {
+		return err
+	}
+
+	memberID, err := uc.getOrAddParticipant(session.UUID, userID)
+	if err != nil {
+		return err
+	}
+
+	return uc.repo.AddPotContribution(memberID, info.Money)
+}
+
+func (uc *AppGroupUsecase) GetPot(info dto.GetPotDTO) (*models.PotState, error) {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.Mode != models.SessionModePot {
+		return nil, usecase.NotPotSessionErr
+	}
+
+	return usecase.FormPotState(uc.repo, session.UUID)
+}
+
+func (uc *AppGroupUsecase) AddTransferToSession(info dto.AddTransferDTO) error {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return err
+	}
+
+	// Recipient must have written to the bot at least once
+	recipient, err := uc.findUser(info.RecipientTgID, info.RecipientUsername)
+	if err != nil {
+		return err
+	}
+	if recipient.ID == 0 {
+		return usecase.UserNotExistsErr
+	}
+
+	senderID, err := uc.upsertUser(info.UserID, info.Username, info.FirstName, info.LastName)
+	if err != nil {
+		return err
+	}
+	if senderID == recipient.ID {
+		return usecase.SelfTransferErr
+	}
+
+	// Both sides of the transfer become members of session
+	senderMemberID, err := uc.getOrAddParticipant(session.UUID, senderID)
+	if err != nil {
+		return err
+	}
+	recipientMemberID, err := uc.getOrAddParticipant(session.UUID, recipient.ID)
+	if err != nil {
+		return err
+	}
+
+	return uc.repo.AddTransfer(senderMemberID, recipientMemberID, info.Money)
+}
+
+// getOrAddParticipant returns member, who can record expenses and transfers, adding user to session if needed.
+func (uc *AppGroupUsecase) getOrAddParticipant(sessionUUID uuid.UUID, userID uint64) (uint64, error) {
+	member, err := uc.repo.GetMemberBySession(sessionUUID, userID)
+	if err != nil {
+		return 0, fmt.Errorf("usecase: %v", err.Error())
+	}
+	if member.Role == models.RoleObserver {
+		return 0, usecase.ObserverErr
+	}
+	if member.ID != 0 {
+		return member.ID, nil
+	}
+
+	memberID, err := uc.repo.AddMemberToSession(sessionUUID, userID, models.RoleMember)
+	if err != nil {
+		return 0, fmt.Errorf("usecase: %v", err.Error())
+	}
+	return memberID, nil
 }
 
-func (uc *AppGroupUsecase) GetAllExpenses(info dto.GetCostsDTO) (map[string]models.AllUserCosts, error) {
-	// Get session by chat id
-	session, err := uc.repo.GetActiveSessionByChatID(info.ChatID)
+func (uc *AppGroupUsecase) GetAllTransfers(info dto.GetTransfersDTO) ([]*models.UserTransfer, error) {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return nil, err
+	}
+
+	transfers, err := uc.repo.GetUsersTransfers(session.UUID)
 	if err != nil {
 		return nil, fmt.Errorf("usecase: %v", err.Error())
 	}
+	return transfers, nil
+}
 
-	// If session not exist -- return error
-	if session.State != ActiveSession {
-		return nil, usecase.SessionNotExistsErr
+func (uc *AppGroupUsecase) GetAllExpenses(info dto.GetCostsDTO) (map[uint64]models.AllUserCosts, error) {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return nil, err
 	}
 
+	return uc.getSessionCosts(session)
+}
+
+func (uc *AppGroupUsecase) getSessionCosts(session *models.Session) (map[uint64]models.AllUserCosts, error) {
 	costs, err := uc.repo.GetUsersCosts(session.UUID)
 	if err != nil {
 		return nil, fmt.Errorf("usecase: %v", err.Error())
 	}
 
-	var UsersCosts = map[string]models.AllUserCosts{}
+	var UsersCosts = map[uint64]models.AllUserCosts{}
 	for _, curCost := range costs {
-		username := curCost.Username
-		curRec := UsersCosts[username]
-		curRec.Sum += curCost.Cost
+		userID := curCost.User.ID
+		curRec := UsersCosts[userID]
+		curRec.User = curCost.User
+		// Costs paid from pot are not member's own spending
+		if !curCost.FromPot {
+			curRec.Sum += curCost.Cost
+		}
 
 		newUserCost := models.UserCost{
+			ID:          curCost.ID,
 			Money:       curCost.Cost,
 			Description: curCost.Description,
+			FromPot:     curCost.FromPot,
 		}
 
 		curRec.Costs = append(curRec.Costs, newUserCost)
-		UsersCosts[username] = curRec
+		UsersCosts[userID] = curRec
 	}
 	return UsersCosts, nil
 }
 
 func (uc *AppGroupUsecase) FinishSession(info dto.FinishSessionDTO) error {
-	// Get session by chat id
-	session, err := uc.repo.GetActiveSessionByChatID(info.ChatID)
+	session, err := uc.getFinishableSession(info)
 	if err != nil {
-		return fmt.Errorf("usecase: %v", err.Error())
+		return err
 	}
 
-	// If session not exist -- return error
-	if session.State != ActiveSession {
-		return usecase.SessionNotExistsErr
-	}
+	_, _, err = uc.finishSession(session)
+	return err
+}
 
-	return uc.repo.FinishSession(session.UUID)
+// CanFinish checks, that user may finish session, so its settlement isn't calculated for anyone else.
+func (uc *AppGroupUsecase) CanFinish(info dto.FinishSessionDTO) error {
+	_, err := uc.getFinishableSession(info)
+	return err
 }
 
-type DebtsMtr map[uint64]map[uint64]int
+// getFinishableSession returns active session, if user is its creator or treasurer.
+func (uc *AppGroupUsecase) getFinishableSession(info dto.FinishSessionDTO) (*models.Session, error) {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return nil, err
+	}
 
-func (uc *AppGroupUsecase) formDebtMtr(sessionUUID uuid.UUID) (DebtsMtr, error) {
-	allUsers, err := uc.repo.GetAllUsers(sessionUUID)
+	user, err := uc.repo.GetUser(info.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("usecase: %v", err.Error())
 	}
+	if err = uc.checkManager(session, user.ID, info.IsAdmin); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
 
-	var debtsMtr = make(DebtsMtr)
-	for _, curUser := range allUsers {
-		curDebtor := make(map[uint64]int)
-		for _, tmpUser := range allUsers {
-			curDebtor[tmpUser.ID] = 0
+// finishSession saves final debts or pot settlement, closes session and notifies members.
+// Caller must check, that session may be finished.
+func (uc *AppGroupUsecase) finishSession(session *models.Session) ([]*models.Debt, *models.PotState, error) {
+	// Final debts are saved to be paid after session is closed
+	var debts []*models.Debt
+	if session.Mode != models.SessionModePot {
+		debtsMtr, err := usecase.FormDebtMtr(uc.repo, session.UUID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for creditorID, curDebtors := range debtsMtr {
+			for debtorID, money := range curDebtors {
+				if money != 0 {
+					debts = append(debts, &models.Debt{
+						Creditor: &models.User{ID: creditorID},
+						Debtor:   &models.User{ID: debtorID},
+						Money:    money,
+						Status:   models.DebtPending,
+					})
+				}
+			}
 		}
-		debtsMtr[curUser.ID] = curDebtor
 	}
 
-	allCosts, err := uc.repo.GetAllCosts(sessionUUID)
+	// Pot settlement is calculated before session is closed
+	var pot *models.PotState
+	if session.Mode == models.SessionModePot {
+		var err error
+		if pot, err = usecase.FormPotState(uc.repo, session.UUID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	err := uc.repo.FinishSession(session.UUID, debts)
+	if err == repo.SessionFinishedErr {
+		return nil, nil, usecase.SessionFinishedErr
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	uc.notifyFinish(session, debts, pot)
+	uc.scheduleReminders(session, debts)
+	return debts, pot, nil
+}
+
+// ReopenSession makes the most recently finished session of chat active again, so forgotten
+// expenses can be added. Debts saved at finish are dropped and calculated again at next finish.
+func (uc *AppGroupUsecase) ReopenSession(info dto.ReopenSessionDTO) (*models.Session, error) {
+	session, finishedAt, err := uc.repo.GetLastClosedSession(info.ChatID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	if session.UUID == uuid.Nil {
+		return nil, usecase.ClosedSessionNotExistsErr
+	}
+	if time.Since(finishedAt) > uc.reopenGrace {
+		return nil, usecase.ReopenExpiredErr
+	}
+
+	userID, err := uc.upsertUser(info.UserID, info.Username, info.FirstName, info.LastName)
+	if err != nil {
+		return nil, err
+	}
+	if err = uc.checkManager(session, userID, info.IsAdmin); err != nil {
+		return nil, err
+	}
+
+	// Names of active sessions of chat must differ
+	curSession, err := uc.repo.GetActiveSessionByName(info.ChatID, session.SessionName)
 	if err != nil {
 		return nil, fmt.Errorf("usecase: %v", err.Error())
 	}
+	if curSession.State == models.SessionActive {
+		return nil, usecase.SessionExistsErr
+	}
 
-	for _, curCost := range allCosts {
-		userID := curCost.UserID
-		debt := curCost.Money / len(allUsers)
-		curDebtors := debtsMtr[userID]
-		for curDebtor := range curDebtors {
-			if curDebtor != userID {
-				debtsMtr[userID][curDebtor] += debt
-			}
+	reopened, err := uc.repo.ReopenSession(session.UUID)
+	if err == repo.SessionExistsErr {
+		return nil, usecase.SessionExistsErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	if !reopened {
+		return nil, usecase.SessionSettledErr
+	}
+
+	session.State = models.SessionActive
+	session.FinishedAt = EmptyString
+	return session, nil
+}
+
+// GetHistory returns finished sessions of chat from newest to oldest.
+func (uc *AppGroupUsecase) GetHistory(info dto.GetHistoryDTO) ([]*models.ArchivedSession, error) {
+	history, err := uc.repo.GetClosedSessions(info.ChatID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	return history, nil
+}
+
+// ShowSession returns final costs and debts of finished session chosen by name or by number in history.
+// If several finished sessions have the same name, the newest one is chosen.
+func (uc *AppGroupUsecase) ShowSession(info dto.ShowSessionDTO) (*models.SessionArchive, error) {
+	history, err := uc.GetHistory(dto.GetHistoryDTO{ChatID: info.ChatID})
+	if err != nil {
+		return nil, err
+	}
+
+	var session *models.Session
+	for i, archived := range history {
+		if info.SessionName == EmptyString && i+1 == info.Number ||
+			info.SessionName != EmptyString && strings.EqualFold(archived.Session.SessionName, info.SessionName) {
+			session = archived.Session
+			break
 		}
 	}
+	if session == nil {
+		return nil, usecase.ClosedSessionNotExistsErr
+	}
 
-	for curUser, curDebtors := range debtsMtr {
-		for curDebtor := range curDebtors {
-			if debtsMtr[curUser][curDebtor] != 0 && debtsMtr[curDebtor][curUser] != 0 {
-				if debtsMtr[curUser][curDebtor] > debtsMtr[curDebtor][curUser] {
-					debtsMtr[curUser][curDebtor] -= debtsMtr[curDebtor][curUser]
-					debtsMtr[curDebtor][curUser] = 0
-				} else {
-					debtsMtr[curDebtor][curUser] -= debtsMtr[curUser][curDebtor]
-					debtsMtr[curUser][curDebtor] = 0
-				}
-			}
+	archive := &models.SessionArchive{Session: session}
+	if archive.Costs, err = uc.getSessionCosts(session); err != nil {
+		return nil, err
+	}
+
+	if session.Mode == models.SessionModePot {
+		archive.Pot, err = usecase.FormPotState(uc.repo, session.UUID)
+		if err != nil {
+			return nil, err
 		}
+		return archive, nil
+	}
+
+	if archive.Debts, err = uc.repo.GetSessionDebts(session.UUID); err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	return archive, nil
+}
+
+// scheduleReminders plans reminders about debts of finished session, failures are only logged.
+func (uc *AppGroupUsecase) scheduleReminders(session *models.Session, debts []*models.Debt) {
+	if len(debts) == 0 {
+		return
+	}
+
+	settings, err := uc.repo.GetChatSettings(session.ChatID)
+	if err != nil {
+		uc.log.Warnf("Schedule reminders err: %v", err)
+		return
+	}
+	if !settings.RemindEnabled {
+		return
+	}
+
+	nextAt := time.Now().Add(time.Duration(settings.RemindIntervalDays) * 24 * time.Hour)
+	if err = uc.repo.CreateReminders(session.UUID, session.ChatID, nextAt); err != nil {
+		uc.log.Warnf("Schedule reminders err: %v", err)
 	}
-	return debtsMtr, nil
 }
 
-func (uc *AppGroupUsecase) GetAllDebts(info dto.GetDebtsDTO) (map[string]models.AllUserDebts, error) {
-	session, err := uc.repo.GetActiveSessionByChatID(info.ChatID)
+func (uc *AppGroupUsecase) GetChatSettings(info dto.GetChatSettingsDTO) (*models.ChatSettings, error) {
+	settings, err := uc.repo.GetChatSettings(info.ChatID)
 	if err != nil {
 		return nil, fmt.Errorf("usecase: %v", err.Error())
 	}
+	return settings, nil
+}
 
-	if session.State != ActiveSession {
-		return nil, usecase.SessionNotExistsErr
+// SaveChatSettings changes settings of whole chat, only chat administrators and managers
+// of active sessions can do it.
+func (uc *AppGroupUsecase) SaveChatSettings(info dto.SaveChatSettingsDTO) error {
+	if err := uc.checkChatManager(info.Settings.ChatID, info.UserID, info.IsAdmin); err != nil {
+		return err
+	}
+	if info.Settings.RemindIntervalDays <= 0 || info.Settings.RemindMax <= 0 ||
+		info.Settings.AutoFinishDays < 0 || info.Settings.AutoFinishGraceDays <= 0 {
+		return usecase.InvalidSettingsErr
+	}
+
+	if err := uc.repo.SaveChatSettings(info.Settings); err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
 	}
+	return nil
+}
 
-	debtsMtr, err := uc.formDebtMtr(session.UUID)
+// checkManager allows action to creator and treasurers of session and, if settings of chat
+// allow it, to chat administrators.
+func (uc *AppGroupUsecase) checkManager(session *models.Session, userID uint64, isAdmin bool) error {
+	member, err := uc.repo.GetMemberBySession(session.UUID, userID)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	if member.CanManage() {
+		return nil
 	}
 
-	UserDebts := map[string]models.AllUserDebts{}
-	for curUser, curDebtors := range debtsMtr {
-		for curDebtor := range curDebtors {
-			if debtsMtr[curUser][curDebtor] != 0 {
-				creditor, _ := uc.repo.GetUserById(curUser)
-				debtor, _ := uc.repo.GetUserById(curDebtor)
-				debt := debtsMtr[curUser][curDebtor]
+	if isAdmin {
+		settings, err := uc.repo.GetChatSettings(session.ChatID)
+		if err != nil {
+			return fmt.Errorf("usecase: %v", err.Error())
+		}
+		if settings.AdminsAreTreasurers {
+			return nil
+		}
+	}
+	return usecase.NoPermissionErr
+}
 
-				curUserDebts := UserDebts[creditor.Username]
-				newUserDebt := models.UserDebt{
-					DebtorName: debtor.Username,
-					Money:      debt,
-				}
-				curUserDebts.Debts = append(curUserDebts.Debts, newUserDebt)
-				UserDebts[creditor.Username] = curUserDebts
-			}
+// checkChatManager allows changing settings of whole chat to chat administrators and to creators
+// and treasurers of any active session of chat.
+func (uc *AppGroupUsecase) checkChatManager(chatID int64, tgUserID int64, isAdmin bool) error {
+	if isAdmin {
+		return nil
+	}
+
+	user, err := uc.repo.GetUser(tgUserID)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	if user.ID == 0 {
+		return usecase.NoPermissionErr
+	}
+
+	sessions, err := uc.repo.GetActiveSessions(chatID)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	for _, session := range sessions {
+		if err = uc.checkManager(session, user.ID, false); err != usecase.NoPermissionErr {
+			return err
 		}
 	}
+	return usecase.NoPermissionErr
+}
+
+// SetRole changes role of session member, only creator can do it.
+func (uc *AppGroupUsecase) SetRole(info dto.SetRoleDTO) error {
+	if info.Role != models.RoleTreasurer && info.Role != models.RoleMember && info.Role != models.RoleObserver {
+		return usecase.InvalidRoleErr
+	}
 
-	return UserDebts, nil
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return err
+	}
+
+	userID, err := uc.upsertUser(info.UserID, info.Username, info.FirstName, info.LastName)
+	if err != nil {
+		return err
+	}
+	if session.CreatorID != userID {
+		return usecase.NotCreatorErr
+	}
+
+	user, err := uc.findUser(info.MemberTgID, info.MemberUsername)
+	if err != nil {
+		return err
+	}
+	member, err := uc.repo.GetMemberBySession(session.UUID, user.ID)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	switch {
+	case user.ID == 0 || member.ID == 0:
+		return usecase.NotMemberErr
+	case member.Role == models.RoleCreator:
+		return usecase.CreatorRoleErr
+	}
+
+	// Observer doesn't share expenses, so his records would break settlement
+	if info.Role == models.RoleObserver {
+		records, err := uc.repo.CountMemberRecords(member.ID)
+		if err != nil {
+			return fmt.Errorf("usecase: %v", err.Error())
+		}
+		if records != 0 {
+			return usecase.MemberHasRecordsErr
+		}
+	}
+
+	if err = uc.repo.SetMemberRole(member.ID, info.Role); err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	return nil
+}
+
+// SetAdminsTreasurers gives or takes away rights of treasurer from chat administrators.
+func (uc *AppGroupUsecase) SetAdminsTreasurers(info dto.SetAdminsTreasurersDTO) error {
+	if err := uc.checkChatManager(info.ChatID, info.UserID, info.IsAdmin); err != nil {
+		return err
+	}
+
+	settings, err := uc.repo.GetChatSettings(info.ChatID)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	settings.AdminsAreTreasurers = info.Enabled
+	if err = uc.repo.SaveChatSettings(settings); err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	return nil
+}
+
+// GetAllDebts returns current debts of session, session is returned to refer it in buttons.
+func (uc *AppGroupUsecase) GetAllDebts(info dto.GetDebtsDTO) (*models.Session, map[uint64]models.AllUserDebts,
+	error) {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	debts, err := uc.getSessionDebts(session)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, debts, nil
+}
+
+func (uc *AppGroupUsecase) getSessionDebts(session *models.Session) (map[uint64]models.AllUserDebts, error) {
+	// In pot mode members settle with the pot, not with each other
+	if session.Mode == models.SessionModePot {
+		return nil, usecase.PotSessionErr
+	}
+
+	debtsMtr, err := usecase.FormDebtMtr(uc.repo, session.UUID)
+	if err != nil {
+		return nil, err
+	}
+
+	allUsers, err := uc.repo.GetAllUsers(session.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	users := make(map[uint64]*models.User, len(allUsers))
+	for _, curUser := range allUsers {
+		users[curUser.ID] = curUser
+	}
+
+	UserDebts := map[uint64]models.AllUserDebts{}
+	for curUser, curDebtors := range debtsMtr {
+		for curDebtor := range curDebtors {
+			if debtsMtr[curUser][curDebtor] != 0 {
+				debt := debtsMtr[curUser][curDebtor]
+
+				curUserDebts := UserDebts[curUser]
+				curUserDebts.Creditor = users[curUser]
+				newUserDebt := models.UserDebt{
+					Debtor: users[curDebtor],
+					Money:  debt,
+				}
+				curUserDebts.Debts = append(curUserDebts.Debts, newUserDebt)
+				UserDebts[curUser] = curUserDebts
+			}
+		}
+	}
+
+	for creditorID, curUserDebts := range UserDebts {
+		requisite, err := uc.repo.GetDefaultRequisite(creditorID)
+		if err != nil {
+			return nil, fmt.Errorf("usecase: %v", err.Error())
+		}
+		if requisite.ID == 0 {
+			continue
+		}
+		// Full value goes only to debtor's payment, group chat shows it masked.
+		// Requisite, which can't be decrypted, is skipped, so debts of chat are still shown
+		if err = uc.repo.RevealRequisite(requisite); err != nil {
+			uc.log.Warnf("Reveal requisite %d of user %d err: %v", requisite.ID, creditorID, err)
+			continue
+		}
+		curUserDebts.Requisite = requisite
+		UserDebts[creditorID] = curUserDebts
+	}
+
+	return UserDebts, nil
+}
+
+// GetPayment returns current debt of user to creditor with creditor's requisite.
+// Debt is recalculated, because it could change after debts were shown.
+func (uc *AppGroupUsecase) GetPayment(info dto.GetPaymentDTO) (*models.Payment, error) {
+	session, err := uc.getSession(info.ChatID, info.SessionUUID, info.UserID, EmptyString)
+	if err != nil {
+		return nil, err
+	}
+
+	allDebts, err := uc.getSessionDebts(session)
+	if err != nil {
+		return nil, err
+	}
+
+	creditorDebts, ok := allDebts[info.CreditorID]
+	if !ok {
+		return nil, usecase.DebtNotExistsErr
+	}
+	for _, debt := range creditorDebts.Debts {
+		if debt.Debtor.ID != info.DebtorID {
+			continue
+		}
+		if debt.Debtor.TgID != info.UserID {
+			return nil, usecase.NotDebtorErr
+		}
+		if creditorDebts.Requisite == nil {
+			return nil, usecase.NoRequisiteErr
+		}
+		return &models.Payment{
+			Creditor:  creditorDebts.Creditor,
+			Requisite: creditorDebts.Requisite,
+			Money:     debt.Money,
+			Purpose:   fmt.Sprintf("Долг за %s", session.SessionName),
+		}, nil
+	}
+	return nil, usecase.DebtNotExistsErr
+}
+
+// getActiveSession returns active session of chat chosen by name. Without name it is the only
+// active session of chat or, if there are several, session chosen by /switch.
+func (uc *AppGroupUsecase) getActiveSession(chatID int64, userID int64, sessionName string) (*models.Session, error) {
+	if sessionName != EmptyString {
+		session, err := uc.repo.GetActiveSessionByName(chatID, sessionName)
+		if err != nil {
+			return nil, fmt.Errorf("usecase: %v", err.Error())
+		}
+		if session.State != models.SessionActive {
+			return nil, usecase.SessionNotExistsErr
+		}
+		return session, nil
+	}
+
+	sessions, err := uc.repo.GetActiveSessions(chatID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	switch len(sessions) {
+	case 0:
+		return nil, usecase.SessionNotExistsErr
+	case 1:
+		return sessions[0], nil
+	}
+
+	defaultUUID, err := uc.repo.GetDefaultSession(chatID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	for _, session := range sessions {
+		if session.UUID == defaultUUID {
+			return session, nil
+		}
+	}
+	return nil, usecase.SessionNotChosenErr
+}
+
+// getSession returns session pointed by uuid if it is set (e.g. from inline
+// button), otherwise active session chosen by name or default one.
+func (uc *AppGroupUsecase) getSession(chatID int64, sessionUUID uuid.UUID, userID int64,
+	sessionName string) (*models.Session, error) {
+	if sessionUUID == uuid.Nil {
+		return uc.getActiveSession(chatID, userID, sessionName)
+	}
+
+	session, err := uc.repo.GetSessionByUUID(sessionUUID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	if session.State != models.SessionActive || session.ChatID != chatID {
+		return nil, usecase.SessionNotExistsErr
+	}
+	return session, nil
+}
+
+// GetChatSessions returns active sessions of chat and session, which commands of user go to.
+// Current session is nil, when there are several sessions and none of them is chosen.
+func (uc *AppGroupUsecase) GetChatSessions(info dto.GetChatSessionsDTO) ([]*models.Session, *models.Session,
+	error) {
+	sessions, err := uc.repo.GetActiveSessions(info.ChatID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	current, err := uc.getActiveSession(info.ChatID, info.UserID, EmptyString)
+	switch err {
+	case nil:
+	case usecase.SessionNotExistsErr, usecase.SessionNotChosenErr:
+		current = nil
+	default:
+		return nil, nil, err
+	}
+	return sessions, current, nil
+}
+
+// SwitchSession chooses session for commands without explicit session, for whole chat or only for user.
+func (uc *AppGroupUsecase) SwitchSession(info dto.SwitchSessionDTO) (*models.Session, error) {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Session for whole chat is chosen like other chat settings
+	var tgID int64
+	if info.Personal {
+		tgID = info.UserID
+	} else if err = uc.checkChatManager(info.ChatID, info.UserID, info.IsAdmin); err != nil {
+		return nil, err
+	}
+	if err = uc.repo.SetDefaultSession(info.ChatID, tgID, session.UUID); err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	return session, nil
+}
+
+func (uc *AppGroupUsecase) JoinSession(info dto.JoinSessionDTO) error {
+	session, err := uc.getSession(info.ChatID, info.SessionUUID, info.UserID, info.SessionName)
+	if err != nil {
+		return err
+	}
+
+	userID, err := uc.upsertUser(info.UserID, info.Username, info.FirstName, info.LastName)
+	if err != nil {
+		return err
+	}
+
+	return uc.addNewMember(session.UUID, userID)
+}
+
+func (uc *AppGroupUsecase) AddMember(info dto.ManageMemberDTO) error {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return err
+	}
+
+	userID, err := uc.upsertUser(info.UserID, info.Username, info.FirstName, info.LastName)
+	if err != nil {
+		return err
+	}
+	if err = uc.checkManager(session, userID, info.IsAdmin); err != nil {
+		return err
+	}
+
+	newMember, err := uc.findUser(info.MemberTgID, info.MemberUsername)
+	if err != nil {
+		return err
+	}
+	if newMember.ID == 0 {
+		return usecase.UserNotExistsErr
+	}
+
+	return uc.addNewMember(session.UUID, newMember.ID)
+}
+
+// GetInviteToken returns token of invite link to active session, token is created on first request.
+func (uc *AppGroupUsecase) GetInviteToken(info dto.GetInviteDTO) (string, error) {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return "", err
+	}
+
+	tokenBytes := make([]byte, inviteTokenBytes)
+	if _, err = rand.Read(tokenBytes); err != nil {
+		return "", fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	token, err := uc.repo.SetInviteToken(session.UUID, base64.RawURLEncoding.EncodeToString(tokenBytes))
+	if err != nil {
+		return "", fmt.Errorf("usecase: %v", err.Error())
+	}
+	return token, nil
+}
+
+// JoinByInvite adds user, who opened invite link, to session.
+func (uc *AppGroupUsecase) JoinByInvite(info dto.JoinByInviteDTO) (*models.Session, error) {
+	session, err := uc.repo.GetSessionByInviteToken(info.Token)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	if session.State != models.SessionActive {
+		return nil, usecase.SessionNotExistsErr
+	}
+
+	userID, err := uc.upsertUser(info.UserID, info.Username, info.FirstName, info.LastName)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, uc.addNewMember(session.UUID, userID)
+}
+
+func (uc *AppGroupUsecase) addNewMember(sessionUUID uuid.UUID, userID uint64) error {
+	member, err := uc.repo.GetMemberBySession(sessionUUID, userID)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	if member.ID != 0 {
+		return usecase.AlreadyMemberErr
+	}
+
+	_, err = uc.repo.AddMemberToSession(sessionUUID, userID, models.RoleMember)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	return nil
+}
+
+func (uc *AppGroupUsecase) LeaveSession(info dto.LeaveSessionDTO) error {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return err
+	}
+
+	userID, err := uc.upsertUser(info.UserID, info.Username, info.FirstName, info.LastName)
+	if err != nil {
+		return err
+	}
+	if session.CreatorID == userID {
+		return usecase.CreatorLeaveErr
+	}
+
+	return uc.removeMember(session.UUID, userID)
+}
+
+func (uc *AppGroupUsecase) KickMember(info dto.ManageMemberDTO) error {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return err
+	}
+
+	userID, err := uc.upsertUser(info.UserID, info.Username, info.FirstName, info.LastName)
+	if err != nil {
+		return err
+	}
+	if err = uc.checkManager(session, userID, info.IsAdmin); err != nil {
+		return err
+	}
+
+	var kicked *models.User
+	if info.MemberIsGuest {
+		kicked, err = uc.repo.GetGuest(info.ChatID, info.MemberUsername)
+	} else {
+		kicked, err = uc.findUser(info.MemberTgID, info.MemberUsername)
+	}
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err)
+	}
+	if kicked.ID == 0 {
+		return usecase.NotMemberErr
+	}
+	if kicked.ID == session.CreatorID {
+		return usecase.CreatorLeaveErr
+	}
+
+	return uc.removeMember(session.UUID, kicked.ID)
+}
+
+// removeMember deletes member only if nothing is recorded for him in session:
+// his expenses and transfers are part of other members' debts, so they must be
+// settled within session instead of disappearing silently.
+func (uc *AppGroupUsecase) removeMember(sessionUUID uuid.UUID, userID uint64) error {
+	member, err := uc.repo.GetMemberBySession(sessionUUID, userID)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	if member.ID == 0 {
+		return usecase.NotMemberErr
+	}
+
+	records, err := uc.repo.CountMemberRecords(member.ID)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	if records != 0 {
+		return usecase.MemberHasRecordsErr
+	}
+
+	err = uc.repo.RemoveMember(member.ID)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	return nil
+}
+
+func (uc *AppGroupUsecase) GetMembers(info dto.GetMembersDTO) (*models.Session, []*models.SessionMember, error) {
+	session, err := uc.getSession(info.ChatID, info.SessionUUID, info.UserID, info.SessionName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	members, err := uc.repo.GetSessionMembers(session.UUID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	return session, members, nil
+}
+
+func (uc *AppGroupUsecase) AddGuest(info dto.AddGuestDTO) error {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return err
+	}
+
+	userID, err := uc.upsertUser(info.UserID, info.Username, info.FirstName, info.LastName)
+	if err != nil {
+		return err
+	}
+
+	// Guests are shared between sessions of chat
+	guest, err := uc.repo.GetGuest(info.ChatID, info.GuestName)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	if guest.ID == 0 {
+		guest.ID, err = uc.repo.CreateGuest(info.ChatID, info.GuestName, userID)
+		if err != nil {
+			return fmt.Errorf("usecase: %v", err.Error())
+		}
+	}
+
+	return uc.addNewMember(session.UUID, guest.ID)
+}
+
+// MergeGuest turns guest into member (sender by default): all guest's costs and debts in every session
+// of chat become member's. Only user who added guest or manager of active session can do it.
+func (uc *AppGroupUsecase) MergeGuest(info dto.MergeGuestDTO) error {
+	session, err := uc.getActiveSession(info.ChatID, info.UserID, info.SessionName)
+	if err != nil {
+		return err
+	}
+
+	guest, err := uc.repo.GetGuest(info.ChatID, info.GuestName)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	if guest.ID == 0 {
+		return usecase.GuestNotExistsErr
+	}
+
+	userID, err := uc.upsertUser(info.UserID, info.Username, info.FirstName, info.LastName)
+	if err != nil {
+		return err
+	}
+	if guest.GuestCreatorID != userID {
+		if err = uc.checkManager(session, userID, info.IsAdmin); err != nil {
+			return err
+		}
+	}
+
+	if info.MemberTgID != 0 || info.MemberUsername != EmptyString {
+		member, err := uc.findUser(info.MemberTgID, info.MemberUsername)
+		if err != nil {
+			return err
+		}
+		if member.ID == 0 {
+			return usecase.UserNotExistsErr
+		}
+		userID = member.ID
+	}
+
+	err = uc.repo.MergeUsers(guest.ID, userID)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	return nil
 }
@@ -0,0 +1,49 @@
+package group_usecase
+
+import (
+	"collector-telegram-bot/internal/usecase"
+	"fmt"
+	"html"
+	"time"
+)
+
+// CloseInactiveSessions warns chats about sessions without expenses and finishes sessions,
+// which stayed inactive for grace period after warning. Failures of single session are only
+// logged, so other sessions are still processed.
+func (uc *AppGroupUsecase) CloseInactiveSessions(now time.Time) error {
+	sessions, err := uc.repo.GetInactiveSessions(now)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	for _, inactive := range sessions {
+		session := inactive.Session
+
+		if inactive.WarnedAt.IsZero() {
+			if err = uc.repo.SetInactivityWarned(session.UUID, now); err != nil {
+				uc.log.Warnf("Warn inactive session err: %v", err)
+				continue
+			}
+			uc.notifier.Notify(session.ChatID, fmt.Sprintf("Сессия <b>%s</b>: новых трат не было %d дн. "+
+				"Если за %d дн. ничего не добавится, сессия завершится автоматически.",
+				html.EscapeString(session.SessionName), inactive.InactiveDays, inactive.GraceDays))
+			continue
+		}
+
+		if now.Before(inactive.WarnedAt.AddDate(0, 0, inactive.GraceDays)) {
+			continue
+		}
+
+		// Session may be finished manually after it was selected
+		debts, pot, err := uc.finishSession(session)
+		if err == usecase.SessionFinishedErr {
+			continue
+		}
+		if err != nil {
+			uc.log.Warnf("Finish inactive session err: %v", err)
+			continue
+		}
+		uc.notifyAutoFinish(session, debts, pot)
+	}
+	return nil
+}
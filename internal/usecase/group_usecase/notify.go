@@ -0,0 +1,172 @@
+package group_usecase
+
+import (
+	"collector-telegram-bot/internal/dto"
+	"collector-telegram-bot/internal/models"
+	"collector-telegram-bot/internal/usecase"
+	"fmt"
+	"html"
+)
+
+// notifyExpense tells members, who have turned on notifications, about their share of new expense.
+// Sender of expense and observers, who don't share it, aren't notified, failures are only logged.
+func (uc *AppGroupUsecase) notifyExpense(session *models.Session, senderID uint64, payerID uint64,
+	info dto.AddExpenseDTO) {
+	members, err := usecase.Participants(uc.repo, session.UUID)
+	if err != nil {
+		uc.log.Warnf("Notify expense err: %v", err)
+		return
+	}
+
+	var payer *models.User
+	for _, member := range members {
+		if member.ID == payerID {
+			payer = member
+		}
+	}
+	if payer == nil {
+		return
+	}
+
+	text := fmt.Sprintf("Сессия <b>%s</b>: %s добавил трату «%s» на %d рублей",
+		html.EscapeString(session.SessionName), payer.Mention(), html.EscapeString(info.Product), info.Cost)
+	if info.FromPot {
+		text += " из котла"
+	}
+	text += fmt.Sprintf(".\nТвоя доля: %d рублей", info.Cost/len(members))
+
+	for _, member := range members {
+		if member.Notify && !member.IsGuest() && member.ID != senderID {
+			uc.notifier.Notify(member.TgID, text)
+		}
+	}
+}
+
+// notifyExpenseEdit tells members, who have turned on notifications, about changed expense
+// and their new share. Member, who changed it, and observers aren't notified.
+func (uc *AppGroupUsecase) notifyExpenseEdit(session *models.Session, senderID uint64, old *models.Expanse,
+	info dto.EditExpenseDTO) {
+	members, err := usecase.Participants(uc.repo, session.UUID)
+	if err != nil {
+		uc.log.Warnf("Notify expense edit err: %v", err)
+		return
+	}
+	if len(members) == 0 {
+		return
+	}
+
+	text := fmt.Sprintf("Сессия <b>%s</b>: трата %s «%s» на %d рублей изменена, теперь это «%s» на %d рублей.\n"+
+		"Твоя доля: %d рублей", html.EscapeString(session.SessionName), old.User.Mention(),
+		html.EscapeString(old.Description), old.Cost, html.EscapeString(info.Product), info.Cost,
+		info.Cost/len(members))
+
+	for _, member := range members {
+		if member.Notify && !member.IsGuest() && member.ID != senderID {
+			uc.notifier.Notify(member.TgID, text)
+		}
+	}
+}
+
+// notifyFinish sends every member, who has turned on notifications, his own result of session.
+func (uc *AppGroupUsecase) notifyFinish(session *models.Session, debts []*models.Debt, pot *models.PotState) {
+	members, err := uc.repo.GetAllUsers(session.UUID)
+	if err != nil {
+		uc.log.Warnf("Notify finish err: %v", err)
+		return
+	}
+
+	users := make(map[uint64]*models.User, len(members))
+	for _, member := range members {
+		users[member.ID] = member
+	}
+
+	for _, member := range members {
+		if !member.Notify || member.IsGuest() {
+			continue
+		}
+
+		text := fmt.Sprintf("Сессия <b>%s</b> завершена!\n", html.EscapeString(session.SessionName))
+		if pot != nil {
+			text += createPotResult(pot.Members[member.ID])
+		} else {
+			text += createDebtsResult(member.ID, debts, users)
+		}
+		uc.notifier.Notify(member.TgID, text)
+	}
+}
+
+func createPotResult(balance models.PotMemberBalance) string {
+	switch {
+	case balance.Balance > 0:
+		return fmt.Sprintf("Котел возвращает тебе %d рублей", balance.Balance)
+	case balance.Balance < 0:
+		return fmt.Sprintf("Тебе нужно доплатить в котел %d рублей", -balance.Balance)
+	default:
+		return "Ты в расчете с котлом"
+	}
+}
+
+func createDebtsResult(userID uint64, debts []*models.Debt, users map[uint64]*models.User) string {
+	var text string
+	for _, debt := range debts {
+		switch userID {
+		case debt.Debtor.ID:
+			text += fmt.Sprintf("Ты должен %s %d рублей\n", users[debt.Creditor.ID].Mention(), debt.Money)
+		case debt.Creditor.ID:
+			text += fmt.Sprintf("%s должен тебе %d рублей\n", users[debt.Debtor.ID].Mention(), debt.Money)
+		}
+	}
+	if text == "" {
+		return "Долгов нет"
+	}
+	return text + "Отметить оплату долга: /paid в личном чате с ботом"
+}
+
+// notifyAutoFinish posts to group chat result of session, which was finished for inactivity.
+func (uc *AppGroupUsecase) notifyAutoFinish(session *models.Session, debts []*models.Debt, pot *models.PotState) {
+	members, err := uc.repo.GetAllUsers(session.UUID)
+	if err != nil {
+		uc.log.Warnf("Notify auto finish err: %v", err)
+		return
+	}
+
+	users := make(map[uint64]*models.User, len(members))
+	for _, member := range members {
+		users[member.ID] = member
+	}
+
+	text := fmt.Sprintf("Сессия <b>%s</b> завершена автоматически: давно не было новых трат.\n",
+		html.EscapeString(session.SessionName))
+	if pot != nil {
+		text += createPotSummary(pot, users)
+	} else {
+		text += createDebtsSummary(debts, users)
+	}
+	uc.notifier.Notify(session.ChatID, text)
+}
+
+func createPotSummary(pot *models.PotState, users map[uint64]*models.User) string {
+	text := fmt.Sprintf("Внесено в котел: %d рублей, потрачено: %d рублей\n", pot.Contributed, pot.Spent)
+	for userID, balance := range pot.Members {
+		switch {
+		case balance.Balance > 0:
+			text += fmt.Sprintf("Котел возвращает %s %d рублей\n", users[userID].Mention(), balance.Balance)
+		case balance.Balance < 0:
+			text += fmt.Sprintf("%s доплачивает в котел %d рублей\n", users[userID].Mention(), -balance.Balance)
+		}
+	}
+	return text
+}
+
+func createDebtsSummary(debts []*models.Debt, users map[uint64]*models.User) string {
+	if len(debts) == 0 {
+		return "Долгов нет"
+	}
+
+	var text string
+	for _, debt := range debts {
+		text += fmt.Sprintf("%s должен %s %d рублей\n", users[debt.Debtor.ID].Mention(),
+			users[debt.Creditor.ID].Mention(), debt.Money)
+	}
+	return text + "Отметить оплату долга: /paid в личном чате с ботом"
+}
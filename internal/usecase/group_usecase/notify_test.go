@@ -0,0 +1,47 @@
+package group_usecase
+
+import (
+	"collector-telegram-bot/internal/dto"
+	"collector-telegram-bot/internal/models"
+	"strings"
+	"testing"
+)
+
+// fakeNotifier keeps notifications by chat.
+type fakeNotifier struct {
+	sent map[int64]string
+}
+
+func (n *fakeNotifier) Notify(chatID int64, text string) {
+	n.sent[chatID] = text
+}
+
+func TestNotifyExpenseSkipsObservers(t *testing.T) {
+	payer := &models.User{ID: 1, TgID: 11, Username: "payer", Notify: true}
+	participant := &models.User{ID: 2, TgID: 12, Username: "participant", Notify: true}
+	observer := &models.User{ID: 3, TgID: 13, Username: "observer", Notify: true}
+
+	r := newFakeRepo()
+	session := r.addSession(member(payer, models.RoleCreator), member(participant, models.RoleMember),
+		member(observer, models.RoleObserver))
+	notifier := &fakeNotifier{sent: make(map[int64]string)}
+	uc := &AppGroupUsecase{log: nopLogger{}, repo: r, notifier: notifier}
+
+	uc.notifyExpense(session, payer.ID, payer.ID, dto.AddExpenseDTO{Product: "Такси", Cost: 300})
+	if text, ok := notifier.sent[participant.TgID]; !ok || !strings.Contains(text, "Твоя доля: 150 рублей") {
+		t.Errorf("participant got %q, want share of 150", text)
+	}
+
+	uc.notifyExpenseEdit(session, payer.ID, &models.Expanse{User: payer, Description: "Такси", Cost: 300},
+		dto.EditExpenseDTO{Product: "Такси", Cost: 500})
+	if text := notifier.sent[participant.TgID]; !strings.Contains(text, "Твоя доля: 250 рублей") {
+		t.Errorf("participant got %q after edit, want share of 250", text)
+	}
+
+	if text, ok := notifier.sent[observer.TgID]; ok {
+		t.Errorf("observer got %q, want no notifications", text)
+	}
+	if text, ok := notifier.sent[payer.TgID]; ok {
+		t.Errorf("sender got %q, want no notifications", text)
+	}
+}
@@ -0,0 +1,86 @@
+package group_usecase
+
+import (
+	"collector-telegram-bot/internal/models"
+	"collector-telegram-bot/internal/usecase"
+	"testing"
+)
+
+var (
+	fourth   = &models.User{ID: 4, TgID: 14, Username: "fourth"}
+	outsider = &models.User{ID: 5, TgID: 15, Username: "outsider"}
+)
+
+// addRolesSession adds session, where first is creator, second is treasurer, third is member
+// and fourth is observer.
+func (r *fakeRepo) addRolesSession() *models.Session {
+	return r.addSession(member(first, models.RoleCreator), member(second, models.RoleTreasurer),
+		member(third, models.RoleMember), member(fourth, models.RoleObserver))
+}
+
+func TestCheckManager(t *testing.T) {
+	tests := []struct {
+		name                string
+		user                *models.User
+		isAdmin             bool
+		adminsAreTreasurers bool
+		want                error
+	}{
+		{name: "creator", user: first, want: nil},
+		{name: "treasurer", user: second, want: nil},
+		{name: "member", user: third, want: usecase.NoPermissionErr},
+		{name: "observer", user: fourth, want: usecase.NoPermissionErr},
+		{name: "outsider", user: outsider, want: usecase.NoPermissionErr},
+		{name: "admin, admins are treasurers", user: outsider, isAdmin: true, adminsAreTreasurers: true, want: nil},
+		{name: "admin, admins aren't treasurers", user: outsider, isAdmin: true, want: usecase.NoPermissionErr},
+		{name: "member admin, admins are treasurers", user: third, isAdmin: true, adminsAreTreasurers: true,
+			want: nil},
+		{name: "creator, admins are treasurers", user: first, adminsAreTreasurers: true, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newFakeRepo()
+			session := r.addRolesSession()
+			settings := models.NewChatSettings(session.ChatID)
+			settings.AdminsAreTreasurers = tt.adminsAreTreasurers
+			r.settings[session.ChatID] = settings
+			uc := New(nopLogger{}, r, nil, 0).(*AppGroupUsecase)
+
+			if err := uc.checkManager(session, tt.user.ID, tt.isAdmin); err != tt.want {
+				t.Errorf("checkManager() err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckChatManager(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    *models.User
+		isAdmin bool
+		settled bool
+		want    error
+	}{
+		{name: "admin", user: outsider, isAdmin: true, want: nil},
+		{name: "creator", user: first, want: nil},
+		{name: "treasurer", user: second, want: nil},
+		{name: "member", user: third, want: usecase.NoPermissionErr},
+		{name: "observer", user: fourth, want: usecase.NoPermissionErr},
+		{name: "unknown user", user: outsider, want: usecase.NoPermissionErr},
+		{name: "creator of finished session", user: first, settled: true, want: usecase.NoPermissionErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newFakeRepo()
+			session := r.addRolesSession()
+			if tt.settled {
+				r.finish(session, 0)
+			}
+			uc := New(nopLogger{}, r, nil, 0).(*AppGroupUsecase)
+
+			if err := uc.checkChatManager(session.ChatID, tt.user.TgID, tt.isAdmin); err != tt.want {
+				t.Errorf("checkChatManager() err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
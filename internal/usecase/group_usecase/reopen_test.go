@@ -0,0 +1,63 @@
+package group_usecase
+
+import (
+	"collector-telegram-bot/internal/dto"
+	"collector-telegram-bot/internal/models"
+	"collector-telegram-bot/internal/usecase"
+	"testing"
+	"time"
+)
+
+func TestReopenSession(t *testing.T) {
+	const grace = time.Hour
+	tests := []struct {
+		name     string
+		user     *models.User
+		ago      time.Duration
+		sameName bool
+		want     error
+	}{
+		{name: "creator within grace", user: first, ago: grace / 2},
+		{name: "grace expired", user: first, ago: 2 * grace, want: usecase.ReopenExpiredErr},
+		{name: "member", user: second, ago: grace / 2, want: usecase.NoPermissionErr},
+		{name: "active session with same name", user: first, ago: grace / 2, sameName: true,
+			want: usecase.SessionExistsErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newFakeRepo()
+			session := r.addSession(member(first, models.RoleCreator), member(second, models.RoleMember))
+			r.finish(session, tt.ago)
+			if tt.sameName {
+				r.addSession(member(second, models.RoleCreator))
+			}
+			uc := New(nopLogger{}, r, nil, grace)
+
+			reopened, err := uc.ReopenSession(dto.ReopenSessionDTO{ChatID: session.ChatID, UserID: tt.user.TgID})
+			if err != tt.want {
+				t.Fatalf("ReopenSession() err = %v, want %v", err, tt.want)
+			}
+			wantState := models.SessionSettling
+			if tt.want == nil {
+				wantState = models.SessionActive
+				if reopened.UUID != session.UUID {
+					t.Errorf("ReopenSession() reopened %v, want %v", reopened.UUID, session.UUID)
+				}
+			}
+			if session.State != wantState {
+				t.Errorf("session state = %s, want %s", session.State, wantState)
+			}
+		})
+	}
+
+	t.Run("chat without finished session", func(t *testing.T) {
+		r := newFakeRepo()
+		r.addSession(member(first, models.RoleCreator))
+		uc := New(nopLogger{}, r, nil, grace)
+
+		_, err := uc.ReopenSession(dto.ReopenSessionDTO{ChatID: 100, UserID: first.TgID})
+		if err != usecase.ClosedSessionNotExistsErr {
+			t.Errorf("ReopenSession() err = %v, want %v", err, usecase.ClosedSessionNotExistsErr)
+		}
+	})
+}
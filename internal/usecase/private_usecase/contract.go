@@ -1,7 +1,26 @@
 package private_usecase
 
-import tele "gopkg.in/telebot.v3"
+import (
+	"collector-telegram-bot/internal/dto"
+	"collector-telegram-bot/internal/models"
+)
 
 type PrivateUsecase interface {
-	Sessions(c tele.Context)
+	GetSessions(info dto.GetSessionsDTO) (*models.SessionsPage, error)
+	GetSessionDetails(info dto.GetSessionDetailsDTO) (*models.SessionDetails, error)
+	GetActiveSessions(info dto.GetSessionsDTO) ([]*models.Session, error)
+	ChooseSession(info dto.ChooseSessionDTO) (*models.Session, error)
+	SetNotify(info dto.SetNotifyDTO) error
+	SetRemind(info dto.SetRemindDTO) error
+	GetDebtsToPay(info dto.GetDebtsToPayDTO) ([]*models.Debt, error)
+	MarkDebtPaid(info dto.MarkDebtPaidDTO) (*models.Debt, error)
+	GetDashboard(info dto.GetDashboardDTO) (*models.Dashboard, error)
+	GetNettings(info dto.GetNettingsDTO) ([]*models.Netting, error)
+	ProposeNetting(info dto.ProposeNettingDTO) (*models.Netting, error)
+	ConfirmNetting(info dto.ManageNettingDTO) (*models.Netting, error)
+	DeclineNetting(info dto.ManageNettingDTO) (*models.Netting, error)
+	GetRequisites(info dto.GetRequisitesDTO) ([]*models.Requisite, error)
+	AddRequisite(info dto.AddRequisiteDTO) error
+	SetDefaultRequisite(info dto.ManageRequisiteDTO) error
+	DeleteRequisite(info dto.ManageRequisiteDTO) error
 }
@@ -0,0 +1,125 @@
+package private_usecase
+
+import (
+	"collector-telegram-bot/internal"
+	"collector-telegram-bot/internal/dto"
+	"collector-telegram-bot/internal/models"
+	"reflect"
+	"testing"
+)
+
+func (r *fakeRepo) GetUserById(userID uint64) (*models.User, error) {
+	for _, debt := range r.debts {
+		if debt.Creditor.ID == userID {
+			return debt.Creditor, nil
+		}
+	}
+	return models.NewUser(), nil
+}
+
+func (r *fakeRepo) GetDebt(debtID uint64) (*models.Debt, error) {
+	for _, debt := range r.debts {
+		if debt.ID == debtID {
+			copied := *debt
+			return &copied, nil
+		}
+	}
+	return models.NewEmptyDebt(), nil
+}
+
+func (r *fakeRepo) MarkDebtPayed(debtID uint64) (bool, error) {
+	for _, debt := range r.debts {
+		if debt.ID == debtID && debt.Status == models.DebtPending {
+			debt.Status = models.DebtPayed
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeRepo) GetSessionDebts(sessionUUID internal.UUID) ([]*models.Debt, error) {
+	result := make([]*models.Debt, 0)
+	for _, debt := range r.debts {
+		if debt.Session.UUID == sessionUUID {
+			result = append(result, debt)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeRepo) CloseSession(sessionUUID internal.UUID) error {
+	r.closed = append(r.closed, sessionUUID)
+	return nil
+}
+
+func (r *fakeRepo) GetNetting(uint64) (*models.Netting, error) {
+	return r.netting, nil
+}
+
+func (r *fakeRepo) UpdateNettingConfirmation(*models.Netting) error {
+	return nil
+}
+
+func (r *fakeRepo) CompleteNetting(uint64) (bool, error) {
+	for _, debt := range r.netting.Debts {
+		debt.Status = models.DebtPayed
+	}
+	r.debts = append(r.debts, r.netDebt)
+	return true, nil
+}
+
+func settlingSession(n byte) *models.Session {
+	return &models.Session{UUID: internal.UUID{n}, State: models.SessionSettling}
+}
+
+func sessionDebt(id uint64, session *models.Session, creditor, debtor *models.User, money int) *models.Debt {
+	result := debt(creditor, debtor, money)
+	result.ID = id
+	result.Session = session
+	return result
+}
+
+func TestMarkDebtPaidClosesSettledSession(t *testing.T) {
+	session := settlingSession(1)
+	r := &fakeRepo{debts: []*models.Debt{
+		sessionDebt(1, session, user, ivan, 300),
+		sessionDebt(2, session, user, petr, 200),
+	}}
+	uc := New(nil, r, nil)
+
+	r.user = ivan
+	if _, err := uc.MarkDebtPaid(dto.MarkDebtPaidDTO{UserID: ivan.TgID, DebtID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.closed) != 0 {
+		t.Fatalf("session with pending debt is closed")
+	}
+
+	r.user = petr
+	if _, err := uc.MarkDebtPaid(dto.MarkDebtPaidDTO{UserID: petr.TgID, DebtID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []internal.UUID{session.UUID}; !reflect.DeepEqual(r.closed, want) {
+		t.Errorf("closed sessions %v after last debt is paid, want %v", r.closed, want)
+	}
+}
+
+func TestConfirmNettingClosesSettledSessions(t *testing.T) {
+	first, second := settlingSession(1), settlingSession(2)
+	debts := []*models.Debt{sessionDebt(1, first, user, ivan, 500), sessionDebt(2, second, ivan, user, 300)}
+	r := &fakeRepo{
+		user:  ivan,
+		debts: debts,
+		netting: &models.Netting{ID: 1, FirstUser: user, SecondUser: ivan, Money: 200, FirstConfirmed: true,
+			Status: models.NettingProposed, Debts: debts},
+		netDebt: sessionDebt(3, first, user, ivan, 200),
+	}
+	uc := New(nil, r, nil)
+
+	if _, err := uc.ConfirmNetting(dto.ManageNettingDTO{UserID: ivan.TgID, NettingID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []internal.UUID{second.UUID}; !reflect.DeepEqual(r.closed, want) {
+		t.Errorf("closed sessions %v after netting, want %v, session of net debt isn't settled", r.closed, want)
+	}
+}
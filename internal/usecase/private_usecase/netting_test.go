@@ -0,0 +1,120 @@
+package private_usecase
+
+import (
+	"collector-telegram-bot/internal"
+	"collector-telegram-bot/internal/dto"
+	"collector-telegram-bot/internal/models"
+	repo "collector-telegram-bot/internal/repository"
+	"collector-telegram-bot/internal/usecase"
+	"testing"
+)
+
+// fakeRepo keeps debts of user, other methods of repository aren't used by nettings and payments.
+type fakeRepo struct {
+	repo.Repository
+	user    *models.User
+	debts   []*models.Debt
+	netting *models.Netting
+	// netDebt is saved, when netting is completed
+	netDebt *models.Debt
+	closed  []internal.UUID
+}
+
+func (r *fakeRepo) GetUser(tgID int64) (*models.User, error) {
+	if tgID != r.user.TgID {
+		return models.NewUser(), nil
+	}
+	return r.user, nil
+}
+
+func (r *fakeRepo) GetUserPendingDebts(uint64) ([]*models.Debt, error) {
+	return r.debts, nil
+}
+
+var (
+	user  = &models.User{ID: 1, TgID: 11}
+	ivan  = &models.User{ID: 2, TgID: 12}
+	petr  = &models.User{ID: 3, TgID: 13}
+	guest = &models.User{ID: 4}
+)
+
+func debt(creditor, debtor *models.User, money int) *models.Debt {
+	return &models.Debt{Creditor: creditor, Debtor: debtor, Money: money, Status: models.DebtPending}
+}
+
+func TestGetNettings(t *testing.T) {
+	type netting struct {
+		counterparty uint64
+		money        int
+		debts        int
+	}
+	tests := []struct {
+		name  string
+		debts []*models.Debt
+		want  []netting
+	}{
+		{
+			name:  "debts in one direction",
+			debts: []*models.Debt{debt(user, ivan, 500), debt(petr, user, 300)},
+		},
+		{
+			name:  "counterparty owes more",
+			debts: []*models.Debt{debt(user, ivan, 500), debt(ivan, user, 300)},
+			want:  []netting{{counterparty: 2, money: 200, debts: 2}},
+		},
+		{
+			name:  "user owes more",
+			debts: []*models.Debt{debt(user, ivan, 500), debt(user, ivan, 100), debt(ivan, user, 700)},
+			want:  []netting{{counterparty: 2, money: -100, debts: 3}},
+		},
+		{
+			name:  "debts are equal",
+			debts: []*models.Debt{debt(ivan, user, 300), debt(user, ivan, 300)},
+			want:  []netting{{counterparty: 2, money: 0, debts: 2}},
+		},
+		{
+			name: "counterparties are netted separately",
+			debts: []*models.Debt{
+				debt(user, ivan, 500), debt(user, petr, 100), debt(ivan, user, 300),
+				debt(petr, user, 400), debt(user, petr, 50),
+			},
+			want: []netting{{counterparty: 2, money: 200, debts: 2}, {counterparty: 3, money: -250, debts: 3}},
+		},
+		{
+			name:  "guest can't confirm netting",
+			debts: []*models.Debt{debt(user, guest, 500), debt(guest, user, 300)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := New(nil, &fakeRepo{user: user, debts: tt.debts}, nil)
+			nettings, err := uc.GetNettings(dto.GetNettingsDTO{UserID: user.TgID})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(nettings) != len(tt.want) {
+				t.Fatalf("GetNettings() returned %d nettings, want %d", len(nettings), len(tt.want))
+			}
+			for i, got := range nettings {
+				want := tt.want[i]
+				if got.FirstUser != user || got.SecondUser.ID != want.counterparty || got.Money != want.money ||
+					len(got.Debts) != want.debts {
+					t.Errorf("netting %d: with %d for %d of %d debts, want with %d for %d of %d debts", i,
+						got.SecondUser.ID, got.Money, len(got.Debts), want.counterparty, want.money, want.debts)
+				}
+				if got.Status != models.NettingProposed || got.FirstConfirmed || got.SecondConfirmed {
+					t.Errorf("netting %d: status %s, confirmed %v and %v, want unconfirmed proposal", i,
+						got.Status, got.FirstConfirmed, got.SecondConfirmed)
+				}
+			}
+		})
+	}
+
+	t.Run("unknown user", func(t *testing.T) {
+		uc := New(nil, &fakeRepo{user: user}, nil)
+		if _, err := uc.GetNettings(dto.GetNettingsDTO{UserID: 99}); err != usecase.UserNotExistsErr {
+			t.Errorf("GetNettings() err = %v, want %v", err, usecase.UserNotExistsErr)
+		}
+	})
+}
@@ -2,17 +2,628 @@ package private_usecase
 
 import (
 	"collector-telegram-bot/internal"
+	"collector-telegram-bot/internal/dto"
+	"collector-telegram-bot/internal/models"
+	"collector-telegram-bot/internal/notifier"
 	"collector-telegram-bot/internal/repository"
-	tele "gopkg.in/telebot.v3"
+	"collector-telegram-bot/internal/usecase"
+	"fmt"
+	"html"
 )
 
+const sessionsPerPage = 5
+
 type AppPrivateUsecase struct {
-	log  internal.Logger
-	repo repo.Repository
+	log      internal.Logger
+	repo     repo.Repository
+	notifier notifier.Notifier
+}
+
+func New(log internal.Logger, repo repo.Repository, notifier notifier.Notifier) PrivateUsecase {
+	return &AppPrivateUsecase{log: log, repo: repo, notifier: notifier}
+}
+
+// GetSessions returns page of user's sessions from all chats with his results.
+// Page out of range is replaced by the nearest one.
+func (uc *AppPrivateUsecase) GetSessions(info dto.GetSessionsDTO) (*models.SessionsPage, error) {
+	user, err := uc.getUser(info.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := uc.repo.GetUserSessions(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	page := &models.SessionsPage{
+		Summaries: make([]*models.SessionSummary, 0, sessionsPerPage),
+		Pages:     (len(sessions) + sessionsPerPage - 1) / sessionsPerPage,
+		Page:      info.Page,
+	}
+	if page.Page >= page.Pages {
+		page.Page = page.Pages - 1
+	}
+	if page.Page < 0 {
+		page.Page = 0
+	}
+
+	for i := page.Page * sessionsPerPage; i < len(sessions) && len(page.Summaries) < sessionsPerPage; i++ {
+		summary, _, err := uc.summarize(sessions[i], user.ID)
+		if err != nil {
+			return nil, err
+		}
+		page.Summaries = append(page.Summaries, summary)
+	}
+	return page, nil
+}
+
+// GetSessionDetails returns expenses and user's debts of session, where user is member.
+func (uc *AppPrivateUsecase) GetSessionDetails(info dto.GetSessionDetailsDTO) (*models.SessionDetails, error) {
+	user, err := uc.getUser(info.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := uc.repo.GetMemberBySession(info.SessionUUID, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	if member.ID == 0 {
+		return nil, usecase.NotMemberErr
+	}
+
+	session, err := uc.repo.GetSessionByUUID(info.SessionUUID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	summary, debtsMtr, err := uc.summarize(session, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	expenses, err := uc.repo.GetUsersCosts(session.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	details := &models.SessionDetails{Summary: summary, Expenses: expenses}
+	if debtsMtr == nil {
+		return details, nil
+	}
+
+	allUsers, err := uc.repo.GetAllUsers(session.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	for _, curUser := range allUsers {
+		if money := debtsMtr[curUser.ID][user.ID]; money != 0 {
+			details.Debts = append(details.Debts, models.UserSessionDebt{Creditor: curUser, Debtor: user, Money: money})
+		}
+		if money := debtsMtr[user.ID][curUser.ID]; money != 0 {
+			details.Debts = append(details.Debts, models.UserSessionDebt{Creditor: user, Debtor: curUser, Money: money})
+		}
+	}
+	return details, nil
+}
+
+// GetActiveSessions returns active sessions of user, session chosen last time goes first.
+func (uc *AppPrivateUsecase) GetActiveSessions(info dto.GetSessionsDTO) ([]*models.Session, error) {
+	user, err := uc.getUser(info.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := uc.repo.GetUserSessions(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	lastSession, err := uc.repo.GetLastSession(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	result := make([]*models.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.State != models.SessionActive {
+			continue
+		}
+		if session.UUID == lastSession {
+			result = append([]*models.Session{session}, result...)
+		} else {
+			result = append(result, session)
+		}
+	}
+	return result, nil
+}
+
+// ChooseSession checks that user can add expenses to session and remembers his choice.
+func (uc *AppPrivateUsecase) ChooseSession(info dto.ChooseSessionDTO) (*models.Session, error) {
+	user, err := uc.getUser(info.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := uc.repo.GetSessionByUUID(info.SessionUUID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	if session.State != models.SessionActive {
+		return nil, usecase.SessionNotExistsErr
+	}
+
+	member, err := uc.repo.GetMemberBySession(session.UUID, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	if member.ID == 0 {
+		return nil, usecase.NotMemberErr
+	}
+
+	if err = uc.repo.SetLastSession(user.ID, session.UUID); err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	return session, nil
+}
+
+func (uc *AppPrivateUsecase) SetNotify(info dto.SetNotifyDTO) error {
+	user, err := uc.getUser(info.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err = uc.repo.SetNotify(user.ID, info.Notify); err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	return nil
+}
+
+// SetRemind turns on or off reminders about user's debts in all chats.
+func (uc *AppPrivateUsecase) SetRemind(info dto.SetRemindDTO) error {
+	user, err := uc.getUser(info.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err = uc.repo.SetRemind(user.ID, info.Remind); err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	return nil
+}
+
+// GetDebtsToPay returns unpaid debts of finished sessions, where user is debtor.
+func (uc *AppPrivateUsecase) GetDebtsToPay(info dto.GetDebtsToPayDTO) ([]*models.Debt, error) {
+	user, err := uc.getUser(info.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	debts, err := uc.repo.GetUserPendingDebts(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	result := make([]*models.Debt, 0, len(debts))
+	for _, debt := range debts {
+		if debt.Debtor.ID == user.ID {
+			result = append(result, debt)
+		}
+	}
+	return result, nil
 }
 
-func New(log internal.Logger, repo repo.Repository) PrivateUsecase {
-	return &AppPrivateUsecase{log: log, repo: repo}
+// MarkDebtPaid is called by debtor after payment, creditor is notified about it. Session is closed,
+// once its last debt is paid.
+func (uc *AppPrivateUsecase) MarkDebtPaid(info dto.MarkDebtPaidDTO) (*models.Debt, error) {
+	user, err := uc.getUser(info.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	debt, err := uc.repo.GetDebt(info.DebtID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	if debt.ID == 0 {
+		return nil, usecase.DebtNotExistsErr
+	}
+	if debt.Debtor.ID != user.ID {
+		return nil, usecase.NotDebtorErr
+	}
+
+	marked, err := uc.repo.MarkDebtPayed(debt.ID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	if !marked {
+		return nil, usecase.DebtNotExistsErr
+	}
+	debt.Status = models.DebtPayed
+	uc.closeIfSettled(debt.Session)
+
+	creditor, err := uc.repo.GetUserById(debt.Creditor.ID)
+	if err != nil {
+		uc.log.Warnf("Notify payment err: %v", err)
+		return debt, nil
+	}
+	if creditor.Notify && !creditor.IsGuest() {
+		uc.notifier.Notify(creditor.TgID, fmt.Sprintf("%s отметил, что вернул тебе %d рублей за сессию <b>%s</b>",
+			debt.Debtor.Mention(), debt.Money, html.EscapeString(debt.Session.SessionName)))
+	}
+	return debt, nil
+}
+
+// GetDashboard collects unpaid debts of finished sessions and current debts
+// of active sessions, where user is creditor or debtor.
+func (uc *AppPrivateUsecase) GetDashboard(info dto.GetDashboardDTO) (*models.Dashboard, error) {
+	user, err := uc.getUser(info.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard := &models.Dashboard{}
+
+	debts, err := uc.repo.GetUserPendingDebts(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	for _, debt := range debts {
+		if debt.Creditor.ID == user.ID {
+			dashboard.AddDebt(debt.Debtor, debt.Session, debt.Money)
+		} else {
+			dashboard.AddDebt(debt.Creditor, debt.Session, -debt.Money)
+		}
+	}
+
+	sessions, err := uc.repo.GetUserSessions(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	for _, session := range sessions {
+		// Pot session members settle with the pot
+		if session.State != models.SessionActive || session.Mode == models.SessionModePot {
+			continue
+		}
+
+		debtsMtr, err := usecase.FormDebtMtr(uc.repo, session.UUID)
+		if err != nil {
+			return nil, err
+		}
+		allUsers, err := uc.repo.GetAllUsers(session.UUID)
+		if err != nil {
+			return nil, fmt.Errorf("usecase: %v", err.Error())
+		}
+		for _, curUser := range allUsers {
+			if money := debtsMtr[user.ID][curUser.ID] - debtsMtr[curUser.ID][user.ID]; money != 0 {
+				dashboard.AddDebt(curUser, session, money)
+			}
+		}
+	}
+
+	dashboard.Finalize()
+	return dashboard, nil
 }
 
-func (uc *AppPrivateUsecase) Sessions(c tele.Context) {}
+// GetNettings finds users, with whom user has pending debts in both directions,
+// and returns nettings of these debts. Nettings are not saved until proposed.
+func (uc *AppPrivateUsecase) GetNettings(info dto.GetNettingsDTO) ([]*models.Netting, error) {
+	user, err := uc.getUser(info.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	debts, err := uc.repo.GetUserPendingDebts(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	var (
+		nettings       = make([]*models.Netting, 0)
+		byCounterparty = make(map[uint64]*models.Netting)
+		owes           = make(map[uint64]bool)
+		owed           = make(map[uint64]bool)
+	)
+	for _, debt := range debts {
+		counterparty, money := debt.Debtor, debt.Money
+		if debt.Debtor.ID == user.ID {
+			counterparty, money = debt.Creditor, -debt.Money
+		}
+		// Guest can't confirm netting
+		if counterparty.IsGuest() {
+			continue
+		}
+
+		netting, ok := byCounterparty[counterparty.ID]
+		if !ok {
+			netting = &models.Netting{FirstUser: user, SecondUser: counterparty, Status: models.NettingProposed}
+			byCounterparty[counterparty.ID] = netting
+			nettings = append(nettings, netting)
+		}
+		netting.Money += money
+		netting.Debts = append(netting.Debts, debt)
+		owed[counterparty.ID] = owed[counterparty.ID] || money > 0
+		owes[counterparty.ID] = owes[counterparty.ID] || money < 0
+	}
+
+	// Netting makes sense only when debts offset each other
+	result := make([]*models.Netting, 0)
+	for _, netting := range nettings {
+		if owed[netting.SecondUser.ID] && owes[netting.SecondUser.ID] {
+			result = append(result, netting)
+		}
+	}
+	return result, nil
+}
+
+// ProposeNetting saves netting with counterparty confirmed by user, who proposes it.
+func (uc *AppPrivateUsecase) ProposeNetting(info dto.ProposeNettingDTO) (*models.Netting, error) {
+	nettings, err := uc.GetNettings(dto.GetNettingsDTO{UserID: info.UserID})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, netting := range nettings {
+		if netting.SecondUser.ID != info.CounterpartyID {
+			continue
+		}
+
+		netting.FirstConfirmed = true
+		netting.ID, err = uc.repo.CreateNetting(netting)
+		if err == repo.NettingExistsErr {
+			return nil, usecase.NettingProposedErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("usecase: %v", err.Error())
+		}
+		return netting, nil
+	}
+	return nil, usecase.NettingNotExistsErr
+}
+
+// ConfirmNetting saves user's confirmation, netting is done when both users have confirmed it.
+func (uc *AppPrivateUsecase) ConfirmNetting(info dto.ManageNettingDTO) (*models.Netting, error) {
+	user, netting, err := uc.getNetting(info)
+	if err != nil {
+		return nil, err
+	}
+
+	netting.Confirm(user.ID)
+	if err = uc.repo.UpdateNettingConfirmation(netting); err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	if !netting.FirstConfirmed || !netting.SecondConfirmed {
+		return netting, nil
+	}
+
+	done, err := uc.repo.CompleteNetting(netting.ID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	if !done {
+		return nil, usecase.NettingOutdatedErr
+	}
+	netting.Status = models.NettingDone
+
+	// Net debt stays in session of one of debts, so only sessions of other debts may be settled
+	checked := make(map[internal.UUID]bool)
+	for _, debt := range netting.Debts {
+		if !checked[debt.Session.UUID] {
+			checked[debt.Session.UUID] = true
+			uc.closeIfSettled(debt.Session)
+		}
+	}
+	return netting, nil
+}
+
+func (uc *AppPrivateUsecase) DeclineNetting(info dto.ManageNettingDTO) (*models.Netting, error) {
+	_, netting, err := uc.getNetting(info)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = uc.repo.DeclineNetting(netting.ID); err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	netting.Status = models.NettingDeclined
+	return netting, nil
+}
+
+// getNetting returns proposed netting, where user takes part.
+func (uc *AppPrivateUsecase) getNetting(info dto.ManageNettingDTO) (*models.User, *models.Netting, error) {
+	user, err := uc.getUser(info.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	netting, err := uc.repo.GetNetting(info.NettingID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	if netting.ID == 0 || netting.Status != models.NettingProposed ||
+		(netting.FirstUser.ID != user.ID && netting.SecondUser.ID != user.ID) {
+		return nil, nil, usecase.NettingNotExistsErr
+	}
+	return user, netting, nil
+}
+
+// summarize calculates user's spending and balance in session. Debts matrix
+// is returned for regular session, pot session members settle with the pot.
+func (uc *AppPrivateUsecase) summarize(session *models.Session, userID uint64) (*models.SessionSummary,
+	usecase.DebtsMtr, error) {
+	summary := &models.SessionSummary{Session: session}
+
+	allCosts, err := uc.repo.GetAllCosts(session.UUID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	for _, curCost := range allCosts {
+		if curCost.UserID == userID && !curCost.FromPot {
+			summary.Spent += curCost.Money
+		}
+	}
+
+	if session.Mode == models.SessionModePot {
+		pot, err := usecase.FormPotState(uc.repo, session.UUID)
+		if err != nil {
+			return nil, nil, err
+		}
+		summary.Balance = pot.Members[userID].Balance
+		return summary, nil, nil
+	}
+
+	debtsMtr, err := usecase.FormDebtMtr(uc.repo, session.UUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for curUser := range debtsMtr {
+		summary.Balance += debtsMtr[userID][curUser] - debtsMtr[curUser][userID]
+	}
+	return summary, debtsMtr, nil
+}
+
+// closeIfSettled closes settling session, once all its debts are paid. Debts are checked after
+// payment is saved, so session is closed by one of concurrent payments of its last debts.
+// Payment is already saved, so error is only logged and session stays settling.
+func (uc *AppPrivateUsecase) closeIfSettled(session *models.Session) {
+	if session.State != models.SessionSettling {
+		return
+	}
+
+	debts, err := uc.repo.GetSessionDebts(session.UUID)
+	if err != nil {
+		uc.log.Warnf("Close session %v err: %v", session.UUID, err)
+		return
+	}
+	for _, debt := range debts {
+		if debt.Status == models.DebtPending {
+			return
+		}
+	}
+
+	if err = uc.repo.CloseSession(session.UUID); err != nil {
+		uc.log.Warnf("Close session %v err: %v", session.UUID, err)
+		return
+	}
+	session.State = models.SessionClosed
+}
+
+func (uc *AppPrivateUsecase) getUser(tgID int64) (*models.User, error) {
+	user, err := uc.repo.GetUser(tgID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+	if user.ID == 0 {
+		return nil, usecase.UserNotExistsErr
+	}
+	return user, nil
+}
+
+func (uc *AppPrivateUsecase) GetRequisites(info dto.GetRequisitesDTO) ([]*models.Requisite, error) {
+	user, err := uc.getUser(info.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	requisites, err := uc.repo.GetUserRequisites(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	// Requisites are shown to their owner
+	for _, requisite := range requisites {
+		if err = uc.repo.RevealRequisite(requisite); err != nil {
+			return nil, fmt.Errorf("usecase: %v", err.Error())
+		}
+	}
+	return requisites, nil
+}
+
+func (uc *AppPrivateUsecase) AddRequisite(info dto.AddRequisiteDTO) error {
+	user, err := uc.getUser(info.UserID)
+	if err != nil {
+		return err
+	}
+
+	var (
+		value string
+		ok    bool
+	)
+	switch info.Kind {
+	case models.RequisitePhone:
+		value, ok = models.NormalizePhone(info.Value)
+	case models.RequisiteCard:
+		value, ok = models.NormalizeCard(info.Value)
+	}
+	if !ok || info.Bank == "" {
+		return usecase.InvalidRequisiteErr
+	}
+
+	requisites, err := uc.repo.GetUserRequisites(user.ID)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	// First requisite is preferred by default
+	requisite := &models.Requisite{
+		UserID:    user.ID,
+		Kind:      info.Kind,
+		Value:     value,
+		Bank:      info.Bank,
+		IsDefault: len(requisites) == 0,
+	}
+	if _, err = uc.repo.AddRequisite(requisite); err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	return nil
+}
+
+func (uc *AppPrivateUsecase) getRequisiteByNumber(tgID int64, number int) (*models.Requisite,
+	[]*models.Requisite, error) {
+	requisites, err := uc.GetRequisites(dto.GetRequisitesDTO{UserID: tgID})
+	if err != nil {
+		return nil, nil, err
+	}
+	if number < 1 || number > len(requisites) {
+		return nil, nil, usecase.RequisiteNotExistsErr
+	}
+	return requisites[number-1], requisites, nil
+}
+
+func (uc *AppPrivateUsecase) SetDefaultRequisite(info dto.ManageRequisiteDTO) error {
+	requisite, _, err := uc.getRequisiteByNumber(info.UserID, info.Number)
+	if err != nil {
+		return err
+	}
+
+	if err = uc.repo.SetDefaultRequisite(requisite.UserID, requisite.ID); err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	return nil
+}
+
+func (uc *AppPrivateUsecase) DeleteRequisite(info dto.ManageRequisiteDTO) error {
+	requisite, requisites, err := uc.getRequisiteByNumber(info.UserID, info.Number)
+	if err != nil {
+		return err
+	}
+
+	if err = uc.repo.DeleteRequisite(requisite.ID); err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	// Keep one of remaining requisites preferred
+	if !requisite.IsDefault || len(requisites) == 1 {
+		return nil
+	}
+	for _, curRequisite := range requisites {
+		if curRequisite.ID != requisite.ID {
+			if err = uc.repo.SetDefaultRequisite(curRequisite.UserID, curRequisite.ID); err != nil {
+				return fmt.Errorf("usecase: %v", err.Error())
+			}
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+package reminder_usecase
+
+import "time"
+
+type ReminderUsecase interface {
+	SendDueReminders(now time.Time) error
+}
@@ -0,0 +1,84 @@
+package reminder_usecase
+
+import (
+	"collector-telegram-bot/internal"
+	"collector-telegram-bot/internal/models"
+	"collector-telegram-bot/internal/notifier"
+	repo "collector-telegram-bot/internal/repository"
+	"fmt"
+	"html"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+type AppReminderUsecase struct {
+	log      internal.Logger
+	repo     repo.Repository
+	notifier notifier.Notifier
+}
+
+func New(log internal.Logger, repo repo.Repository, notifier notifier.Notifier) ReminderUsecase {
+	return &AppReminderUsecase{log: log, repo: repo, notifier: notifier}
+}
+
+// SendDueReminders nudges debtors, which reminders time has come, and schedules next reminders.
+// Reminder is dropped when debt is paid, reminders are turned off or limit of chat is reached.
+// Failures of single reminder are only logged, so other reminders are still sent.
+func (uc *AppReminderUsecase) SendDueReminders(now time.Time) error {
+	reminders, err := uc.repo.GetDueReminders(now)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	for _, reminder := range reminders {
+		if err = uc.sendReminder(reminder, now); err != nil {
+			uc.log.Warnf("Send reminder %d err: %v", reminder.ID, err)
+			continue
+		}
+	}
+	return nil
+}
+
+func (uc *AppReminderUsecase) sendReminder(reminder *models.Reminder, now time.Time) error {
+	settings, err := uc.repo.GetChatSettings(reminder.ChatID)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	debt, err := uc.repo.GetDebt(reminder.DebtID)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	toGroup := settings.RemindInGroup
+	if !reminder.DebtPending || !reminder.DebtorRemind || !settings.RemindEnabled ||
+		reminder.Sent >= settings.RemindMax || (debt.Debtor.IsGuest() && !toGroup) {
+		return uc.deleteReminder(reminder)
+	}
+
+	text := fmt.Sprintf("Напоминание: %s, за сессию <b>%s</b> нужно вернуть %s %d рублей.",
+		debt.Debtor.Mention(), html.EscapeString(debt.Session.SessionName), debt.Creditor.Mention(), debt.Money)
+	if toGroup {
+		uc.notifier.Notify(reminder.ChatID, text)
+	} else {
+		uc.notifier.Notify(debt.Debtor.TgID, text+"\nЕсли уже вернул, отметь это: /paid")
+	}
+
+	sent := reminder.Sent + 1
+	if sent >= settings.RemindMax {
+		return uc.deleteReminder(reminder)
+	}
+	nextAt := now.Add(time.Duration(settings.RemindIntervalDays) * day)
+	if err = uc.repo.UpdateReminder(reminder.ID, sent, nextAt); err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	return nil
+}
+
+func (uc *AppReminderUsecase) deleteReminder(reminder *models.Reminder) error {
+	if err := uc.repo.DeleteReminder(reminder.ID); err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package usecase
+
+import (
+	"collector-telegram-bot/internal"
+	"collector-telegram-bot/internal/models"
+	repo "collector-telegram-bot/internal/repository"
+	"fmt"
+)
+
+// DebtsMtr stores how much user (column) owes to user (row) by their ids.
+type DebtsMtr map[uint64]map[uint64]int
+
+// Participants returns members of session, who share its expenses, observers are skipped.
+func Participants(repo repo.Repository, sessionUUID internal.UUID) ([]*models.User, error) {
+	members, err := repo.GetSessionMembers(sessionUUID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	users := make([]*models.User, 0, len(members))
+	for _, member := range members {
+		if member.Participates() {
+			users = append(users, member.User)
+		}
+	}
+	return users, nil
+}
+
+// FormDebtMtr splits expenses equally between members and adds transfers,
+// then nets mutual debts.
+func FormDebtMtr(repo repo.Repository, sessionUUID internal.UUID) (DebtsMtr, error) {
+	allUsers, err := Participants(repo, sessionUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	var debtsMtr = make(DebtsMtr)
+	for _, curUser := range allUsers {
+		curDebtor := make(map[uint64]int)
+		for _, tmpUser := range allUsers {
+			curDebtor[tmpUser.ID] = 0
+		}
+		debtsMtr[curUser.ID] = curDebtor
+	}
+
+	allCosts, err := repo.GetAllCosts(sessionUUID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	for _, curCost := range allCosts {
+		userID := curCost.UserID
+		debt := curCost.Money / len(allUsers)
+		curDebtors := debtsMtr[userID]
+		for curDebtor := range curDebtors {
+			if curDebtor != userID {
+				debtsMtr[userID][curDebtor] += debt
+			}
+		}
+	}
+
+	allTransfers, err := repo.GetAllTransfers(sessionUUID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	// Transfer is not shared: recipient owes the whole sum to sender
+	for _, curTransfer := range allTransfers {
+		debtsMtr[curTransfer.SenderID][curTransfer.RecipientID] += curTransfer.Money
+	}
+
+	for curUser, curDebtors := range debtsMtr {
+		for curDebtor := range curDebtors {
+			if debtsMtr[curUser][curDebtor] != 0 && debtsMtr[curDebtor][curUser] != 0 {
+				if debtsMtr[curUser][curDebtor] > debtsMtr[curDebtor][curUser] {
+					debtsMtr[curUser][curDebtor] -= debtsMtr[curDebtor][curUser]
+					debtsMtr[curDebtor][curUser] = 0
+				} else {
+					debtsMtr[curDebtor][curUser] -= debtsMtr[curUser][curDebtor]
+					debtsMtr[curUser][curDebtor] = 0
+				}
+			}
+		}
+	}
+	return debtsMtr, nil
+}
+
+// FormPotState splits all expenses (paid from pot or personally) equally
+// between members and compares the share with what each member has put in.
+func FormPotState(repo repo.Repository, sessionUUID internal.UUID) (*models.PotState, error) {
+	allUsers, err := Participants(repo, sessionUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	allCosts, err := repo.GetAllCosts(sessionUUID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	allContributions, err := repo.GetPotContributions(sessionUUID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	allTransfers, err := repo.GetAllTransfers(sessionUUID)
+	if err != nil {
+		return nil, fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	var (
+		pot      = &models.PotState{Members: map[uint64]models.PotMemberBalance{}}
+		balances = make(map[uint64]models.PotMemberBalance)
+		total    int
+	)
+
+	for _, curCost := range allCosts {
+		total += curCost.Money
+		if curCost.FromPot {
+			pot.Spent += curCost.Money
+			continue
+		}
+		curBalance := balances[curCost.UserID]
+		curBalance.Spent += curCost.Money
+		balances[curCost.UserID] = curBalance
+	}
+
+	for _, curContribution := range allContributions {
+		pot.Contributed += curContribution.Money
+		curBalance := balances[curContribution.UserID]
+		curBalance.Contributed += curContribution.Money
+		balances[curContribution.UserID] = curBalance
+	}
+
+	// Transfers move money between members and don't touch the pot
+	transferred := make(map[uint64]int)
+	for _, curTransfer := range allTransfers {
+		transferred[curTransfer.SenderID] += curTransfer.Money
+		transferred[curTransfer.RecipientID] -= curTransfer.Money
+	}
+
+	pot.Balance = pot.Contributed - pot.Spent
+	if len(allUsers) == 0 {
+		return pot, nil
+	}
+
+	share := total / len(allUsers)
+	for _, curUser := range allUsers {
+		curBalance := balances[curUser.ID]
+		curBalance.User = curUser
+		curBalance.Share = share
+		curBalance.Balance = curBalance.Contributed + curBalance.Spent + transferred[curUser.ID] - share
+		pot.Members[curUser.ID] = curBalance
+	}
+	return pot, nil
+}
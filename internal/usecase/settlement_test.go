@@ -0,0 +1,238 @@
+package usecase
+
+import (
+	"collector-telegram-bot/internal"
+	"collector-telegram-bot/internal/models"
+	repo "collector-telegram-bot/internal/repository"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// fakeRepo returns records of one session, other methods of repository aren't used by settlement.
+type fakeRepo struct {
+	repo.Repository
+	members       []*models.SessionMember
+	costs         []*models.Cost
+	transfers     []*models.Transfer
+	contributions []*models.Contribution
+}
+
+func (r *fakeRepo) GetSessionMembers(internal.UUID) ([]*models.SessionMember, error) {
+	return r.members, nil
+}
+
+func (r *fakeRepo) GetAllCosts(internal.UUID) ([]*models.Cost, error) {
+	return r.costs, nil
+}
+
+func (r *fakeRepo) GetAllTransfers(internal.UUID) ([]*models.Transfer, error) {
+	return r.transfers, nil
+}
+
+func (r *fakeRepo) GetPotContributions(internal.UUID) ([]*models.Contribution, error) {
+	return r.contributions, nil
+}
+
+var (
+	first  = &models.User{ID: 1, TgID: 11}
+	second = &models.User{ID: 2, TgID: 12}
+	third  = &models.User{ID: 3, TgID: 13}
+)
+
+func members(observers []*models.User, users ...*models.User) []*models.SessionMember {
+	result := make([]*models.SessionMember, 0, len(users)+len(observers))
+	for _, user := range users {
+		result = append(result, &models.SessionMember{User: user, Role: models.RoleMember})
+	}
+	for _, user := range observers {
+		result = append(result, &models.SessionMember{User: user, Role: models.RoleObserver})
+	}
+	return result
+}
+
+// matrix returns debts of users, pairs missing in debts owe nothing.
+func matrix(users []*models.User, debts map[[2]uint64]int) DebtsMtr {
+	result := make(DebtsMtr)
+	for _, creditor := range users {
+		result[creditor.ID] = make(map[uint64]int)
+		for _, debtor := range users {
+			result[creditor.ID][debtor.ID] = debts[[2]uint64{creditor.ID, debtor.ID}]
+		}
+	}
+	return result
+}
+
+func TestFormDebtMtr(t *testing.T) {
+	all := []*models.User{first, second, third}
+	tests := []struct {
+		name string
+		repo *fakeRepo
+		want DebtsMtr
+	}{
+		{
+			name: "no expenses",
+			repo: &fakeRepo{members: members(nil, all...)},
+			want: matrix(all, nil),
+		},
+		{
+			name: "expense is split equally",
+			repo: &fakeRepo{
+				members: members(nil, all...),
+				costs:   []*models.Cost{{UserID: 1, Money: 300}},
+			},
+			want: matrix(all, map[[2]uint64]int{{1, 2}: 100, {1, 3}: 100}),
+		},
+		{
+			name: "share is rounded down",
+			repo: &fakeRepo{
+				members: members(nil, all...),
+				costs:   []*models.Cost{{UserID: 1, Money: 100}},
+			},
+			want: matrix(all, map[[2]uint64]int{{1, 2}: 33, {1, 3}: 33}),
+		},
+		{
+			name: "mutual debts are netted",
+			repo: &fakeRepo{
+				members: members(nil, all...),
+				costs:   []*models.Cost{{UserID: 1, Money: 300}, {UserID: 2, Money: 150}},
+			},
+			want: matrix(all, map[[2]uint64]int{{1, 2}: 50, {1, 3}: 100, {2, 3}: 50}),
+		},
+		{
+			name: "transfer is owed in full",
+			repo: &fakeRepo{
+				members:   members(nil, all...),
+				transfers: []*models.Transfer{{SenderID: 1, RecipientID: 2, Money: 200}},
+			},
+			want: matrix(all, map[[2]uint64]int{{1, 2}: 200}),
+		},
+		{
+			name: "transfer pays off debt",
+			repo: &fakeRepo{
+				members:   members(nil, all...),
+				costs:     []*models.Cost{{UserID: 1, Money: 300}},
+				transfers: []*models.Transfer{{SenderID: 2, RecipientID: 1, Money: 100}},
+			},
+			want: matrix(all, map[[2]uint64]int{{1, 3}: 100}),
+		},
+		{
+			name: "observer doesn't share expenses",
+			repo: &fakeRepo{
+				members: members([]*models.User{third}, first, second),
+				costs:   []*models.Cost{{UserID: 1, Money: 300}},
+			},
+			want: matrix([]*models.User{first, second}, map[[2]uint64]int{{1, 2}: 150}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FormDebtMtr(tt.repo, uuid.New())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FormDebtMtr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormPotState(t *testing.T) {
+	tests := []struct {
+		name string
+		repo *fakeRepo
+		want *models.PotState
+	}{
+		{
+			name: "expenses from pot",
+			repo: &fakeRepo{
+				members:       members(nil, first, second),
+				costs:         []*models.Cost{{UserID: 1, Money: 600, FromPot: true}},
+				contributions: []*models.Contribution{{UserID: 1, Money: 1000}, {UserID: 2, Money: 500}},
+			},
+			want: &models.PotState{
+				Contributed: 1500,
+				Spent:       600,
+				Balance:     900,
+				Members: map[uint64]models.PotMemberBalance{
+					1: {User: first, Contributed: 1000, Share: 300, Balance: 700},
+					2: {User: second, Contributed: 500, Share: 300, Balance: 200},
+				},
+			},
+		},
+		{
+			name: "personal expense counts as contribution",
+			repo: &fakeRepo{
+				members:       members(nil, first, second),
+				costs:         []*models.Cost{{UserID: 2, Money: 200}},
+				contributions: []*models.Contribution{{UserID: 1, Money: 400}},
+			},
+			want: &models.PotState{
+				Contributed: 400,
+				Balance:     400,
+				Members: map[uint64]models.PotMemberBalance{
+					1: {User: first, Contributed: 400, Share: 100, Balance: 300},
+					2: {User: second, Spent: 200, Share: 100, Balance: 100},
+				},
+			},
+		},
+		{
+			name: "transfer moves money between members",
+			repo: &fakeRepo{
+				members:       members(nil, first, second),
+				costs:         []*models.Cost{{UserID: 1, Money: 200, FromPot: true}},
+				contributions: []*models.Contribution{{UserID: 1, Money: 200}},
+				transfers:     []*models.Transfer{{SenderID: 2, RecipientID: 1, Money: 100}},
+			},
+			want: &models.PotState{
+				Contributed: 200,
+				Spent:       200,
+				Members: map[uint64]models.PotMemberBalance{
+					1: {User: first, Contributed: 200, Share: 100},
+					2: {User: second, Share: 100},
+				},
+			},
+		},
+		{
+			name: "observer has no share",
+			repo: &fakeRepo{
+				members:       members([]*models.User{third}, first, second),
+				costs:         []*models.Cost{{UserID: 1, Money: 300, FromPot: true}},
+				contributions: []*models.Contribution{{UserID: 1, Money: 300}},
+			},
+			want: &models.PotState{
+				Contributed: 300,
+				Spent:       300,
+				Members: map[uint64]models.PotMemberBalance{
+					1: {User: first, Contributed: 300, Share: 150, Balance: 150},
+					2: {User: second, Share: 150, Balance: -150},
+				},
+			},
+		},
+		{
+			name: "no participants",
+			repo: &fakeRepo{
+				members:       members([]*models.User{first}),
+				contributions: []*models.Contribution{{UserID: 1, Money: 300}},
+			},
+			want: &models.PotState{
+				Contributed: 300,
+				Balance:     300,
+				Members:     map[uint64]models.PotMemberBalance{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FormPotState(tt.repo, uuid.New())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FormPotState() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package user_usecase
+
+import "collector-telegram-bot/internal/dto"
+
+type UserUsecase interface {
+	SyncProfile(info dto.SyncProfileDTO) error
+}
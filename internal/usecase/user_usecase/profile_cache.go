@@ -0,0 +1,52 @@
+package user_usecase
+
+import (
+	"collector-telegram-bot/internal/models"
+	"container/list"
+	"sync"
+)
+
+// knownLimit bounds count of cached profiles, it is much more than count of users active at the same time.
+const knownLimit = 10000
+
+// profileCache keeps profiles already synced with db, least recently used one is evicted over limit.
+type profileCache struct {
+	mu    sync.Mutex
+	limit int
+	order *list.List
+	items map[int64]*list.Element
+}
+
+func newProfileCache(limit int) *profileCache {
+	return &profileCache{limit: limit, order: list.New(), items: make(map[int64]*list.Element)}
+}
+
+func (c *profileCache) get(tgID int64) (models.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.items[tgID]
+	if !ok {
+		return models.User{}, false
+	}
+	c.order.MoveToFront(item)
+	return item.Value.(models.User), true
+}
+
+func (c *profileCache) put(profile models.User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if item, ok := c.items[profile.TgID]; ok {
+		item.Value = profile
+		c.order.MoveToFront(item)
+		return
+	}
+	c.items[profile.TgID] = c.order.PushFront(profile)
+
+	if c.order.Len() > c.limit {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(models.User).TgID)
+	}
+}
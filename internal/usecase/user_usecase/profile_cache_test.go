@@ -0,0 +1,47 @@
+package user_usecase
+
+import (
+	"collector-telegram-bot/internal/models"
+	"testing"
+)
+
+func TestProfileCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newProfileCache(2)
+	c.put(models.User{TgID: 1, Username: "first"})
+	c.put(models.User{TgID: 2, Username: "second"})
+
+	// Reading first profile makes second one the oldest
+	if _, ok := c.get(1); !ok {
+		t.Fatal("profile 1 isn't cached")
+	}
+	c.put(models.User{TgID: 3, Username: "third"})
+
+	if _, ok := c.get(2); ok {
+		t.Error("profile 2 isn't evicted")
+	}
+	for _, tgID := range []int64{1, 3} {
+		if _, ok := c.get(tgID); !ok {
+			t.Errorf("profile %d is evicted", tgID)
+		}
+	}
+	if c.order.Len() != 2 || len(c.items) != 2 {
+		t.Errorf("cache has %d profiles and %d keys, want 2", c.order.Len(), len(c.items))
+	}
+}
+
+func TestProfileCacheUpdatesProfile(t *testing.T) {
+	c := newProfileCache(2)
+	c.put(models.User{TgID: 1, Username: "old"})
+	c.put(models.User{TgID: 2, Username: "second"})
+	c.put(models.User{TgID: 1, Username: "new"})
+
+	// Update makes profile the newest one
+	c.put(models.User{TgID: 3, Username: "third"})
+
+	if profile, ok := c.get(1); !ok || profile.Username != "new" {
+		t.Errorf("get(1) = %+v, %v, want updated profile", profile, ok)
+	}
+	if _, ok := c.get(2); ok {
+		t.Error("profile 2 isn't evicted")
+	}
+}
@@ -0,0 +1,64 @@
+package user_usecase
+
+import (
+	"collector-telegram-bot/internal"
+	"collector-telegram-bot/internal/dto"
+	"collector-telegram-bot/internal/models"
+	repo "collector-telegram-bot/internal/repository"
+	"fmt"
+)
+
+type AppUserUsecase struct {
+	log  internal.Logger
+	repo repo.Repository
+
+	// Profiles already synced with db, so unchanged profile costs no queries
+	known *profileCache
+}
+
+func New(log internal.Logger, repo repo.Repository) UserUsecase {
+	return &AppUserUsecase{log: log, repo: repo, known: newProfileCache(knownLimit)}
+}
+
+// SyncProfile creates user or updates his profile if it was changed in telegram.
+func (uc *AppUserUsecase) SyncProfile(info dto.SyncProfileDTO) error {
+	profile := models.User{
+		TgID:         info.UserID,
+		Username:     info.Username,
+		FirstName:    info.FirstName,
+		LastName:     info.LastName,
+		LanguageCode: info.LanguageCode,
+	}
+
+	known, ok := uc.known.get(info.UserID)
+	if ok && sameProfile(&known, &profile) {
+		return nil
+	}
+
+	user, err := uc.repo.GetUser(info.UserID)
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	switch {
+	case user.ID == 0:
+		profile.ID, err = uc.repo.CreateUser(&profile)
+	case !sameProfile(user, &profile):
+		profile.ID = user.ID
+		err = uc.repo.UpdateUserProfile(&profile)
+		uc.log.Infof("Profile of user %d updated", user.ID)
+	default:
+		profile.ID = user.ID
+	}
+	if err != nil {
+		return fmt.Errorf("usecase: %v", err.Error())
+	}
+
+	uc.known.put(profile)
+	return nil
+}
+
+func sameProfile(a *models.User, b *models.User) bool {
+	return a.Username == b.Username && a.FirstName == b.FirstName && a.LastName == b.LastName &&
+		a.LanguageCode == b.LanguageCode
+}